@@ -0,0 +1,309 @@
+// Package client talks to the skein proxy: it runs queries synchronously,
+// submits jobs and waits for them, cancels them and iterates over streamed
+// rows. Results decode into api.QueryResults with a typed api.Column per
+// column.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"skein/internal/api"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueryTimeout is how long the proxy waits for the result of Query before it
+// gives up with ErrTimeout, Run waits as long as its context allows.
+const QueryTimeout = 30 * time.Second
+
+// Client is a client of the proxy's public API. Change its fields before
+// the first request.
+type Client struct {
+	// BaseURL is the proxy's address, like http://proxy:8080.
+	BaseURL string
+	// APIKey is sent in X-API-Key, Token as a bearer token.
+	APIKey string
+	Token  string
+	// UserID is the user_id of queries that don't set one. The proxy replaces
+	// it with the principal of the credentials if it authenticates clients.
+	UserID string
+	// HTTPClient sends the requests, http.DefaultClient if it's nil.
+	HTTPClient *http.Client
+	// Timeout limits every call except Wait and Stream, which last as long as
+	// their context. Zero means no limit.
+	Timeout time.Duration
+	// PollInterval is how often Wait asks for the result of a job.
+	PollInterval time.Duration
+}
+
+// New returns a client of the proxy at baseURL.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		Timeout:      time.Minute,
+		PollInterval: 200 * time.Millisecond,
+	}
+}
+
+// Query runs a query and waits for its result, the proxy gives up after
+// QueryTimeout with ErrTimeout. A query that fails returns a *QueryError.
+func (c *Client) Query(ctx context.Context, req api.QueryRequest) (*api.QueryResults, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	resp, err := c.do(ctx, http.MethodPost, "/query", c.queryBody(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return decodeResults(resp, "")
+}
+
+// Submit queues a query and returns its job ID without waiting for it.
+func (c *Client) Submit(ctx context.Context, req api.QueryRequest) (string, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	resp, err := c.do(ctx, http.MethodPost, "/jobs", c.queryBody(req))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return "", newStatusError(resp)
+	}
+	var submitted api.QueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&submitted); err != nil {
+		return "", fmt.Errorf("decoding response: %w", err)
+	}
+	return submitted.JobID, nil
+}
+
+// Job returns the state of a job, without its result.
+func (c *Client) Job(ctx context.Context, jobID string) (*api.Job, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	resp, err := c.do(ctx, http.MethodGet, "/jobs/"+url.PathEscape(jobID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError(resp)
+	}
+	var job api.Job
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+		return nil, fmt.Errorf("decoding job: %w", err)
+	}
+	return &job, nil
+}
+
+// Result returns the result of a finished job, or nil while it's still
+// queued or running.
+func (c *Client) Result(ctx context.Context, jobID string) (*api.QueryResults, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	resp, err := c.do(ctx, http.MethodGet, "/jobs/"+url.PathEscape(jobID)+"/result", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusAccepted {
+		return nil, nil
+	}
+	return decodeResults(resp, jobID)
+}
+
+// Wait polls the result of a job until it's finished or ctx is done.
+func (c *Client) Wait(ctx context.Context, jobID string) (*api.QueryResults, error) {
+	ticker := time.NewTicker(c.PollInterval)
+	defer ticker.Stop()
+	for {
+		results, err := c.Result(ctx, jobID)
+		if err != nil || results != nil {
+			return results, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run submits a query and waits for its result until ctx is done, so unlike
+// Query it isn't cut off after QueryTimeout. The job is cancelled if ctx ends
+// first.
+func (c *Client) Run(ctx context.Context, req api.QueryRequest) (*api.QueryResults, error) {
+	jobID, err := c.Submit(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	results, err := c.Wait(ctx, jobID)
+	if ctx.Err() != nil {
+		c.Cancel(context.WithoutCancel(ctx), jobID)
+	}
+	return results, err
+}
+
+// JobFilter selects the jobs Jobs lists, its zero value lists the newest
+// jobs of all users.
+type JobFilter struct {
+	UserID string
+	Status api.JobStatus
+	// Limit is the maximum number of jobs, the proxy's default if it's zero.
+	Limit int
+}
+
+// Jobs lists the newest jobs matching the filter, without their results. A
+// proxy authenticating clients only lists the caller's jobs.
+func (c *Client) Jobs(ctx context.Context, filter JobFilter) ([]api.Job, error) {
+	query := url.Values{}
+	if filter.UserID != "" {
+		query.Set("user_id", filter.UserID)
+	}
+	if filter.Status != "" {
+		query.Set("status", string(filter.Status))
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	var jobs []api.Job
+	return jobs, c.getJSON(ctx, "/jobs?"+query.Encode(), &jobs)
+}
+
+// Workers lists the workers registered with the proxy.
+func (c *Client) Workers(ctx context.Context) ([]api.WorkerInfo, error) {
+	var workers []api.WorkerInfo
+	return workers, c.getJSON(ctx, "/workers", &workers)
+}
+
+// Cancel cancels a queued or running job. It returns ErrFinished if the job
+// finished already.
+func (c *Client) Cancel(ctx context.Context, jobID string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	resp, err := c.do(ctx, http.MethodDelete, "/jobs/"+url.PathEscape(jobID), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusConflict {
+		return ErrFinished
+	}
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError(resp)
+	}
+	return nil
+}
+
+// getJSON decodes the JSON response of a GET request into v.
+func (c *Client) getJSON(ctx context.Context, path string, v any) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.Timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.Timeout)
+}
+
+func (c *Client) queryBody(req api.QueryRequest) any {
+	if req.UserID == "" {
+		req.UserID = c.UserID
+	}
+	return req
+}
+
+// do sends a request with the client's credentials and a JSON body, if body
+// isn't nil.
+func (c *Client) do(ctx context.Context, method, path string, body any, header ...string) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return httpClient.Do(req)
+}
+
+// decodeResults decodes the api.QueryResults response of a finished job.
+func decodeResults(resp *http.Response, jobID string) (*api.QueryResults, error) {
+	// Failed and cancelled jobs have their error in a JSON body too.
+	mediaType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";")
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if mediaType != "application/json" {
+			return nil, fmt.Errorf("unexpected %s response, the query asked for another format", mediaType)
+		}
+	case http.StatusConflict, http.StatusInternalServerError:
+		if mediaType != "application/json" {
+			return nil, newStatusError(resp)
+		}
+	default:
+		return nil, newStatusError(resp)
+	}
+
+	var results api.QueryResults
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, fmt.Errorf("decoding results: %w", err)
+	}
+	if results.JobID == "" {
+		results.JobID = jobID
+	}
+	if err := resultError(resp.StatusCode, &results); err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
+// resultError returns the error of a finished job's results.
+func resultError(status int, results *api.QueryResults) error {
+	if status == http.StatusConflict || results.Error == "job cancelled" {
+		return ErrCancelled
+	}
+	if results.Error != "" {
+		return &QueryError{JobID: results.JobID, Message: results.Error, Timeline: results.Timeline}
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"skein/internal/api"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, mux *http.ServeMux) *Client {
+	t.Helper()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	c := New(server.URL + "/")
+	c.PollInterval = time.Millisecond
+	return c
+}
+
+func writeJSON(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write([]byte(body))
+}
+
+func TestClient_Query(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /query", func(w http.ResponseWriter, r *http.Request) {
+		var req api.QueryRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "key", r.Header.Get("X-API-Key"))
+		assert.Equal(t, "Bearer jwt", r.Header.Get("Authorization"))
+		assert.Equal(t, "default-user", req.UserID)
+		assert.Equal(t, map[string]any{"pax": float64(2)}, req.Params)
+		writeJSON(w, http.StatusOK, `{"job_id":"j1","column_names":["n","d"],
+			"column_types":[{"type":"INTEGER"},{"type":"DECIMAL(4,2)"}],
+			"column_data":[[0,null],["1.50",null]],"profile":{"rows_returned":2}}`)
+	})
+	c := newTestClient(t, mux)
+	c.APIKey, c.Token, c.UserID = "key", "jwt", "default-user"
+
+	results, err := c.Query(context.Background(), api.QueryRequest{Query: "SELECT", Params: map[string]any{"pax": 2}})
+	require.NoError(t, err)
+	assert.Equal(t, "j1", results.JobID)
+	assert.Equal(t, 2, results.Profile.RowsReturned)
+	n := results.ColumnData[0].(api.Column)
+	v, ok := ColumnValue[int32](n, 0)
+	assert.True(t, ok)
+	assert.Zero(t, v)
+	assert.True(t, n.IsNull(1))
+	d, _ := api.ParseDecimal("1.50")
+	assert.Equal(t, d, results.ColumnData[1].(api.Column).Value(0))
+}
+
+func TestClient_Errors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /query", func(w http.ResponseWriter, r *http.Request) {
+		var req api.QueryRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		switch req.Query {
+		case "failing":
+			writeJSON(w, http.StatusInternalServerError, `{"error":"Binder Error: no such column"}`)
+		case "cancelled":
+			writeJSON(w, http.StatusConflict, `{"error":"job cancelled"}`)
+		case "slow":
+			http.Error(w, "Request timed out", http.StatusGatewayTimeout)
+		default:
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		}
+	})
+	c := newTestClient(t, mux)
+	ctx := context.Background()
+
+	_, err := c.Query(ctx, api.QueryRequest{Query: "failing"})
+	var queryErr *QueryError
+	require.ErrorAs(t, err, &queryErr)
+	assert.Equal(t, "Binder Error: no such column", queryErr.Message)
+
+	_, err = c.Query(ctx, api.QueryRequest{Query: "cancelled"})
+	assert.ErrorIs(t, err, ErrCancelled)
+
+	_, err = c.Query(ctx, api.QueryRequest{Query: "slow"})
+	assert.ErrorIs(t, err, ErrTimeout)
+
+	_, err = c.Query(ctx, api.QueryRequest{Query: "anything"})
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, "Unauthorized", statusErr.Message)
+}
+
+func TestClient_SubmitWaitCancel(t *testing.T) {
+	var polls atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusAccepted, `{"job_id":"j1"}`)
+	})
+	mux.HandleFunc("GET /jobs/{id}/result", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "j1" {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		if polls.Add(1) < 3 {
+			writeJSON(w, http.StatusAccepted, `{"id":"j1","status":"running"}`)
+			return
+		}
+		writeJSON(w, http.StatusOK, `{"job_id":"j1","column_names":["a"],"column_types":[{"type":"VARCHAR"}],"column_data":[["x"]]}`)
+	})
+	mux.HandleFunc("DELETE /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.PathValue("id") {
+		case "j1":
+			http.Error(w, "Job already finished", http.StatusConflict)
+		case "j2":
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.Error(w, "Job not found", http.StatusNotFound)
+		}
+	})
+	c := newTestClient(t, mux)
+	ctx := context.Background()
+
+	jobID, err := c.Submit(ctx, api.QueryRequest{Query: "SELECT 'x'"})
+	require.NoError(t, err)
+	assert.Equal(t, "j1", jobID)
+
+	results, err := c.Wait(ctx, jobID)
+	require.NoError(t, err)
+	assert.Equal(t, api.Column{Values: []string{"x"}}, results.ColumnData[0])
+	assert.EqualValues(t, 3, polls.Load())
+
+	_, err = c.Wait(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.ErrorIs(t, c.Cancel(ctx, "j1"), ErrFinished)
+	assert.NoError(t, c.Cancel(ctx, "j2"))
+	assert.ErrorIs(t, c.Cancel(ctx, "j3"), ErrNotFound)
+}
+
+func TestClient_Run(t *testing.T) {
+	var cancelled atomic.Bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", func(w http.ResponseWriter, r *http.Request) {
+		var req api.QueryRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		writeJSON(w, http.StatusAccepted, `{"job_id":"`+req.Query+`"}`)
+	})
+	mux.HandleFunc("GET /jobs/{id}/result", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "slow" {
+			writeJSON(w, http.StatusAccepted, `{"id":"slow","status":"running"}`)
+			return
+		}
+		writeJSON(w, http.StatusOK, `{"job_id":"fast","column_names":["a"],"column_types":[{"type":"INTEGER"}],"column_data":[[1]]}`)
+	})
+	mux.HandleFunc("DELETE /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		cancelled.Store(r.PathValue("id") == "slow")
+	})
+	c := newTestClient(t, mux)
+
+	results, err := c.Run(context.Background(), api.QueryRequest{Query: "fast"})
+	require.NoError(t, err)
+	assert.Equal(t, "fast", results.JobID)
+	assert.False(t, cancelled.Load())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = c.Run(ctx, api.QueryRequest{Query: "slow"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, cancelled.Load(), "the job of an expired context is cancelled")
+}
+
+func TestClient_Stream(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /query", func(w http.ResponseWriter, r *http.Request) {
+		var req api.QueryRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		assert.Equal(t, api.FormatNDJSON, req.Format)
+		assert.Equal(t, api.NDJSONMediaType, r.Header.Get("Accept"))
+		if req.Query == "failing" {
+			writeJSON(w, http.StatusInternalServerError, `{"error":"boom"}`)
+			return
+		}
+		w.Header().Set("Content-Type", api.NDJSONMediaType)
+		w.Write([]byte(`{"schema":{"column_names":["id","day"],"column_types":[{"type":"BIGINT"},{"type":"DATE"}]}}
+{"rows":[[1,"2024-01-01"],[2,null]]}
+{"rows":[[3,"2024-01-03"]]}
+`))
+		if req.Query == "interrupted" {
+			w.Write([]byte(`{"trailer":{"error":"job cancelled"}}` + "\n"))
+			return
+		}
+		w.Write([]byte(`{"trailer":{"job_id":"j1","profile":{"rows_returned":3}}}` + "\n"))
+	})
+	c := newTestClient(t, mux)
+	ctx := context.Background()
+
+	rows, err := c.Stream(ctx, api.QueryRequest{Query: "SELECT"})
+	require.NoError(t, err)
+	defer rows.Close()
+	assert.Equal(t, []string{"id", "day"}, rows.Columns())
+	var got [][]any
+	for rows.Next() {
+		assert.Nil(t, rows.Trailer())
+		got = append(got, rows.Values())
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, [][]any{
+		{int64(1), api.Date{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{int64(2), nil},
+		{int64(3), api.Date{Time: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)}},
+	}, got)
+	require.NotNil(t, rows.Trailer())
+	assert.Equal(t, 3, rows.Trailer().Profile.RowsReturned)
+
+	rows, err = c.Stream(ctx, api.QueryRequest{Query: "interrupted"})
+	require.NoError(t, err)
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	assert.Equal(t, 3, n)
+	assert.ErrorIs(t, rows.Err(), ErrCancelled)
+
+	_, err = c.Stream(ctx, api.QueryRequest{Query: "failing"})
+	var queryErr *QueryError
+	assert.True(t, errors.As(err, &queryErr))
+}
+
+func TestClient_JobsAndWorkers(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "status=running&user_id=alice", r.URL.RawQuery)
+		writeJSON(w, http.StatusOK, `[{"id":"j2","user_id":"alice","query":"SELECT 2","status":"running"}]`)
+	})
+	mux.HandleFunc("GET /workers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, `[{"id":"w1","slots":2,"running":1,"ready":true,"capacity":{"size":"large"}}]`)
+	})
+	c := newTestClient(t, mux)
+	ctx := context.Background()
+
+	jobs, err := c.Jobs(ctx, JobFilter{UserID: "alice", Status: api.StatusRunning})
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "j2", jobs[0].ID)
+	assert.Equal(t, api.StatusRunning, jobs[0].Status)
+
+	workers, err := c.Workers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []WorkerInfo{{ID: "w1", Slots: 2, Running: 1, Ready: true, Capacity: api.WorkerCapacity{Size: api.SizeLarge}}}, workers)
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"skein/internal/api"
+	"strings"
+)
+
+var (
+	// ErrUnauthorized is returned when the proxy rejects the credentials.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrNotFound is returned for jobs the proxy doesn't know.
+	ErrNotFound = errors.New("job not found")
+	// ErrTimeout is returned when the proxy gave up waiting for a result.
+	ErrTimeout = errors.New("query timed out")
+	// ErrCancelled is returned for the result of a cancelled job.
+	ErrCancelled = errors.New("job cancelled")
+	// ErrFinished is returned when cancelling a job that finished already.
+	ErrFinished = errors.New("job already finished")
+)
+
+// StatusError is a response of the proxy with an unexpected status code. It
+// matches ErrUnauthorized, ErrNotFound and ErrTimeout with errors.Is.
+type StatusError struct {
+	StatusCode int
+	// Message is the response body.
+	Message string
+}
+
+func newStatusError(resp *http.Response) *StatusError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &StatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("proxy responded %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrTimeout:
+		return e.StatusCode == http.StatusGatewayTimeout
+	}
+	return false
+}
+
+// QueryError is a query that failed to run.
+type QueryError struct {
+	JobID   string
+	Message string
+	// Timeline holds the stages the job went through before it failed.
+	Timeline api.Timeline
+}
+
+func (e *QueryError) Error() string {
+	if e.JobID == "" {
+		return "query failed: " + e.Message
+	}
+	return fmt.Sprintf("job %s failed: %s", e.JobID, e.Message)
+}
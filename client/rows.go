@@ -0,0 +1,195 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"skein/internal/api"
+	"strings"
+)
+
+// Rows iterates over the rows of a streamed result as the worker produces
+// them:
+//
+//	rows, err := c.Stream(ctx, req)
+//	if err != nil { ... }
+//	defer rows.Close()
+//	for rows.Next() {
+//		values := rows.Values()
+//	}
+//	if err := rows.Err(); err != nil { ... }
+type Rows struct {
+	body    io.Closer
+	dec     *json.Decoder
+	names   []string
+	columns []api.ColumnType
+	types   []*api.DataType
+
+	batch   [][]json.RawMessage
+	values  []any
+	trailer *api.QueryResults
+	err     error
+
+	// results holds the whole result when the proxy didn't stream it.
+	results *api.QueryResults
+	row     int
+}
+
+// streamLine is an api.StreamMessage with the rows left undecoded until
+// their types are known.
+type streamLine struct {
+	Schema  *api.StreamSchema   `json:"schema"`
+	Rows    [][]json.RawMessage `json:"rows"`
+	Trailer *api.QueryResults   `json:"trailer"`
+}
+
+// Stream runs a query with its result streamed as NDJSON. The rows can be
+// read as long as ctx isn't done, the Client's Timeout doesn't apply.
+func (c *Client) Stream(ctx context.Context, req api.QueryRequest) (*Rows, error) {
+	req.Format = api.FormatNDJSON
+	resp, err := c.do(ctx, http.MethodPost, "/query", c.queryBody(req), "Accept", api.NDJSONMediaType)
+	if err != nil {
+		return nil, err
+	}
+	if mediaType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";"); resp.StatusCode != http.StatusOK || mediaType != api.NDJSONMediaType {
+		// A query failing before it streams anything gets a plain response.
+		defer resp.Body.Close()
+		results, err := decodeResults(resp, "")
+		if err != nil {
+			return nil, err
+		}
+		return &Rows{names: results.ColumnNames, columns: results.ColumnTypes, results: results, trailer: results}, nil
+	}
+
+	rows := &Rows{body: resp.Body, dec: json.NewDecoder(resp.Body)}
+	var first streamLine
+	if err := rows.dec.Decode(&first); err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("reading result stream: %w", err)
+	}
+	if first.Trailer != nil {
+		rows.finish(first.Trailer)
+		return rows, nil
+	}
+	if first.Schema == nil {
+		resp.Body.Close()
+		return nil, errors.New("result stream doesn't start with a schema")
+	}
+	rows.names = first.Schema.ColumnNames
+	rows.columns = first.Schema.ColumnTypes
+	rows.types = make([]*api.DataType, len(rows.columns))
+	for i, col := range rows.columns {
+		if rows.types[i], err = api.ParseType(col.Type); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unsupported column type: %w", err)
+		}
+	}
+	return rows, nil
+}
+
+// Columns returns the names of the columns.
+func (r *Rows) Columns() []string {
+	return r.names
+}
+
+// ColumnTypes returns the types of the columns.
+func (r *Rows) ColumnTypes() []api.ColumnType {
+	return r.columns
+}
+
+// Next advances to the next row, it returns false at the end of the result
+// or on an error.
+func (r *Rows) Next() bool {
+	if r.results != nil {
+		return r.nextResultRow()
+	}
+	for len(r.batch) == 0 {
+		if r.dec == nil || r.err != nil {
+			return false
+		}
+		var line streamLine
+		if err := r.dec.Decode(&line); err != nil {
+			if err == io.EOF {
+				err = errors.New("result stream ended without a trailer")
+			}
+			r.err = err
+			r.Close()
+			return false
+		}
+		if line.Trailer != nil {
+			r.finish(line.Trailer)
+			return false
+		}
+		r.batch = line.Rows
+	}
+
+	raw := r.batch[0]
+	r.batch = r.batch[1:]
+	r.values = make([]any, len(raw))
+	for i, v := range raw {
+		var err error
+		if i < len(r.types) {
+			r.values[i], err = api.DecodeValue(r.types[i], v)
+		}
+		if err != nil {
+			r.err = fmt.Errorf("decoding column %s: %w", r.names[i], err)
+			r.Close()
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Rows) nextResultRow() bool {
+	if len(r.results.ColumnData) == 0 || r.row >= api.AsColumn(r.results.ColumnData[0]).Len() {
+		return false
+	}
+	r.values = make([]any, len(r.results.ColumnData))
+	for i, data := range r.results.ColumnData {
+		r.values[i] = api.AsColumn(data).Value(r.row)
+	}
+	r.row++
+	return true
+}
+
+// Values returns the values of the current row, nil for NULL. They have the
+// Go types of api.DecodeValue.
+func (r *Rows) Values() []any {
+	return r.values
+}
+
+// Trailer returns the outcome of the query with its profile once Next
+// returned false, nil before.
+func (r *Rows) Trailer() *api.QueryResults {
+	if r.dec != nil {
+		return nil
+	}
+	return r.trailer
+}
+
+// Err returns the error that ended the iteration: a *QueryError or
+// ErrCancelled from the trailer or an error reading the stream.
+func (r *Rows) Err() error {
+	return r.err
+}
+
+// Close stops reading the result, the proxy cancels the query if it's still
+// running.
+func (r *Rows) Close() error {
+	r.dec = nil
+	if r.body == nil {
+		return nil
+	}
+	body := r.body
+	r.body = nil
+	return body.Close()
+}
+
+func (r *Rows) finish(trailer *api.QueryResults) {
+	r.trailer = trailer
+	r.err = resultError(http.StatusOK, trailer)
+	r.Close()
+}
@@ -0,0 +1,38 @@
+package client
+
+import "skein/internal/api"
+
+// Aliases of the API types, so programs outside this module can use them.
+type (
+	QueryRequest   = api.QueryRequest
+	QueryResults   = api.QueryResults
+	Job            = api.Job
+	JobStatus      = api.JobStatus
+	ColumnType     = api.ColumnType
+	Column         = api.Column
+	Priority       = api.Priority
+	QueryClass     = api.QueryClass
+	ResultFormat   = api.ResultFormat
+	Timeline       = api.Timeline
+	Decimal        = api.Decimal
+	Date           = api.Date
+	TimeOfDay      = api.TimeOfDay
+	TimeTZ         = api.TimeTZ
+	Interval       = api.Interval
+	Map            = api.Map
+	Union          = api.Union
+	ProfilingStats = api.ProfilingStats
+	WorkerInfo     = api.WorkerInfo
+)
+
+const (
+	PriorityLow    = api.PriorityLow
+	PriorityNormal = api.PriorityNormal
+	PriorityHigh   = api.PriorityHigh
+)
+
+// ColumnValue returns row i of a column of Go type T and false if the row is
+// NULL, see api.ColumnValue.
+func ColumnValue[T any](c Column, i int) (T, bool) {
+	return api.ColumnValue[T](c, i)
+}
@@ -4,7 +4,13 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"skein/internal/api"
+	"skein/internal/auth"
+	"skein/internal/console"
+	"skein/internal/jobstore"
 	"skein/internal/proxy"
+	"skein/internal/settings"
+	"skein/internal/tracing"
 )
 
 func main() {
@@ -12,21 +18,109 @@ func main() {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	slog.SetDefault(logger)
 
+	settings.JobAckTimeout = settings.DurationFromEnv("JOB_ACK_TIMEOUT", settings.JobAckTimeout)
+	settings.JobLeaseDuration = settings.DurationFromEnv("JOB_LEASE_DURATION", settings.JobLeaseDuration)
+	settings.MaxDeliveryAttempts = settings.IntFromEnv("MAX_DELIVERY_ATTEMPTS", settings.MaxDeliveryAttempts)
+	settings.ResultPageLimit = settings.IntFromEnv("RESULT_PAGE_LIMIT", settings.ResultPageLimit)
+	settings.MaxResultPageLimit = settings.IntFromEnv("MAX_RESULT_PAGE_LIMIT", settings.MaxResultPageLimit)
+	settings.ResultCacheTTL = settings.DurationFromEnv("RESULT_CACHE_TTL", settings.ResultCacheTTL)
+	settings.ResultCacheMaxBytes = int64(settings.IntFromEnv("RESULT_CACHE_MAX_BYTES", int(settings.ResultCacheMaxBytes)))
+	if dir := os.Getenv("DATASETS_DIR"); dir != "" {
+		settings.DatasetsDir = dir
+	}
+	if settings.ResultCacheTTL > 0 && settings.ResultCacheMaxBytes > 0 {
+		if err := proxy.CheckDatasets(settings.DatasetsDir); err != nil {
+			slog.Warn("the result cache can't see the datasets, queries over them won't be cached",
+				"event", "cache.datasets_missing", "dir", settings.DatasetsDir, "error", err)
+		}
+	}
+	if dir := os.Getenv("EXPORT_SPOOL_DIR"); dir != "" {
+		settings.ExportSpoolDir = dir
+	}
+	settings.JobStorePath = os.Getenv("JOB_STORE_PATH")
+	settings.JobStoreResults = settings.BoolFromEnv("JOB_STORE_RESULTS", settings.JobStoreResults)
+
 	// Instantiate the new worker registry and the old queue systems.
 	registry := proxy.NewWorkerRegistry()
-	jobQueue := proxy.NewJobQueue()
+	scheduler := proxy.NewScheduler(
+		[]proxy.ClassConfig{
+			{Class: api.ClassUser, MaxConcurrent: settings.IntFromEnv("MAX_CONCURRENT_USER", settings.MaxConcurrentUserQueries)},
+			{Class: api.ClassSystem, MaxConcurrent: settings.IntFromEnv("MAX_CONCURRENT_SYSTEM", settings.MaxConcurrentSystemQueries)},
+		},
+		settings.DurationFromEnv("QUEUE_AGING_INTERVAL", settings.QueueAgingInterval),
+		api.Priority(settings.QueueAgingStep),
+	)
 	resultStore := proxy.NewResultStore()
+	jobStore := proxy.NewJobStore()
+	if settings.JobStorePath != "" {
+		backend, err := jobstore.OpenDuckDB(settings.JobStorePath, settings.JobStoreResults)
+		if err != nil {
+			slog.Error("failed to open job store", "path", settings.JobStorePath, "error", err)
+			os.Exit(1)
+		}
+		defer backend.Close()
+		if jobStore, err = proxy.NewPersistentJobStore(backend); err != nil {
+			slog.Error("failed to load jobs", "path", settings.JobStorePath, "error", err)
+			os.Exit(1)
+		}
+		defer jobStore.Close()
+	}
 
 	// The Proxy now holds all dispatching and result systems.
-	p := proxy.NewProxy(registry, jobQueue, resultStore)
+	p := proxy.NewProxy(registry, scheduler, resultStore, jobStore)
 
-	// User-facing and health-check endpoints.
-	http.HandleFunc("/query", p.QueryHandler)
+	// Spans go to the OTLP collector of OTEL_EXPORTER_OTLP_ENDPOINT and/or TRACE_FILE.
+	tracer, err := tracing.FromEnv("skein-proxy")
+	if err != nil {
+		slog.Error("failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+	p.SetTracer(tracer)
+
+	// Workers register with WORKER_SECRET or their own token from WORKER_TOKENS_FILE.
+	if secret, file := os.Getenv("WORKER_SECRET"), os.Getenv("WORKER_TOKENS_FILE"); secret != "" || file != "" {
+		var tokens map[string]string
+		if file != "" {
+			var err error
+			if tokens, err = proxy.LoadWorkerTokens(file); err != nil {
+				slog.Error("failed to load worker tokens", "path", file, "error", err)
+				os.Exit(1)
+			}
+		}
+		p.RequireWorkerAuth(proxy.NewWorkerAuth(secret, tokens))
+	}
+
+	// User-facing endpoints, behind the authenticators of AUTH_CONFIG if it's set.
+	public := func(h http.HandlerFunc) http.Handler { return h }
+	if path := os.Getenv("AUTH_CONFIG"); path != "" {
+		authenticator, err := auth.LoadConfig(path)
+		if err != nil {
+			slog.Error("failed to load auth config", "path", path, "error", err)
+			os.Exit(1)
+		}
+		public = func(h http.HandlerFunc) http.Handler { return auth.Middleware(authenticator, h) }
+	}
+	http.Handle("/query", public(p.QueryHandler))
+	http.Handle("/jobs", public(p.SubmitJobHandler))
+	http.Handle("GET /jobs", public(p.ListJobsHandler))
+	http.Handle("/jobs/{id}", public(p.JobStatusHandler))
+	http.Handle("DELETE /jobs/{id}", public(p.CancelJobHandler))
+	http.Handle("/jobs/{id}/result", public(p.JobResultHandler))
+	http.Handle("/jobs/{id}/download", public(p.DownloadHandler))
+	http.Handle("GET /workers", public(p.ListWorkersHandler))
+	// The web console is static, it sends the credentials entered in it with every API request.
+	http.Handle("GET /console/", http.StripPrefix("/console", console.Handler()))
+	http.Handle("GET /{$}", http.RedirectHandler("/console/", http.StatusFound))
 	http.HandleFunc("/healthz", p.HealthCheckHandler)
+	http.HandleFunc("/metrics", p.MetricsHandler)
 
 	// Internal endpoints for worker communication.
 	http.HandleFunc("/internal/job/result", p.ResultHandler)
+	http.HandleFunc("/internal/job/stream", p.StreamResultHandler)
+	http.HandleFunc("/internal/job/export", p.ExportUploadHandler)
 	http.HandleFunc("/internal/job/next", p.JobDispatcherHandler)
+	http.HandleFunc("/internal/job/ack", p.AckJobHandler)
+	http.HandleFunc("/internal/job/cancel", p.CancelWatchHandler)
 	http.HandleFunc("/internal/worker/register", p.RegisterWorkerHandler)
 	http.HandleFunc("/internal/worker/heartbeat", p.HeartbeatHandler)
 	http.HandleFunc("/internal/worker/goodbye", p.DeregisterWorkerHandler)
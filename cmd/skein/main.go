@@ -0,0 +1,320 @@
+// Command skein runs queries through the skein proxy:
+//
+//	skein query -f trips.sql -param pax=2 -priority high
+//	skein query -format csv "SELECT * FROM trips LIMIT 10"
+//	skein jobs -status running
+//	skein workers
+//	skein            # interactive shell
+//
+// The proxy's address and credentials come from -url, -user, -api-key and
+// -token, or SKEIN_URL, SKEIN_USER, SKEIN_API_KEY and SKEIN_TOKEN.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"skein/client"
+	"skein/internal/api"
+	"strings"
+	"time"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+const usage = `Usage: skein [flags] [command] [command flags]
+
+Commands:
+  query    run a query given as an argument, with -f or on stdin
+  jobs     list recent jobs
+  workers  list the registered workers
+  repl     start the interactive shell, the default
+
+Flags:
+`
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("skein", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	baseURL := fs.String("url", envOr("SKEIN_URL", "http://localhost:8080"), "address of the proxy")
+	user := fs.String("user", envOr("SKEIN_USER", os.Getenv("USER")), "user ID of queries")
+	apiKey := fs.String("api-key", os.Getenv("SKEIN_API_KEY"), "API key")
+	token := fs.String("token", os.Getenv("SKEIN_TOKEN"), "bearer token")
+	timeout := fs.Duration("timeout", 5*time.Minute, "time limit of a query, 0 for none")
+	if err := fs.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+
+	c := client.New(*baseURL)
+	c.UserID, c.APIKey, c.Token = *user, *apiKey, *token
+
+	command, args := "repl", fs.Args()
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	var err error
+	switch command {
+	case "query":
+		err = queryCommand(c, *timeout, args, stdin, stdout, stderr)
+	case "jobs":
+		err = jobsCommand(c, args, stdout, stderr)
+	case "workers":
+		err = workersCommand(c, args, stdout, stderr)
+	case "repl":
+		r := newREPL(c, *timeout, stdin, stdout, stderr)
+		r.historyFile = historyFile()
+		r.prompt = isTerminal(stdin)
+		err = r.run(context.Background())
+	default:
+		fmt.Fprintf(stderr, "skein: unknown command %q\n", command)
+		fs.Usage()
+		return 2
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "skein:", err)
+		return 1
+	}
+	return 0
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// queryFlags are the settings of a query that the query command and the
+// REPL share.
+type queryFlags struct {
+	priority api.Priority
+	class    api.QueryClass
+	size     api.WorkerSize
+	params   params
+	format   string
+	// timeout limits how long a query may run, zero means no limit.
+	timeout time.Duration
+}
+
+func (f *queryFlags) request(query string) api.QueryRequest {
+	req := api.QueryRequest{Query: query, Priority: f.priority, Class: f.class, Size: f.size}
+	if len(f.params) > 0 {
+		req.Params = f.params
+	}
+	return req
+}
+
+// run submits the query as a job and waits for its result up to the timeout,
+// the proxy would cut a synchronous query off after 30s. The job is cancelled
+// when ctx is done or the timeout expires.
+func (f *queryFlags) run(ctx context.Context, c *client.Client, query string) (*api.QueryResults, error) {
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+	}
+	results, err := c.Run(ctx, f.request(query))
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("query didn't finish within %s, its job was cancelled", f.timeout)
+	}
+	return results, err
+}
+
+func queryCommand(c *client.Client, timeout time.Duration, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	qf := queryFlags{priority: api.PriorityNormal, params: params{}, timeout: timeout}
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	file := fs.String("f", "", "file with the query, - for stdin")
+	fs.Var(&qf.params, "param", "query parameter `name=value`, repeatable; values are JSON or strings")
+	fs.Func("priority", "low, normal, high or a number", func(s string) (err error) {
+		qf.priority, err = api.ParsePriority(s)
+		return err
+	})
+	fs.Func("class", "query class, user or system", func(s string) error {
+		qf.class = api.QueryClass(s)
+		return nil
+	})
+	fs.Func("size", "minimum worker size, small, medium or large", func(s string) error {
+		qf.size = api.WorkerSize(s)
+		if !qf.size.Valid() {
+			return fmt.Errorf("unknown worker size %q", s)
+		}
+		return nil
+	})
+	fs.StringVar(&qf.format, "format", formatTable, "output format, table, csv or json")
+	quiet := fs.Bool("q", false, "don't print the profiling stats")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !validFormat(qf.format) {
+		return fmt.Errorf("unknown format %q", qf.format)
+	}
+
+	var query string
+	switch {
+	case *file != "" && fs.NArg() > 0:
+		return errors.New("give the query either with -f or as an argument")
+	case *file == "-" || *file == "" && fs.NArg() == 0:
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		query = string(data)
+	case *file != "":
+		data, err := os.ReadFile(*file)
+		if err != nil {
+			return err
+		}
+		query = string(data)
+	default:
+		query = strings.Join(fs.Args(), " ")
+	}
+	if strings.TrimSpace(query) == "" {
+		return errors.New("empty query")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	results, err := qf.run(ctx, c, query)
+	if err != nil {
+		return err
+	}
+	if err := writeResults(stdout, results, qf.format); err != nil {
+		return err
+	}
+	if !*quiet {
+		writeProfile(stderr, results)
+	}
+	return nil
+}
+
+func jobsCommand(c *client.Client, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("jobs", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var filter client.JobFilter
+	fs.StringVar(&filter.UserID, "user", "", "only list the jobs of this user")
+	fs.Func("status", "only list jobs with this status", func(s string) error {
+		filter.Status = api.JobStatus(s)
+		return nil
+	})
+	fs.IntVar(&filter.Limit, "limit", 0, "maximum number of jobs")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return listJobs(context.Background(), c, filter, stdout)
+}
+
+func workersCommand(c *client.Client, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("workers", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return listWorkers(context.Background(), c, stdout)
+}
+
+func listJobs(ctx context.Context, c *client.Client, filter client.JobFilter, w io.Writer) error {
+	jobs, err := c.Jobs(ctx, filter)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, len(jobs))
+	for i, job := range jobs {
+		query := []rune(strings.Join(strings.Fields(job.Query), " "))
+		if len(query) > 60 {
+			query = append(query[:57], []rune("...")...)
+		}
+		rows[i] = []string{
+			job.ID, job.UserID, string(job.Status), fmt.Sprint(job.Priority),
+			job.CreatedAt.Local().Format(time.DateTime), job.WorkerID, string(query),
+		}
+	}
+	writeTable(w, []string{"id", "user", "status", "priority", "created", "worker", "query"}, rows,
+		[]bool{false, false, false, true})
+	return nil
+}
+
+func listWorkers(ctx context.Context, c *client.Client, w io.Writer) error {
+	workers, err := c.Workers(ctx)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, len(workers))
+	for i, worker := range workers {
+		ready := "no"
+		if worker.Ready {
+			ready = "yes"
+		}
+		heartbeat := "-"
+		if !worker.LastHeartbeat.IsZero() {
+			heartbeat = time.Since(worker.LastHeartbeat).Round(time.Second).String()
+		}
+		rows[i] = []string{
+			worker.ID, worker.Name, string(worker.Capacity.Size), fmt.Sprint(worker.Running), fmt.Sprint(worker.Slots),
+			ready, heartbeat,
+		}
+	}
+	writeTable(w, []string{"id", "name", "size", "running", "slots", "ready", "last heartbeat"}, rows,
+		[]bool{false, false, false, true, true})
+	return nil
+}
+
+// params collects -param name=value flags. A value is sent as JSON if it is
+// JSON, like 2 or true, and as a string otherwise.
+type params map[string]any
+
+func (p params) String() string {
+	names := make([]string, 0, len(p))
+	for name, v := range p {
+		names = append(names, fmt.Sprintf("%s=%v", name, v))
+	}
+	return strings.Join(names, " ")
+}
+
+func (p params) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("parameter %q isn't name=value", s)
+	}
+	p[name] = parseParam(value)
+	return nil
+}
+
+func parseParam(value string) any {
+	dec := json.NewDecoder(strings.NewReader(value))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return value
+	}
+	// Like 2019-04-15, which starts with a number.
+	if _, err := dec.Token(); err != io.EOF {
+		return value
+	}
+	return v
+}
+
+func isTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"skein/internal/api"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Output formats of query results.
+const (
+	formatTable = "table"
+	formatCSV   = "csv"
+	formatJSON  = "json"
+)
+
+func validFormat(f string) bool {
+	return f == formatTable || f == formatCSV || f == formatJSON
+}
+
+// writeResults writes a query's result in the given format.
+func writeResults(w io.Writer, results *api.QueryResults, format string) error {
+	switch format {
+	case formatCSV:
+		return writeCSV(w, results)
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+	header, rows := resultRows(results, "NULL")
+	right := make([]bool, len(results.ColumnTypes))
+	for i, col := range results.ColumnTypes {
+		right[i] = isNumeric(col.Type)
+	}
+	writeTable(w, header, rows, right)
+	if len(rows) == 1 {
+		fmt.Fprintln(w, "(1 row)")
+	} else {
+		fmt.Fprintf(w, "(%d rows)\n", len(rows))
+	}
+	return nil
+}
+
+func writeCSV(w io.Writer, results *api.QueryResults) error {
+	header, rows := resultRows(results, "")
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	cw.WriteAll(rows)
+	return cw.Error()
+}
+
+// resultRows formats the values of a result row by row, NULLs as null.
+func resultRows(results *api.QueryResults, null string) ([]string, [][]string) {
+	columns := make([]api.Column, len(results.ColumnData))
+	n := 0
+	for i, data := range results.ColumnData {
+		columns[i] = api.AsColumn(data)
+		n = max(n, columns[i].Len())
+	}
+	rows := make([][]string, n)
+	for r := range rows {
+		rows[r] = make([]string, len(columns))
+		for c, col := range columns {
+			if r >= col.Len() || col.IsNull(r) {
+				rows[r][c] = null
+				continue
+			}
+			rows[r][c] = formatValue(col.Value(r))
+		}
+	}
+	return results.ColumnNames, rows
+}
+
+// writeTable writes rows as a table with aligned columns, right aligned where
+// right is true.
+func writeTable(w io.Writer, header []string, rows [][]string, right []bool) {
+	widths := make([]int, len(header))
+	for i, h := range header {
+		widths[i] = utf8.RuneCountInString(h)
+	}
+	for _, row := range rows {
+		for i, v := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(v))
+		}
+	}
+
+	var b strings.Builder
+	line := func(cells []string, alignRight bool) {
+		b.Reset()
+		for i, v := range cells {
+			if i > 0 {
+				b.WriteString(" | ")
+			}
+			pad := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(v))
+			if alignRight && i < len(right) && right[i] {
+				b.WriteString(pad + v)
+			} else if i < len(cells)-1 {
+				b.WriteString(v + pad)
+			} else {
+				b.WriteString(v)
+			}
+		}
+		fmt.Fprintln(w, b.String())
+	}
+	line(header, false)
+	separator := make([]string, len(widths))
+	for i, width := range widths {
+		separator[i] = strings.Repeat("-", width)
+	}
+	fmt.Fprintln(w, strings.Join(separator, "-+-"))
+	for _, row := range rows {
+		line(row, true)
+	}
+}
+
+func isNumeric(columnType string) bool {
+	t, err := api.ParseType(columnType)
+	if err != nil {
+		return false
+	}
+	switch t.ID {
+	case "TINYINT", "SMALLINT", "INTEGER", "BIGINT", "HUGEINT",
+		"UTINYINT", "USMALLINT", "UINTEGER", "UBIGINT", "UHUGEINT",
+		"FLOAT", "DOUBLE", "DECIMAL":
+		return true
+	}
+	return false
+}
+
+// formatValue formats a decoded value the way DuckDB's shell prints it,
+// nested values as JSON.
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return v
+	case bool, int8, int16, int32, int64, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	case *big.Int:
+		return v.String()
+	case api.Date:
+		return v.Format(time.DateOnly)
+	case time.Time:
+		if v.Location() == time.UTC {
+			return v.Format("2006-01-02 15:04:05.999999999")
+		}
+		return v.Format("2006-01-02 15:04:05.999999999-07:00")
+	case []byte:
+		return `\x` + hex.EncodeToString(v)
+	case fmt.Stringer:
+		return v.String()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// writeProfile writes the profiling stats of a result on one line.
+func writeProfile(w io.Writer, results *api.QueryResults) {
+	p := results.Profile
+	fmt.Fprintf(w, "%d rows, latency %s, cpu %s, read %s, written %s",
+		p.RowsReturned, seconds(p.Latency), seconds(p.CPUTime),
+		formatBytes(p.TotalBytesRead), formatBytes(p.TotalBytesWritten))
+	if results.CacheHit {
+		fmt.Fprint(w, ", cached")
+	}
+	if results.JobID != "" {
+		fmt.Fprintf(w, ", job %s", results.JobID)
+	}
+	fmt.Fprintln(w)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Microsecond)
+}
+
+func formatBytes(n int) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := unit, 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"skein/internal/api"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testResults(t *testing.T) *api.QueryResults {
+	t.Helper()
+	var results api.QueryResults
+	require.NoError(t, json.Unmarshal([]byte(`{"job_id":"j1",
+		"column_names":["vendor","pax","fare","day"],
+		"column_types":[{"type":"VARCHAR"},{"type":"INTEGER"},{"type":"DECIMAL(6,2)"},{"type":"DATE"}],
+		"column_data":[["CMT","Verifone, Inc."],[2,null],["12.50","7.00"],["2019-04-15","2019-04-16"]],
+		"profile":{"rows_returned":2,"latency":0.0125,"cpu_time":0.002,"total_bytes_read":1536}}`), &results))
+	return &results
+}
+
+func TestWriteResults_Table(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, writeResults(&out, testResults(t), formatTable))
+	assert.Equal(t, ""+
+		"vendor         | pax  | fare  | day\n"+
+		"---------------+------+-------+-----------\n"+
+		"CMT            |    2 | 12.50 | 2019-04-15\n"+
+		"Verifone, Inc. | NULL |  7.00 | 2019-04-16\n"+
+		"(2 rows)\n", out.String())
+}
+
+func TestWriteResults_CSV(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, writeResults(&out, testResults(t), formatCSV))
+	assert.Equal(t, "vendor,pax,fare,day\nCMT,2,12.50,2019-04-15\n\"Verifone, Inc.\",,7.00,2019-04-16\n", out.String())
+}
+
+func TestWriteResults_JSON(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, writeResults(&out, testResults(t), formatJSON))
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &got))
+	assert.Equal(t, []any{[]any{"CMT", "Verifone, Inc."}, []any{float64(2), nil}, []any{"12.50", "7.00"}, []any{"2019-04-15", "2019-04-16"}},
+		got["column_data"])
+}
+
+func TestWriteProfile(t *testing.T) {
+	var out bytes.Buffer
+	writeProfile(&out, testResults(t))
+	assert.Equal(t, "2 rows, latency 12.5ms, cpu 2ms, read 1.5 KiB, written 0 B, job j1\n", out.String())
+}
+
+func TestFormatValue(t *testing.T) {
+	assert.Equal(t, "NULL", formatValue(nil))
+	assert.Equal(t, "2024-01-02 03:04:05.5", formatValue(time.Date(2024, 1, 2, 3, 4, 5, 5e8, time.UTC)))
+	assert.Equal(t, `\x0aff`, formatValue([]byte{0x0a, 0xff}))
+	assert.Equal(t, "01:02:03", formatValue(api.TimeOfDay(time.Hour+2*time.Minute+3*time.Second)))
+	assert.Equal(t, `[1,null]`, formatValue([]any{int32(1), nil}))
+	assert.Equal(t, `{"a":"b"}`, formatValue(map[string]any{"a": "b"}))
+}
+
+func TestParseParam(t *testing.T) {
+	assert.Equal(t, json.Number("2"), parseParam("2"))
+	assert.Equal(t, true, parseParam("true"))
+	assert.Equal(t, "2019-04-15", parseParam("2019-04-15"))
+	assert.Equal(t, "CMT", parseParam("CMT"))
+	assert.Equal(t, "quoted", parseParam(`"quoted"`))
+	assert.Equal(t, []any{json.Number("1"), json.Number("2")}, parseParam("[1,2]"))
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"skein/client"
+	"skein/internal/api"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxHistory is the number of statements the history file keeps.
+const maxHistory = 1000
+
+const replHelp = `Statements end with a semicolon and can span lines. Ctrl-C cancels a running query.
+
+  \jobs [status]          list recent jobs
+  \workers                list the registered workers
+  \cancel <job id>        cancel a job
+  \format table|csv|json  set the output format
+  \priority <priority>    set the priority: low, normal, high or a number
+  \class user|system      set the query class
+  \set <name> <value>     set a query parameter, used as $name
+  \unset <name>           remove a query parameter
+  \params                 list the query parameters
+  \profile on|off         print the profiling stats after results
+  \history                list the previous statements
+  !<n>                    run statement n of the history again
+  \help                   show this help
+  \q                      quit
+`
+
+// repl is the interactive shell: it reads statements and meta-commands from
+// in and runs them until \q or the end of in.
+type repl struct {
+	client  *client.Client
+	in      *bufio.Scanner
+	out     io.Writer
+	errOut  io.Writer
+	flags   queryFlags
+	profile bool
+	// prompt is whether to print prompts, only when in is a terminal.
+	prompt bool
+	// history holds the previous statements, historyFile persists them if
+	// it's set.
+	history     []string
+	historyFile string
+}
+
+func newREPL(c *client.Client, timeout time.Duration, in io.Reader, out, errOut io.Writer) *repl {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 1<<20)
+	return &repl{
+		client:  c,
+		in:      scanner,
+		out:     out,
+		errOut:  errOut,
+		flags:   queryFlags{priority: api.PriorityNormal, params: params{}, format: formatTable, timeout: timeout},
+		profile: true,
+	}
+}
+
+var errQuit = errors.New("quit")
+
+func (r *repl) run(ctx context.Context) error {
+	r.loadHistory()
+	if r.prompt {
+		fmt.Fprintf(r.out, "Connected to %s, \\help for help.\n", r.client.BaseURL)
+	}
+	var statement strings.Builder
+	for {
+		if r.prompt {
+			if statement.Len() == 0 {
+				fmt.Fprint(r.out, "skein> ")
+			} else {
+				fmt.Fprint(r.out, "   ...> ")
+			}
+		}
+		if !r.in.Scan() {
+			if r.prompt {
+				fmt.Fprintln(r.out)
+			}
+			return r.in.Err()
+		}
+		line := r.in.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if statement.Len() == 0 {
+			if trimmed == "" {
+				continue
+			}
+			if strings.HasPrefix(trimmed, `\`) || strings.HasPrefix(trimmed, "!") {
+				if err := r.command(ctx, trimmed); errors.Is(err, errQuit) {
+					return nil
+				} else if err != nil {
+					fmt.Fprintln(r.errOut, "Error:", err)
+				}
+				continue
+			}
+		} else {
+			statement.WriteByte('\n')
+		}
+		statement.WriteString(line)
+		if strings.HasSuffix(trimmed, ";") {
+			query := strings.TrimSpace(statement.String())
+			statement.Reset()
+			r.addHistory(query)
+			r.query(ctx, query)
+		}
+	}
+}
+
+// query runs a statement, Ctrl-C cancels it instead of ending the shell.
+func (r *repl) query(ctx context.Context, query string) {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	results, err := r.flags.run(ctx, r.client, query)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			err = errors.New("query cancelled")
+		}
+		fmt.Fprintln(r.errOut, "Error:", err)
+		return
+	}
+	if err := writeResults(r.out, results, r.flags.format); err != nil {
+		fmt.Fprintln(r.errOut, "Error:", err)
+		return
+	}
+	if r.profile {
+		writeProfile(r.errOut, results)
+	}
+}
+
+// command runs a meta-command.
+func (r *repl) command(ctx context.Context, line string) error {
+	if n, ok := strings.CutPrefix(line, "!"); ok {
+		i, err := strconv.Atoi(n)
+		if err != nil || i < 1 || i > len(r.history) {
+			return fmt.Errorf("no statement %s in the history", n)
+		}
+		query := r.history[i-1]
+		fmt.Fprintln(r.out, query)
+		r.addHistory(query)
+		r.query(ctx, query)
+		return nil
+	}
+
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case `\q`, `\quit`:
+		return errQuit
+	case `\help`, `\?`:
+		fmt.Fprint(r.out, replHelp)
+	case `\jobs`:
+		return listJobs(ctx, r.client, client.JobFilter{Status: api.JobStatus(arg)}, r.out)
+	case `\workers`:
+		return listWorkers(ctx, r.client, r.out)
+	case `\cancel`:
+		if arg == "" {
+			return errors.New(`usage: \cancel <job id>`)
+		}
+		if err := r.client.Cancel(ctx, arg); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "Cancelled %s.\n", arg)
+	case `\format`:
+		if !validFormat(arg) {
+			return fmt.Errorf("unknown format %q, use table, csv or json", arg)
+		}
+		r.flags.format = arg
+	case `\priority`:
+		priority, err := api.ParsePriority(arg)
+		if err != nil {
+			return err
+		}
+		r.flags.priority = priority
+	case `\class`:
+		r.flags.class = api.QueryClass(arg)
+	case `\set`:
+		name, value, ok := strings.Cut(arg, " ")
+		if !ok || name == "" {
+			return errors.New(`usage: \set <name> <value>`)
+		}
+		r.flags.params[name] = parseParam(strings.TrimSpace(value))
+	case `\unset`:
+		delete(r.flags.params, arg)
+	case `\params`:
+		rows := make([][]string, 0, len(r.flags.params))
+		for _, name := range slices.Sorted(maps.Keys(r.flags.params)) {
+			rows = append(rows, []string{name, formatValue(r.flags.params[name])})
+		}
+		writeTable(r.out, []string{"name", "value"}, rows, nil)
+	case `\profile`:
+		r.profile = arg != "off"
+	case `\history`:
+		for i, query := range r.history {
+			fmt.Fprintf(r.out, "%4d  %s\n", i+1, query)
+		}
+	default:
+		return fmt.Errorf(`unknown command %s, \help lists the commands`, name)
+	}
+	return nil
+}
+
+// historyFile is ~/.skein_history.
+func historyFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".skein_history")
+}
+
+// loadHistory reads the history file, which has a quoted statement per line.
+func (r *repl) loadHistory() {
+	if r.historyFile == "" {
+		return
+	}
+	data, err := os.ReadFile(r.historyFile)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if query, err := strconv.Unquote(line); err == nil {
+			r.history = append(r.history, query)
+		}
+	}
+	if len(r.history) > maxHistory {
+		r.history = r.history[len(r.history)-maxHistory:]
+		var b strings.Builder
+		for _, query := range r.history {
+			b.WriteString(strconv.Quote(query) + "\n")
+		}
+		os.WriteFile(r.historyFile, []byte(b.String()), 0o600)
+	}
+}
+
+func (r *repl) addHistory(query string) {
+	if n := len(r.history); n > 0 && r.history[n-1] == query {
+		return
+	}
+	r.history = append(r.history, query)
+	if r.historyFile == "" {
+		return
+	}
+	f, err := os.OpenFile(r.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		fmt.Fprintln(r.errOut, "Error: saving history:", err)
+		r.historyFile = ""
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, strconv.Quote(query))
+}
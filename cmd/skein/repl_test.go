@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"skein/client"
+	"skein/internal/api"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProxy runs every job right away, its result is the query's text, and
+// records the requests. Jobs of queries containing "slow" never finish.
+type fakeProxy struct {
+	mu        sync.Mutex
+	requests  []api.QueryRequest
+	cancelled []string
+}
+
+func newFakeProxy(t *testing.T) (*fakeProxy, *client.Client) {
+	t.Helper()
+	p := &fakeProxy{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /query", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("queries must not go through the synchronous endpoint, the proxy cuts it off after 30s")
+	})
+	mux.HandleFunc("POST /jobs", func(w http.ResponseWriter, r *http.Request) {
+		var req api.QueryRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		p.mu.Lock()
+		p.requests = append(p.requests, req)
+		jobID := fmt.Sprintf("j%d", len(p.requests))
+		p.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(api.QueryResponse{JobID: jobID})
+	})
+	mux.HandleFunc("GET /jobs/{id}/result", func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(r.PathValue("id"), "j%d", &n)
+		p.mu.Lock()
+		req := p.requests[n-1]
+		p.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(req.Query, "slow"):
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(api.Job{ID: r.PathValue("id"), Status: api.StatusRunning})
+		case strings.Contains(req.Query, "nope"):
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.QueryResults{Error: "Catalog Error: Table with name nope does not exist!"})
+		default:
+			json.NewEncoder(w).Encode(api.QueryResults{
+				JobID:       r.PathValue("id"),
+				ColumnNames: []string{"query"},
+				ColumnTypes: []api.ColumnType{{Type: "VARCHAR"}},
+				ColumnData:  []any{[]string{req.Query}},
+				Profile:     api.ProfilingStats{RowsReturned: 1},
+			})
+		}
+	})
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]api.Job{{ID: "j1", UserID: "alice", Query: "SELECT\n  1", Status: api.JobStatus(r.URL.Query().Get("status"))}})
+	})
+	mux.HandleFunc("GET /workers", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]api.WorkerInfo{{ID: "w1", Slots: 4, Running: 1, Ready: true}})
+	})
+	mux.HandleFunc("DELETE /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.cancelled = append(p.cancelled, r.PathValue("id"))
+		p.mu.Unlock()
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	c := client.New(server.URL)
+	c.UserID = "alice"
+	c.PollInterval = time.Millisecond
+	return p, c
+}
+
+func TestREPL(t *testing.T) {
+	p, c := newFakeProxy(t)
+	var out, errOut bytes.Buffer
+	r := newREPL(c, time.Minute, strings.NewReader(`
+SELECT *
+FROM trips
+WHERE pax = $pax;
+\set pax 2
+\priority high
+\format csv
+SELECT 1;
+\jobs running
+\workers
+\cancel j7
+\params
+\history
+!1
+SELECT * FROM nope;
+\bogus
+\q
+SELECT 'not run';
+`), &out, &errOut)
+	r.historyFile = filepath.Join(t.TempDir(), "history")
+	require.NoError(t, r.run(context.Background()))
+
+	require.Len(t, p.requests, 4)
+	assert.Equal(t, "SELECT *\nFROM trips\nWHERE pax = $pax;", p.requests[0].Query)
+	assert.Equal(t, api.PriorityNormal, p.requests[0].Priority)
+	assert.Nil(t, p.requests[0].Params)
+	assert.Equal(t, "SELECT 1;", p.requests[1].Query)
+	assert.Equal(t, api.PriorityHigh, p.requests[1].Priority)
+	assert.Equal(t, map[string]any{"pax": float64(2)}, p.requests[1].Params)
+	assert.Equal(t, p.requests[0].Query, p.requests[2].Query)
+	assert.Equal(t, []string{"j7"}, p.cancelled)
+
+	output := out.String()
+	assert.Contains(t, output, "query\n---")
+	assert.Contains(t, output, "query\nSELECT 1;\n")
+	assert.Contains(t, output, "j1 | alice | running")
+	assert.Contains(t, output, "| SELECT 1\n")
+	assert.Contains(t, output, "w1 |      |      |       1 |     4 | yes   | -\n")
+	assert.Contains(t, output, "Cancelled j7.")
+	assert.Contains(t, output, "pax  | 2")
+	assert.Contains(t, output, "   2  SELECT 1;\n")
+	assert.NotContains(t, output, "not run")
+	assert.Contains(t, errOut.String(), "1 rows, latency")
+	assert.Contains(t, errOut.String(), "Catalog Error: Table with name nope does not exist!")
+	assert.Contains(t, errOut.String(), `Error: unknown command \bogus`)
+
+	history, err := os.ReadFile(r.historyFile)
+	require.NoError(t, err)
+	assert.Equal(t, `"SELECT *\nFROM trips\nWHERE pax = $pax;"`+"\n"+`"SELECT 1;"`+"\n"+
+		`"SELECT *\nFROM trips\nWHERE pax = $pax;"`+"\n"+`"SELECT * FROM nope;"`+"\n", string(history))
+
+	out.Reset()
+	reopened := newREPL(c, time.Minute, strings.NewReader("\\history\n"), &out, &errOut)
+	reopened.historyFile = r.historyFile
+	require.NoError(t, reopened.run(context.Background()))
+	assert.Equal(t, "   1  SELECT *\nFROM trips\nWHERE pax = $pax;\n   2  SELECT 1;\n"+
+		"   3  SELECT *\nFROM trips\nWHERE pax = $pax;\n   4  SELECT * FROM nope;\n", out.String())
+}
+
+func TestREPLSettings(t *testing.T) {
+	p, c := newFakeProxy(t)
+	var out, errOut bytes.Buffer
+	r := newREPL(c, 50*time.Millisecond, strings.NewReader(`\help
+\priority low
+\class system
+\set day 2019-04-15
+\set pax 2
+\unset pax
+\profile off
+\format json
+SELECT 1;
+\priority 7
+\priority urgent
+\format xml
+\cancel
+\set pax
+!9
+SELECT slow;
+`), &out, &errOut)
+	require.NoError(t, r.run(context.Background()))
+
+	require.Len(t, p.requests, 2)
+	assert.Equal(t, api.PriorityLow, p.requests[0].Priority)
+	assert.Equal(t, api.ClassSystem, p.requests[0].Class)
+	assert.Equal(t, map[string]any{"day": "2019-04-15"}, p.requests[0].Params)
+	assert.Equal(t, api.Priority(7), p.requests[1].Priority)
+	assert.Equal(t, []string{"j2"}, p.cancelled, "the job of a timed out query is cancelled")
+
+	assert.Contains(t, out.String(), `\jobs [status]`)
+	assert.Contains(t, out.String(), `"column_names": [`)
+	messages := errOut.String()
+	assert.NotContains(t, messages, "rows, latency", "profiling stats are off")
+	assert.Contains(t, messages, `"urgent"`)
+	assert.Contains(t, messages, `unknown format "xml"`)
+	assert.Contains(t, messages, `usage: \cancel <job id>`)
+	assert.Contains(t, messages, `usage: \set <name> <value>`)
+	assert.Contains(t, messages, "no statement 9 in the history")
+	assert.Contains(t, messages, "query didn't finish within 50ms, its job was cancelled")
+}
+
+func TestQueryCommand(t *testing.T) {
+	p, c := newFakeProxy(t)
+	file := filepath.Join(t.TempDir(), "trips.sql")
+	require.NoError(t, os.WriteFile(file, []byte("SELECT * FROM trips WHERE pax = $pax"), 0o600))
+
+	var out, errOut bytes.Buffer
+	err := queryCommand(c, time.Minute, []string{"-f", file, "-param", "pax=2", "--param", "day=2019-04-15", "--priority", "high", "-format", "json"},
+		nil, &out, &errOut)
+	require.NoError(t, err)
+	require.Len(t, p.requests, 1)
+	assert.Equal(t, "SELECT * FROM trips WHERE pax = $pax", p.requests[0].Query)
+	assert.Equal(t, map[string]any{"pax": float64(2), "day": "2019-04-15"}, p.requests[0].Params)
+	assert.Equal(t, api.PriorityHigh, p.requests[0].Priority)
+	assert.Contains(t, out.String(), `"column_names": [`)
+	assert.Contains(t, errOut.String(), "1 rows")
+
+	out.Reset()
+	require.NoError(t, queryCommand(c, time.Minute, []string{"-q"}, strings.NewReader("SELECT 2"), &out, &errOut))
+	assert.Equal(t, "SELECT 2", p.requests[1].Query)
+	assert.Contains(t, out.String(), "(1 row)")
+
+	require.NoError(t, queryCommand(c, time.Minute, []string{"--priority", "3", "-class", "system", "-size", "large", "SELECT 3"}, nil, &out, &errOut))
+	assert.Equal(t, api.Priority(3), p.requests[2].Priority)
+	assert.Equal(t, api.ClassSystem, p.requests[2].Class)
+	assert.Equal(t, api.SizeLarge, p.requests[2].Size)
+
+	err = queryCommand(c, 20*time.Millisecond, []string{"SELECT slow"}, nil, &out, &errOut)
+	assert.ErrorContains(t, err, "didn't finish within 20ms")
+	assert.Equal(t, []string{"j4"}, p.cancelled)
+
+	assert.ErrorContains(t, queryCommand(c, time.Minute, []string{"SELECT * FROM nope"}, nil, &out, &errOut), "nope does not exist")
+	assert.Error(t, queryCommand(c, time.Minute, []string{"-priority", "urgent", "SELECT 1"}, nil, &out, &errOut))
+	assert.Error(t, queryCommand(c, time.Minute, []string{"-format", "xml", "SELECT 1"}, nil, &out, &errOut))
+	assert.Error(t, queryCommand(c, time.Minute, []string{"-param", "pax", "SELECT 1"}, nil, &out, &errOut))
+}
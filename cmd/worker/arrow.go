@@ -0,0 +1,99 @@
+//go:build duckdb_arrow
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"skein/internal/api"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+// arrowSupported is registered with the proxy, which only sends arrow jobs to
+// workers that support them.
+const arrowSupported = true
+
+// runArrowQuery runs the job's query through DuckDB's Arrow interface and
+// returns the record batches as an Arrow IPC stream.
+func runArrowQuery(ctx context.Context, db *sql.Conn, job *api.Job) (*api.JobResult, error) {
+	var buf bytes.Buffer
+	start := time.Now()
+	err := db.Raw(func(driverConn any) error {
+		conn, ok := driverConn.(*duckdb.Conn)
+		if !ok {
+			return errors.New("not a duckdb connection")
+		}
+		args, err := positionalArgs(ctx, conn, job)
+		if err != nil {
+			return err
+		}
+		ar, err := duckdb.NewArrowFromConn(conn)
+		if err != nil {
+			return fmt.Errorf("failed to open arrow interface: %w", err)
+		}
+
+		reader, err := ar.QueryContext(ctx, job.Query, args...)
+		if err != nil {
+			return fmt.Errorf("query execution failed: %w", err)
+		}
+		defer reader.Release()
+
+		writer := ipc.NewWriter(&buf, ipc.WithSchema(reader.Schema()))
+		for reader.Next() {
+			if err := writer.Write(reader.RecordBatch()); err != nil {
+				return fmt.Errorf("failed to write record batch: %w", err)
+			}
+		}
+		if err := reader.Err(); err != nil {
+			return fmt.Errorf("error during record batch iteration: %w", err)
+		}
+		return writer.Close()
+	})
+	if err != nil {
+		return nil, err
+	}
+	duration := time.Since(start)
+	slog.Info("query execution completed", "duration_ms", duration.Milliseconds(), "arrow_bytes", buf.Len())
+
+	return &api.JobResult{
+		Arrow: buf.Bytes(),
+		GoProfile: api.GoProfileStats{
+			QueryTime: duration,
+		},
+	}, nil
+}
+
+// positionalArgs orders the job's named parameters the way the query expects
+// them, DuckDB's Arrow interface binds arguments by position only.
+func positionalArgs(ctx context.Context, conn *duckdb.Conn, job *api.Job) ([]any, error) {
+	if len(job.Params) == 0 {
+		return nil, nil
+	}
+	stmt, err := conn.PrepareContext(ctx, job.Query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer stmt.Close()
+
+	duckStmt := stmt.(*duckdb.Stmt)
+	args := make([]any, duckStmt.NumInput())
+	for i := range args {
+		name, err := duckStmt.ParamName(i + 1)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get parameter name: %w", err)
+		}
+		value, ok := job.Params[name]
+		if !ok {
+			return nil, fmt.Errorf("missing value for parameter %q", name)
+		}
+		args[i] = value
+	}
+	return args, nil
+}
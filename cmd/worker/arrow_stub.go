@@ -0,0 +1,20 @@
+//go:build !duckdb_arrow
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"skein/internal/api"
+)
+
+// arrowSupported is registered with the proxy, which only sends arrow jobs to
+// workers that support them.
+const arrowSupported = false
+
+// runArrowQuery needs DuckDB's Arrow interface, which duckdb-go only builds
+// with the duckdb_arrow tag.
+func runArrowQuery(ctx context.Context, db *sql.Conn, job *api.Job) (*api.JobResult, error) {
+	return nil, errors.New("arrow results need a worker built with -tags duckdb_arrow")
+}
@@ -0,0 +1,66 @@
+//go:build duckdb_arrow
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"skein/internal/api"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteJobArrow(t *testing.T) {
+	job := &api.Job{
+		ID:     "test-job-arrow",
+		Query:  "SELECT range AS n, 'row ' || range AS label FROM range(5) WHERE range >= $min AND range < $max",
+		Params: map[string]interface{}{"max": 4, "min": 1},
+		Format: api.FormatArrow,
+	}
+
+	result, err := ExecuteJob(context.Background(), openTestDB(t), job)
+	require.NoError(t, err)
+	require.NotEmpty(t, result.Arrow)
+	assert.Empty(t, result.ColumnData)
+	assert.NotEmpty(t, result.Profile)
+
+	reader, err := ipc.NewReader(bytes.NewReader(result.Arrow))
+	require.NoError(t, err)
+	defer reader.Release()
+
+	schema := reader.Schema()
+	require.Equal(t, 2, schema.NumFields())
+	assert.Equal(t, "n", schema.Field(0).Name)
+	assert.Equal(t, "label", schema.Field(1).Name)
+
+	var (
+		numbers []int64
+		labels  []string
+	)
+	for reader.Next() {
+		rec := reader.RecordBatch()
+		numbers = append(numbers, rec.Column(0).(*array.Int64).Int64Values()...)
+		for i := 0; i < int(rec.NumRows()); i++ {
+			labels = append(labels, rec.Column(1).ValueStr(i))
+		}
+	}
+	require.NoError(t, reader.Err())
+	assert.Equal(t, []int64{1, 2, 3}, numbers)
+	assert.Equal(t, []string{"row 1", "row 2", "row 3"}, labels)
+}
+
+func TestExecuteJobArrowMissingParam(t *testing.T) {
+	job := &api.Job{
+		ID:               "test-job-arrow-param",
+		Query:            "SELECT $missing AS x",
+		Params:           map[string]interface{}{"other": 1},
+		Format:           api.FormatArrow,
+		DisableProfiling: true,
+	}
+	_, err := ExecuteJob(context.Background(), openTestDB(t), job)
+	assert.ErrorContains(t, err, `"missing"`)
+}
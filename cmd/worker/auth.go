@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+)
+
+// credentials adds the worker's bearer token to its requests to the proxy:
+// the WORKER_TOKEN it registers with, then the token the proxy issued at
+// registration.
+type credentials struct {
+	mu    sync.RWMutex
+	token string
+	base  http.RoundTripper
+}
+
+var workerCredentials = &credentials{base: http.DefaultTransport}
+
+func (c *credentials) set(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// RoundTrip implements http.RoundTripper.
+func (c *credentials) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.RLock()
+	token := c.token
+	c.mu.RUnlock()
+	if token == "" {
+		return c.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return c.base.RoundTrip(req)
+}
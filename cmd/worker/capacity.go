@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var memoryUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	// Longer suffixes first, "GiB" must not be matched as "B".
+	{"KIB", 1 << 10},
+	{"MIB", 1 << 20},
+	{"GIB", 1 << 30},
+	{"TIB", 1 << 40},
+	{"KB", 1000},
+	{"MB", 1000 * 1000},
+	{"GB", 1000 * 1000 * 1000},
+	{"TB", 1000 * 1000 * 1000 * 1000},
+	{"B", 1},
+}
+
+// parseMemoryLimit parses a memory size such as "512MB" or "4GiB" into bytes,
+// using the same units as DuckDB's memory_limit setting.
+func parseMemoryLimit(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	for _, unit := range memoryUnits {
+		if number, ok := strings.CutSuffix(v, unit.suffix); ok {
+			n, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid memory limit %q", s)
+			}
+			return int64(n * float64(unit.bytes)), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid memory limit %q: missing unit", s)
+}
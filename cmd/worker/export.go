@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"skein/internal/api"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runExport writes the result of the job's query to a local file with
+// COPY ... TO, the file is uploaded to the proxy by uploadExport.
+func runExport(ctx context.Context, db *sql.Conn, job *api.Job) (*api.JobResult, error) {
+	query := strings.TrimRight(strings.TrimSpace(job.Query), ";")
+	stmt := fmt.Sprintf("COPY (%s) TO '%s' (%s)", query, exportPath(job), copyOptions(job))
+
+	start := time.Now()
+	res, err := db.ExecContext(ctx, stmt, queryArgs(job)...)
+	if err != nil {
+		return nil, fmt.Errorf("export failed: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exported row count: %w", err)
+	}
+	info, err := os.Stat(exportPath(job))
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat exported file: %w", err)
+	}
+	duration := time.Since(start)
+	slog.Info("query export completed", "duration_ms", duration.Milliseconds(), "rows", rows, "size_bytes", info.Size())
+
+	return &api.JobResult{
+		Export: &api.ExportFile{
+			Format:    job.Format,
+			FileName:  job.ExportFileName(),
+			SizeBytes: info.Size(),
+			Rows:      rows,
+		},
+		GoProfile: api.GoProfileStats{
+			QueryTime: duration,
+		},
+	}, nil
+}
+
+// exportPath returns the local spool path of the job's exported file.
+func exportPath(job *api.Job) string {
+	return filepath.Join(os.TempDir(), job.ExportFileName())
+}
+
+// copyOptions returns the COPY options for the job's format. The options
+// were validated by the proxy.
+func copyOptions(job *api.Job) string {
+	var opts api.ExportOptions
+	if job.ExportOptions != nil {
+		opts = *job.ExportOptions
+	}
+	parts := []string{"FORMAT " + string(job.Format)}
+	if opts.Compression != "" {
+		parts = append(parts, "COMPRESSION "+opts.Compression)
+	}
+	if job.Format == api.FormatCSV {
+		if opts.Delimiter != "" {
+			parts = append(parts, "DELIMITER '"+strings.ReplaceAll(opts.Delimiter, "'", "''")+"'")
+		}
+		parts = append(parts, "HEADER "+strconv.FormatBool(opts.Header == nil || *opts.Header))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// uploadExport sends the job's exported file to the proxy.
+func (w *Worker) uploadExport(job *api.Job) error {
+	f, err := os.Open(exportPath(job))
+	if err != nil {
+		return fmt.Errorf("failed to open exported file: %w", err)
+	}
+	defer f.Close()
+
+	reqURL := fmt.Sprintf("%s/internal/job/export?worker_id=%s&job_id=%s", w.proxyURL, w.workerID, job.ID)
+	resp, err := streamClient.Post(reqURL, "application/octet-stream", f)
+	if err != nil {
+		return fmt.Errorf("failed to upload exported file: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("exported file upload failed with status: %s", resp.Status)
+	}
+	return nil
+}
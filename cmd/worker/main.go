@@ -1,23 +1,34 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"os"
+	"runtime"
+	"skein/internal/api"
 	"skein/internal/settings"
+	"skein/internal/tracing"
 	"time"
 
 	_ "github.com/duckdb/duckdb-go/v2"
 )
 
 const (
-	dbPath = "" // Use in-memory DuckDB
+	dbPath = "" // Use in-memory DuckDB, shared by the slots
 )
 
 var httpClient = &http.Client{
-	Timeout: settings.LongPollTimeout + (5 * time.Second), // Must be longer than the proxy's long poll timeout.
+	Timeout:   settings.LongPollTimeout + (5 * time.Second), // Must be longer than the proxy's long poll timeout.
+	Transport: workerCredentials,
 }
 
+// tracer exports the spans of executed jobs, a nil tracer only propagates them.
+var tracer *tracing.Tracer
+
+// streamClient uploads result streams, which last as long as the query runs.
+var streamClient = &http.Client{Transport: workerCredentials}
+
 func main() {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	slog.SetDefault(logger)
@@ -28,7 +39,56 @@ func main() {
 		proxyURL = "http://localhost:8080"
 	}
 	slog.Info("Worker starting...", "proxy_url", proxyURL)
+	// The proxy's shared worker secret or this worker's own token.
+	workerCredentials.set(os.Getenv("WORKER_TOKEN"))
+
+	slots := max(1, settings.IntFromEnv("WORKER_SLOTS", 1))
+	capacity := api.WorkerCapacity{
+		Size:    api.WorkerSize(os.Getenv("WORKER_SIZE")),
+		Threads: settings.IntFromEnv("WORKER_THREADS", runtime.NumCPU()),
+		Arrow:   arrowSupported,
+	}
+	if capacity.Size == "" {
+		capacity.Size = api.SizeSmall
+	}
+	if !capacity.Size.Valid() {
+		slog.Error("invalid WORKER_SIZE, use small, medium or large", "size", capacity.Size)
+		os.Exit(1)
+	}
+	if v := os.Getenv("WORKER_MEMORY_LIMIT"); v != "" {
+		limit, err := parseMemoryLimit(v)
+		if err != nil {
+			slog.Error("invalid WORKER_MEMORY_LIMIT", "error", err)
+			os.Exit(1)
+		}
+		capacity.MemoryLimitBytes = limit
+	}
+	slog.Info("Opening DuckDB", "slots", slots, "size", capacity.Size,
+		"threads", capacity.Threads, "memory_limit_bytes", capacity.MemoryLimitBytes)
+
+	metricsAddr := os.Getenv("WORKER_METRICS_ADDR")
+	if metricsAddr == "" {
+		metricsAddr = ":9091"
+	}
+	go serveMetrics(metricsAddr)
+
+	var err error
+	if tracer, err = tracing.FromEnv("skein-worker"); err != nil {
+		slog.Error("failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
+	db, err := OpenDB(dbPath, DBConfig{
+		Threads:          capacity.Threads,
+		MemoryLimitBytes: capacity.MemoryLimitBytes,
+		Slots:            slots,
+	})
+	if err != nil {
+		slog.Error("failed to open duckdb", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
 
-	w := &Worker{proxyURL: proxyURL}
+	w := &Worker{proxyURL: proxyURL, db: db, slots: slots, capacity: capacity, running: make(map[string]context.CancelCauseFunc)}
 	w.runWorker()
 }
@@ -1,14 +1,31 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
 	"skein/internal/api"
+	"skein/internal/tracing"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+func openTestDB(t testing.TB) *DB {
+	t.Helper()
+	// Use an in-memory database by passing an empty dbPath.
+	db, err := OpenDB("", DBConfig{})
+	if err != nil {
+		t.Fatalf("failed to open duckdb: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
 func TestExecuteJob(t *testing.T) {
 	// The parquet file is in the 'datasets' directory at the project root.
 	// The test runs from 'cmd/worker', so the relative path is '../../datasets/'.
@@ -25,8 +42,7 @@ func TestExecuteJob(t *testing.T) {
 		Query: query,
 	}
 
-	// Use an in-memory database by passing an empty dbPath.
-	result, err := ExecuteJob(context.Background(), job, "")
+	result, err := ExecuteJob(context.Background(), openTestDB(t), job)
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 	assert.Empty(t, result.Error)
@@ -74,7 +90,7 @@ func TestExecuteJobWithParams(t *testing.T) {
 		},
 	}
 
-	result, err := ExecuteJob(context.Background(), job, "")
+	result, err := ExecuteJob(context.Background(), openTestDB(t), job)
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 	assert.Empty(t, result.Error)
@@ -106,3 +122,258 @@ func TestExecuteJobWithParams(t *testing.T) {
 
 	t.Logf("Parameterized query executed successfully, count: %v", count)
 }
+
+// TestExecuteJobCancelled checks that cancelling the context interrupts a running DuckDB query.
+func TestExecuteJobCancelled(t *testing.T) {
+	job := &api.Job{
+		ID:               "test-job-cancel",
+		Query:            "SELECT sum(a.range * b.range) FROM range(100000000) a, range(100000000) b",
+		DisableProfiling: true,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := ExecuteJob(ctx, openTestDB(t), job)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 10*time.Second, "expected the query to be interrupted")
+}
+
+// TestExecuteJobSessionIsolation checks that jobs share the DuckDB instance but not the session state or settings.
+func TestExecuteJobSessionIsolation(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	settings := func() []interface{} {
+		result, err := ExecuteJob(ctx, db, &api.Job{
+			ID:               "test-job-settings",
+			Query:            "SELECT current_setting('threads')::VARCHAR, current_setting('memory_limit')",
+			DisableProfiling: true,
+		})
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		return result.ColumnData
+	}
+	want := settings()
+
+	steps := []struct {
+		query   string
+		wantErr bool
+	}{
+		{query: "CREATE TABLE shared AS SELECT 42 AS answer"},
+		{query: "CREATE TEMP TABLE session_only AS SELECT 1 AS one"},
+		{query: "SET threads = 1"},
+		{query: "SET memory_limit = '10MB'"},
+		{query: "SELECT answer FROM shared"},
+		{query: "SELECT one FROM session_only", wantErr: true},
+	}
+	for i, step := range steps {
+		job := &api.Job{ID: fmt.Sprintf("test-job-session-%d", i), Query: step.query}
+		_, err := ExecuteJob(ctx, db, job)
+		if step.wantErr {
+			assert.Error(t, err, step.query)
+		} else {
+			assert.NoError(t, err, step.query)
+		}
+	}
+	assert.Equal(t, want, settings(), "a job's settings leaked into the next job")
+}
+
+func TestOpenDBSharedBySlots(t *testing.T) {
+	db, err := OpenDB("", DBConfig{Slots: 2})
+	if err != nil {
+		t.Fatalf("failed to open duckdb: %v", err)
+	}
+	defer db.Close()
+	assert.Equal(t, 2, db.Stats().MaxOpenConnections, "one connection per slot")
+
+	// The slots' connections see the same instance.
+	ctx := context.Background()
+	_, err = ExecuteJob(ctx, db, &api.Job{ID: "test-job-slot-0", Query: "CREATE TABLE slots AS SELECT 2 AS n"})
+	assert.NoError(t, err)
+	first, err := db.Conn(ctx)
+	assert.NoError(t, err)
+	defer first.Close()
+	_, err = ExecuteJob(ctx, db, &api.Job{ID: "test-job-slot-1", Query: "SELECT n FROM slots"})
+	assert.NoError(t, err)
+}
+
+// TestStreamJob checks that a streamed result starts with the schema and is split into row batches.
+func TestStreamJob(t *testing.T) {
+	job := &api.Job{
+		ID:     "test-job-stream",
+		Query:  "SELECT range AS n FROM range($rows)",
+		Params: map[string]interface{}{"rows": 2*streamBatchRows + 1},
+		Format: api.FormatNDJSON,
+	}
+
+	var out bytes.Buffer
+	result, err := StreamJob(context.Background(), openTestDB(t), job, &out)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result.Profile)
+	assert.Empty(t, result.ColumnData)
+
+	dec := json.NewDecoder(&out)
+	var schema api.StreamMessage
+	assert.NoError(t, dec.Decode(&schema))
+	if assert.NotNil(t, schema.Schema) {
+		assert.Equal(t, []string{"n"}, schema.Schema.ColumnNames)
+	}
+	var batches []int
+	for dec.More() {
+		var msg api.StreamMessage
+		assert.NoError(t, dec.Decode(&msg))
+		batches = append(batches, len(msg.Rows))
+	}
+	assert.Equal(t, []int{streamBatchRows, streamBatchRows, 1}, batches)
+}
+
+// TestExecuteJobExport checks that parquet and csv jobs write their result with COPY ... TO.
+func TestExecuteJobExport(t *testing.T) {
+	db := openTestDB(t)
+	noHeader := false
+	jobs := []*api.Job{
+		{
+			ID:            "test-job-export-parquet",
+			Query:         "SELECT range AS n FROM range($rows);",
+			Params:        map[string]interface{}{"rows": 10},
+			Format:        api.FormatParquet,
+			ExportOptions: &api.ExportOptions{Compression: "zstd"},
+		},
+		{
+			ID:            "test-job-export-csv",
+			Query:         "SELECT 1 AS a, 'x' AS b",
+			Format:        api.FormatCSV,
+			ExportOptions: &api.ExportOptions{Compression: "none", Delimiter: ";", Header: &noHeader},
+		},
+	}
+	for _, job := range jobs {
+		t.Cleanup(func() { os.Remove(exportPath(job)) })
+		result, err := ExecuteJob(context.Background(), db, job)
+		if !assert.NoError(t, err, job.ID) || !assert.NotNil(t, result.Export) {
+			continue
+		}
+		assert.Equal(t, job.ExportFileName(), result.Export.FileName)
+		assert.Positive(t, result.Export.SizeBytes)
+		assert.NotEmpty(t, result.Profile)
+	}
+
+	var count int
+	err := db.QueryRow(fmt.Sprintf("SELECT count(*) FROM '%s'", exportPath(jobs[0]))).Scan(&count)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, count)
+
+	csv, err := os.ReadFile(exportPath(jobs[1]))
+	assert.NoError(t, err)
+	assert.Equal(t, "1;x\n", string(csv))
+}
+
+// BenchmarkExecuteJob compares reusing the worker's DuckDB instance with opening one per job.
+func BenchmarkExecuteJob(b *testing.B) {
+	const parquetPath = "../../datasets/taxi/taxi_2019_04.parquet"
+	if _, err := os.Stat(parquetPath); err != nil {
+		b.Skipf("dataset not available: %v", err)
+	}
+	job := &api.Job{
+		ID: "bench-job",
+		Query: fmt.Sprintf(`
+			SELECT passenger_count, count(*), avg(total_amount)
+			FROM '%s'
+			GROUP BY passenger_count;
+		`, parquetPath),
+		DisableProfiling: true,
+	}
+	ctx := context.Background()
+
+	b.Run("SharedDB", func(b *testing.B) {
+		db := openTestDB(b)
+		for b.Loop() {
+			if _, err := ExecuteJob(ctx, db, job); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("OpenPerJob", func(b *testing.B) {
+		for b.Loop() {
+			db, err := OpenDB("", DBConfig{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := ExecuteJob(ctx, db, job); err != nil {
+				b.Fatal(err)
+			}
+			db.Close()
+		}
+	})
+}
+
+func TestParseMemoryLimit(t *testing.T) {
+	tests := map[string]int64{
+		"100B":   100,
+		"512MB":  512 * 1000 * 1000,
+		"4GiB":   4 << 30,
+		"1.5 GB": 1500 * 1000 * 1000,
+		"2tib":   2 << 40,
+	}
+	for in, want := range tests {
+		got, err := parseMemoryLimit(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"", "12", "GB", "-1GB", "ten MB"} {
+		_, err := parseMemoryLimit(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestObserveQuery(t *testing.T) {
+	observeQuery(&api.JobResult{
+		Profile:   json.RawMessage(`{"total_bytes_read":2048,"cpu_time":0.25}`),
+		GoProfile: api.GoProfileStats{ExecuteTime: 300 * time.Millisecond},
+	})
+	observeQuery(&api.JobResult{Cancelled: true, Error: "cancelled"})
+
+	var buf bytes.Buffer
+	workerMetrics.Write(&buf)
+	assert.Contains(t, buf.String(), `skein_worker_query_duration_seconds_count{status="completed"} 1`)
+	assert.Contains(t, buf.String(), `skein_worker_query_duration_seconds_count{status="cancelled"} 1`)
+	assert.Contains(t, buf.String(), "skein_worker_query_bytes_read_sum 2048\n")
+	assert.Contains(t, buf.String(), "skein_worker_query_cpu_seconds_sum 0.25\n")
+}
+
+func TestExecuteJobTracesProfileCollection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	exporter, err := tracing.NewFileExporter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer = tracing.NewTracer("skein-worker", exporter)
+	t.Cleanup(func() { tracer = nil })
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := tracing.ContextWithTraceparent(context.Background(), traceparent)
+	_, err = ExecuteJob(ctx, openTestDB(t), &api.Job{ID: "test-job-trace", Query: "SELECT 1"})
+	assert.NoError(t, err)
+	assert.NoError(t, exporter.Shutdown(ctx))
+
+	spans, err := tracing.ReadSpans(path)
+	assert.NoError(t, err)
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "skein.worker.profile", spans[0].Name)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].TraceID.String())
+		assert.Equal(t, "00f067aa0ba902b7", spans[0].ParentSpanID.String())
+	}
+}
+
+func TestExecuteJobTimeline(t *testing.T) {
+	result, err := ExecuteJob(context.Background(), openTestDB(t), &api.Job{ID: "test-job-timeline", WorkerID: "w1", Query: "SELECT x"})
+	assert.Error(t, err)
+	if assert.NotNil(t, result) && assert.Len(t, result.Timeline, 1) {
+		assert.Equal(t, api.StageDuckDBFinished, result.Timeline[0].Stage)
+		assert.Equal(t, "w1", result.Timeline[0].WorkerID)
+	}
+}
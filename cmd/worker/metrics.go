@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"skein/internal/api"
+	"skein/internal/metrics"
+)
+
+var (
+	workerMetrics = metrics.NewRegistry()
+
+	queryDuration = workerMetrics.NewHistogram("skein_worker_query_duration_seconds",
+		"Time the worker spent executing a query, by final status.", metrics.DefBuckets, "status")
+	queryBytesRead = workerMetrics.NewHistogram("skein_worker_query_bytes_read",
+		"Bytes read by a query as reported by the DuckDB profile.", metrics.ExponentialBuckets(1024, 4, 12))
+	queryCPUTime = workerMetrics.NewHistogram("skein_worker_query_cpu_seconds",
+		"CPU time of a query as reported by the DuckDB profile.", metrics.DefBuckets)
+)
+
+// observeQuery records the execution of a finished query.
+func observeQuery(result *api.JobResult) {
+	status := api.StatusCompleted
+	switch {
+	case result.Cancelled:
+		status = api.StatusCancelled
+	case result.Error != "":
+		status = api.StatusFailed
+	}
+	queryDuration.Observe(result.GoProfile.ExecuteTime.Seconds(), string(status))
+
+	if len(result.Profile) == 0 {
+		return
+	}
+	var profile api.DuckDBProfile
+	if err := json.Unmarshal(result.Profile, &profile); err != nil {
+		slog.Warn("failed to parse DuckDB profile for metrics", "error", err)
+		return
+	}
+	queryBytesRead.Observe(float64(profile.TotalBytesRead))
+	queryCPUTime.Observe(profile.CPUTime)
+}
+
+// serveMetrics serves the worker's metrics for Prometheus on addr.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", workerMetrics.Handler())
+	slog.Info("Serving metrics", "addr", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		slog.Error("metrics server failed", "addr", addr, "error", err)
+	}
+}
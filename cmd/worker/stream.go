@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"skein/internal/api"
+)
+
+// resultUpload streams a result to the proxy while the query runs. The body
+// of a single long-running request is written as the rows are produced.
+type resultUpload struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+// openResultStream starts the upload of the job's result stream.
+func (w *Worker) openResultStream(jobID string) *resultUpload {
+	pr, pw := io.Pipe()
+	u := &resultUpload{pw: pw, done: make(chan error, 1)}
+	reqURL := fmt.Sprintf("%s/internal/job/stream?worker_id=%s&job_id=%s", w.proxyURL, w.workerID, jobID)
+	go func() {
+		resp, err := streamClient.Post(reqURL, api.NDJSONMediaType, pr)
+		if err != nil {
+			pr.CloseWithError(err)
+			u.done <- fmt.Errorf("failed to upload result stream: %w", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("proxy returned %s for result stream", resp.Status)
+			pr.CloseWithError(err)
+			u.done <- err
+			return
+		}
+		u.done <- nil
+	}()
+	return u
+}
+
+func (u *resultUpload) Write(p []byte) (int, error) {
+	return u.pw.Write(p)
+}
+
+// Abort ends the upload without a result, the proxy fails the job.
+func (u *resultUpload) Abort(err error) {
+	u.pw.CloseWithError(err)
+	<-u.done
+}
+
+// Finish sends the job's result as the last line of the stream and waits
+// for the proxy to accept the upload.
+func (u *resultUpload) Finish(result *api.JobResult) error {
+	if err := json.NewEncoder(u.pw).Encode(api.StreamMessage{Result: result}); err != nil {
+		u.pw.CloseWithError(err)
+		return <-u.done
+	}
+	u.pw.Close()
+	return <-u.done
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"skein/internal/api"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/google/uuid"
+)
+
+// columnDataTypes parses the types of the result columns, a type that
+// doesn't parse is nil and its values are sent as the driver returns them.
+func columnDataTypes(columnTypes []api.ColumnType) []*api.DataType {
+	types := make([]*api.DataType, len(columnTypes))
+	for i, ct := range columnTypes {
+		t, err := api.ParseType(ct.Type)
+		if err != nil {
+			slog.Warn("unknown column type", "type", ct.Type, "error", err)
+			continue
+		}
+		types[i] = t
+	}
+	return types
+}
+
+// wireValue converts a value scanned from DuckDB to its JSON wire form, see
+// api.DecodeColumn.
+func wireValue(t *api.DataType, v any) any {
+	if t == nil {
+		if b, ok := v.([]byte); ok {
+			return string(b)
+		}
+		return v
+	}
+	switch v.(type) {
+	case nil, string, []byte:
+	default:
+		if t.ID == "VARCHAR" {
+			// A JSON value nested in a STRUCT or LIST, its type says VARCHAR but
+			// the driver parsed it.
+			if text, err := json.Marshal(v); err == nil {
+				return string(text)
+			}
+		}
+	}
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case *big.Int:
+		// HUGEINTs don't fit into the float64 of JSON clients.
+		return v.String()
+	case duckdb.Decimal:
+		return api.Decimal{Unscaled: v.Value, Scale: int(v.Scale)}
+	case duckdb.Interval:
+		return api.Interval{Months: v.Months, Days: v.Days, Micros: v.Micros}
+	case duckdb.UUID:
+		return uuid.UUID(v)
+	case time.Time:
+		switch t.ID {
+		case "DATE":
+			return api.Date{Time: v}
+		case "TIME":
+			return timeOfDay(v)
+		case "TIMETZ":
+			// Only read without the text cast, the driver returns it in UTC.
+			return api.TimeTZ{Time: timeOfDay(v.UTC())}
+		}
+		return v
+	case string:
+		if t.ID == "TIMETZ" {
+			if tz, err := api.ParseTimeTZ(v); err == nil {
+				return tz
+			}
+		}
+		return v
+	case []byte:
+		switch t.ID {
+		case "UUID":
+			if id, err := uuid.FromBytes(v); err == nil {
+				return id
+			}
+		case "BLOB":
+			return v
+		}
+		return string(v)
+	case []any:
+		if t.Elem == nil {
+			return v
+		}
+		list := make([]any, len(v))
+		for i, e := range v {
+			list[i] = wireValue(t.Elem, e)
+		}
+		return list
+	case map[string]any:
+		if t.ID != "STRUCT" {
+			// A JSON object, it has no fields to convert.
+			return v
+		}
+		fields := make(map[string]any, len(v))
+		for _, f := range t.Fields {
+			fields[f.Name] = wireValue(f.Type, v[f.Name])
+		}
+		return fields
+	case duckdb.Map:
+		if t.Key == nil {
+			return v
+		}
+		m := make(api.Map, 0, len(v))
+		for key, value := range v {
+			m = append(m, api.MapEntry{Key: wireValue(t.Key, key), Value: wireValue(t.Value, value)})
+		}
+		// The driver returns a Go map, sort the entries to keep results stable.
+		sort.Slice(m, func(i, j int) bool { return fmt.Sprint(m[i].Key) < fmt.Sprint(m[j].Key) })
+		return m
+	case duckdb.Union:
+		for _, member := range t.Fields {
+			if member.Name == v.Tag {
+				return api.Union{Tag: v.Tag, Value: wireValue(member.Type, v.Value)}
+			}
+		}
+		return api.Union{Tag: v.Tag, Value: v.Value}
+	}
+	return v
+}
+
+func timeOfDay(v time.Time) api.TimeOfDay {
+	y, m, d := v.Date()
+	return api.TimeOfDay(v.Sub(time.Date(y, m, d, 0, 0, 0, 0, v.Location())))
+}
+
+// textTypes are the types the DuckDB driver can't scan, UHUGEINT and BIT, or
+// scans without their UTC offset, TIMETZ. Results read them cast to VARCHAR.
+var textTypes = map[string]bool{"TIMETZ": true, "UHUGEINT": true, "BIT": true}
+
+// textCastQuery wraps a query whose columns have textTypes, also nested ones,
+// into one casting them to VARCHAR, keeping the other columns as they are.
+// Only queries starting with SELECT, WITH, FROM or VALUES are wrapped, as
+// the query runs again.
+func textCastQuery(query string, columnTypes []api.ColumnType) (string, bool) {
+	first := strings.TrimLeft(query, "( \t\r\n")
+	if end := strings.IndexFunc(first, func(r rune) bool { return !unicode.IsLetter(r) }); end >= 0 {
+		first = first[:end]
+	}
+	switch strings.ToLower(first) {
+	case "select", "with", "from", "values":
+	default:
+		return "", false
+	}
+
+	columns := make([]string, len(columnTypes))
+	cast := false
+	for i, ct := range columnTypes {
+		columns[i] = "#" + strconv.Itoa(i+1)
+		if textType, ok := textCastType(ct.Type); ok {
+			columns[i] = fmt.Sprintf("CAST(%s AS %s)", columns[i], textType)
+			cast = true
+		}
+	}
+	if !cast {
+		return "", false
+	}
+	// The newline ends a trailing line comment of the query.
+	return fmt.Sprintf("SELECT %s FROM (%s\n)", strings.Join(columns, ", "), strings.TrimRight(query, "; \t\r\n")), true
+}
+
+// textCastType replaces the textTypes in a type name by VARCHAR, skipping
+// the quoted names of STRUCT fields.
+func textCastType(typeName string) (string, bool) {
+	var b strings.Builder
+	replaced := false
+	runes := []rune(typeName)
+	for i := 0; i < len(runes); {
+		switch r := runes[i]; {
+		case r == '"':
+			start := i
+			for i++; i < len(runes); i++ {
+				if runes[i] == '"' {
+					if i+1 < len(runes) && runes[i+1] == '"' {
+						i++
+						continue
+					}
+					i++
+					break
+				}
+			}
+			b.WriteString(string(runes[start:i]))
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			word := string(runes[start:i])
+			if textTypes[strings.ToUpper(word)] {
+				word = "VARCHAR"
+				replaced = true
+			}
+			b.WriteString(word)
+		default:
+			b.WriteRune(r)
+			i++
+		}
+	}
+	return b.String(), replaced
+}
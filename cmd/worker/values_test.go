@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"skein/internal/api"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExecuteJobTypesRoundTrip runs a query with a column of every DuckDB
+// type and decodes its JSON result like the proxy does.
+func TestExecuteJobTypesRoundTrip(t *testing.T) {
+	db := openTestDB(t)
+	_, err := db.Exec("CREATE TYPE mood AS ENUM ('sad', 'ok', 'happy')")
+	require.NoError(t, err)
+
+	job := &api.Job{ID: "test-job-types", DisableProfiling: true, Query: `SELECT
+		-1::TINYINT, -2::SMALLINT, -3::INTEGER, -4::BIGINT,
+		255::UTINYINT, 65535::USMALLINT, 4294967295::UINTEGER, 18446744073709551615::UBIGINT,
+		-170141183460469231731687303715884105727::HUGEINT,
+		12345678901234567890.123456789::DECIMAL(38,9), -0.05::DECIMAL(4,2),
+		1.5::FLOAT, 2.25::DOUBLE, 'x'::VARCHAR, true, 'ok'::mood,
+		'\xAA\x00'::BLOB, 'a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11'::UUID,
+		DATE '2024-01-02', TIME '12:34:56.789', TIMESTAMP '2024-01-02 03:04:05.123456',
+		TIMESTAMPTZ '2024-01-02 03:04:05+02', INTERVAL '1 month 2 days 3 seconds',
+		[1, NULL, 3], [DATE '2024-01-01']::DATE[1], {'a': 1, 'b': [1.5::DECIMAL(3,1)]},
+		MAP {'k': 1, 'l': NULL}, union_value(num := 2)::UNION(num INTEGER, str VARCHAR),
+		NULL::INTEGER, NULL::VARCHAR, NULL::INTEGER[]`}
+	result, err := ExecuteJob(context.Background(), db, job)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	var decoded api.JobResult
+	require.NoError(t, json.Unmarshal(data, &decoded), string(data))
+
+	hugeint, _ := new(big.Int).SetString("-170141183460469231731687303715884105727", 10)
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	bigDecimal, _ := api.ParseDecimal("12345678901234567890.123456789")
+	smallDecimal, _ := api.ParseDecimal("-0.05")
+	want := []any{
+		[]int8{-1}, []int16{-2}, []int32{-3}, []int64{-4},
+		[]uint16{255}, []uint16{65535}, []uint32{4294967295}, []uint64{18446744073709551615},
+		[]*big.Int{hugeint},
+		[]api.Decimal{bigDecimal}, []api.Decimal{smallDecimal},
+		[]float32{1.5}, []float64{2.25}, []string{"x"}, []bool{true}, []string{"ok"},
+		[][]byte{{0xAA, 0x00}}, []uuid.UUID{uuid.MustParse("a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11")},
+		[]api.Date{{Time: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}},
+		[]api.TimeOfDay{api.TimeOfDay(12*time.Hour + 34*time.Minute + 56*time.Second + 789*time.Millisecond)},
+		[]time.Time{ts}, []time.Time{time.Date(2024, 1, 2, 1, 4, 5, 0, time.UTC)},
+		[]api.Interval{{Months: 1, Days: 2, Micros: 3_000_000}},
+		[][]any{{int32(1), nil, int32(3)}},
+		[][]any{{api.Date{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}}},
+		[]map[string]any{{"a": int32(1), "b": []any{api.Decimal{Unscaled: big.NewInt(15), Scale: 1}}}},
+		[]api.Map{{{Key: "k", Value: int32(1)}, {Key: "l", Value: nil}}},
+		[]api.Union{{Tag: "num", Value: int32(2)}},
+	}
+	want = append(want, []int32{0}, []string{""}, [][]any{nil})
+	require.Len(t, decoded.ColumnData, len(want))
+	for i := range want {
+		col := decoded.ColumnData[i].(api.Column)
+		assert.Equal(t, want[i], col.Values, "column %d %s", i, decoded.ColumnTypes[i].Type)
+		assert.Equal(t, i >= len(want)-3, col.IsNull(0), "column %d %s", i, decoded.ColumnTypes[i].Type)
+	}
+
+	again, err := json.Marshal(decoded)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(data), string(again), "decoded results encode the same as the worker sends them")
+}
+
+// TestExecuteJobTextTypes reads the types the driver can't scan as it
+// should, cast to text.
+func TestExecuteJobTextTypes(t *testing.T) {
+	db := openTestDB(t)
+	job := &api.Job{ID: "test-job-text-types", DisableProfiling: true, Query: `SELECT
+		'12:34:56.5+05:30'::TIMETZ AS t, '01:02:03-08'::TIMETZ AS t,
+		340282366920938463463374607431768211455::UHUGEINT AS u, '0101'::BIT AS "bit",
+		[1::UHUGEINT, NULL] AS l, {'bit': '1'::BIT, 'n': 1} AS s, MAP {2::UHUGEINT: '12:00:00+01'::TIMETZ} AS m,
+		NULL::TIMETZ AS n, 42 AS i;`}
+	result, err := ExecuteJob(context.Background(), db, job)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"t", "t", "u", "bit", "l", "s", "m", "n", "i"}, result.ColumnNames)
+	assert.Equal(t, "MAP(UHUGEINT, TIMETZ)", result.ColumnTypes[6].Type, "the column types are the original ones")
+
+	data, err := json.Marshal(result.ColumnData)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[["12:34:56.5+05:30"],["01:02:03-08"],["340282366920938463463374607431768211455"],["0101"],
+		[["1",null]],[{"bit":"1","n":1}],[[{"key":"2","value":"12:00:00+01"}]],[null],[42]]`, string(data))
+
+	data, err = json.Marshal(result)
+	require.NoError(t, err)
+	var decoded api.JobResult
+	require.NoError(t, json.Unmarshal(data, &decoded), string(data))
+	uhugeint, _ := new(big.Int).SetString("340282366920938463463374607431768211455", 10)
+	want := []any{
+		[]api.TimeTZ{{Time: api.TimeOfDay(12*time.Hour + 34*time.Minute + 56*time.Second + 500*time.Millisecond), Offset: 5*3600 + 30*60}},
+		[]api.TimeTZ{{Time: api.TimeOfDay(time.Hour + 2*time.Minute + 3*time.Second), Offset: -8 * 3600}},
+		[]*big.Int{uhugeint},
+		[]string{"0101"},
+		[][]any{{big.NewInt(1), nil}},
+		[]map[string]any{{"bit": "1", "n": int32(1)}},
+		[]api.Map{{{Key: big.NewInt(2), Value: api.TimeTZ{Time: api.TimeOfDay(12 * time.Hour), Offset: 3600}}}},
+		[]api.TimeTZ{{}},
+		[]int32{42},
+	}
+	for i := range want {
+		assert.Equal(t, want[i], decoded.ColumnData[i].(api.Column).Values, "column %d %s", i, decoded.ColumnTypes[i].Type)
+	}
+}
+
+func TestExecuteJobTextTypesNotReadOnly(t *testing.T) {
+	db := openTestDB(t)
+	_, err := db.Exec("CREATE TABLE tz (t TIMETZ)")
+	require.NoError(t, err)
+	job := &api.Job{ID: "test-job-text-types-insert", DisableProfiling: true,
+		Query: `INSERT INTO tz VALUES ('12:00:00+01') RETURNING t`}
+	result, err := ExecuteJob(context.Background(), db, job)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{api.TimeTZ{Time: api.TimeOfDay(11 * time.Hour)}}, result.ColumnData[0], "the insert doesn't run twice, its TIMETZ is read in UTC")
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM tz").Scan(&count))
+	assert.Equal(t, 1, count)
+
+	job = &api.Job{ID: "test-job-uhugeint-insert", DisableProfiling: true,
+		Query: `INSERT INTO tz VALUES ('13:00:00+01') RETURNING 1::UHUGEINT`}
+	_, err = ExecuteJob(context.Background(), db, job)
+	assert.ErrorContains(t, err, "unsupported data type", "UHUGEINT and BIT are only read from queries")
+}
+
+func TestExecuteJobJSONObjects(t *testing.T) {
+	db := openTestDB(t)
+	job := &api.Job{ID: "test-job-json", DisableProfiling: true,
+		Query: `SELECT '{"x":1,"y":[true,null]}'::JSON, {'j': '{"z":"a"}'::JSON}`}
+	result, err := ExecuteJob(context.Background(), db, job)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(result.ColumnData)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[[{"x":1,"y":[true,null]}],[{"j":"{\"z\":\"a\"}"}]]`, string(data))
+
+	data, err = json.Marshal(result)
+	require.NoError(t, err)
+	var decoded api.JobResult
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, []any{map[string]any{"x": float64(1), "y": []any{true, nil}}}, decoded.ColumnData[0].(api.Column).Values)
+	assert.Equal(t, []map[string]any{{"j": `{"z":"a"}`}}, decoded.ColumnData[1].(api.Column).Values)
+}
+
+func TestExecuteJobHugeintsAsStrings(t *testing.T) {
+	db := openTestDB(t)
+	job := &api.Job{ID: "test-job-hugeint", DisableProfiling: true,
+		Query: `SELECT 170141183460469231731687303715884105727::HUGEINT, [9007199254740993::HUGEINT]`}
+	result, err := ExecuteJob(context.Background(), db, job)
+	require.NoError(t, err)
+
+	const wire = `[["170141183460469231731687303715884105727"],[["9007199254740993"]]]`
+	data, err := json.Marshal(result.ColumnData)
+	require.NoError(t, err)
+	assert.JSONEq(t, wire, string(data))
+
+	data, err = json.Marshal(result)
+	require.NoError(t, err)
+	var decoded api.JobResult
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	hugeint, _ := new(big.Int).SetString("170141183460469231731687303715884105727", 10)
+	assert.Equal(t, []*big.Int{hugeint}, decoded.ColumnData[0].(api.Column).Values)
+	assert.Equal(t, [][]any{{big.NewInt(9007199254740993)}}, decoded.ColumnData[1].(api.Column).Values)
+
+	// The proxy encodes decoded columns again for its clients.
+	data, err = json.Marshal(decoded.ColumnData)
+	require.NoError(t, err)
+	assert.JSONEq(t, wire, string(data))
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
@@ -14,13 +15,35 @@ import (
 	"path"
 	"skein/internal/api"
 	"skein/internal/settings"
+	"skein/internal/tracing"
+	"slices"
+	"strconv"
+	"sync"
 	"syscall"
 	"time"
 )
 
+// streamBatchRows is the number of rows per line of a streamed result.
+const streamBatchRows = 1000
+
+var (
+	errJobCancelled = errors.New("query cancelled")
+	// errLeaseLost means the proxy gave the job to another worker.
+	errLeaseLost = errors.New("job lease lost")
+)
+
 type Worker struct {
 	proxyURL string
 	workerID string
+	// db is the DuckDB instance shared by the execution slots, the worker
+	// runs up to slots jobs at the same time.
+	db       *DB
+	slots    int
+	capacity api.WorkerCapacity
+
+	mu sync.Mutex
+	// running maps the IDs of the executing jobs to their cancel functions.
+	running map[string]context.CancelCauseFunc
 }
 
 func (w *Worker) runWorker() {
@@ -30,18 +53,32 @@ func (w *Worker) runWorker() {
 		slog.Error("failed to register with proxy", "error", err)
 		os.Exit(1)
 	}
-	slog.Info("Worker registered successfully", "worker_id", w.workerID)
+	slog.Info("Worker registered successfully", "worker_id", w.workerID, "slots", w.slots, "size", w.capacity.Size)
 
 	// 2. Handle graceful shutdown.
 	// This will call deregister when the process is terminated.
 	w.setupGracefulShutdown()
 
 	// 3. Start the heartbeat goroutine.
-	go runHeartbeat(w.proxyURL, w.workerID)
+	go w.runHeartbeat()
 
 	workerDelay, _ := time.ParseDuration(os.Getenv("WORKER_DELAY"))
 
-	// 4. Main job-fetching loop.
+	// 4. Job-fetching loop per slot.
+	var wg sync.WaitGroup
+	for slot := range w.slots {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.runSlot(slot, workerDelay)
+		}()
+	}
+	wg.Wait()
+}
+
+// runSlot fetches, executes and reports jobs one at a time, each on its own
+// connection of the worker's DuckDB instance.
+func (w *Worker) runSlot(slot int, workerDelay time.Duration) {
 	for {
 		job := w.fetchJob()
 		if job == nil {
@@ -49,39 +86,114 @@ func (w *Worker) runWorker() {
 			// The fetchJob function handles logging and backoff, so we just continue.
 			continue
 		}
+		if err := w.ackJob(job.ID); err != nil {
+			slog.Warn("Failed to acknowledge job, skipping it", "job_id", job.ID, "worker_id", w.workerID, "error", err)
+			continue
+		}
 
 		slog.Info("Executing job", "event", "query.execution.started", "job_id", job.ID,
-			"worker_id", w.workerID)
+			"worker_id", w.workerID, "slot", slot, "attempt", job.Attempts)
+		ctx, cancel := context.WithCancelCause(tracing.ContextWithTraceparent(context.Background(), job.Traceparent))
+		w.trackJob(job.ID, cancel)
+		go w.watchCancellation(ctx, job.ID, cancel)
+
+		var (
+			result *api.JobResult
+			err    error
+			upload *resultUpload
+		)
+		execCtx, span := tracer.Start(ctx, "skein.worker.execute", tracing.String("job.id", job.ID),
+			tracing.String("worker.id", w.workerID), tracing.Int("worker.slot", slot), tracing.String("result.format", string(job.Format)))
+		started := api.TimelineEntry{Stage: api.StageWorkerStarted, At: time.Now().UTC(), WorkerID: w.workerID}
 		startTime := time.Now()
-		result, err := ExecuteJob(context.Background(), job, dbPath)
+		if job.Format == api.FormatNDJSON {
+			upload = w.openResultStream(job.ID)
+			result, err = StreamJob(execCtx, w.db, job, upload)
+		} else {
+			result, err = ExecuteJob(execCtx, w.db, job)
+		}
 		duration := time.Since(startTime)
+		span.SetError(err)
+		span.End()
+		cancelled := errors.Is(context.Cause(ctx), errJobCancelled)
+		leaseLost := errors.Is(context.Cause(ctx), errLeaseLost)
+		cancel(nil)
+		w.untrackJob(job.ID)
+
+		if leaseLost {
+			slog.Warn("Job lease lost, dropping the result", "event", "query.execution.lost", "job_id", job.ID,
+				"worker_id", w.workerID, "slot", slot)
+			if upload != nil {
+				upload.Abort(errLeaseLost)
+			}
+			if job.Format.IsExport() {
+				os.Remove(exportPath(job))
+			}
+			continue
+		}
 
 		if result == nil {
 			result = &api.JobResult{}
 		}
 		result.GoProfile.ExecuteTime = duration
-
-		if err != nil {
+		result.Timeline = append(api.Timeline{started}, result.Timeline...)
+
+		if cancelled {
+			slog.Info("Job execution cancelled", "event", "query.execution.cancelled", "job_id", job.ID,
+				"worker_id", w.workerID, "slot", slot, "duration_ms", duration.Milliseconds())
+			result.Cancelled = true
+			result.Error = errJobCancelled.Error()
+		} else if err != nil {
 			slog.Error("Job execution failed", "event", "query.execution.failed", "job_id", job.ID,
-				"worker_id", w.workerID, "error", err)
+				"worker_id", w.workerID, "slot", slot, "error", err)
 			result.Error = err.Error()
 		} else {
 			slog.Info("Job execution completed", "event", "query.execution.completed", "job_id", job.ID,
-				"worker_id", w.workerID, "duration_ms", duration.Milliseconds())
+				"worker_id", w.workerID, "slot", slot, "duration_ms", duration.Milliseconds())
 		}
 
+		if job.Format.IsExport() {
+			if result.Export != nil {
+				if err := w.uploadExport(job); err != nil {
+					slog.Error("Export upload failed", "job_id", job.ID, "worker_id", w.workerID, "error", err)
+					result.Export = nil
+					result.Error = err.Error()
+				}
+			}
+			if err := os.Remove(exportPath(job)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				slog.Warn("failed to remove exported file", "job_id", job.ID, "error", err)
+			}
+		}
+
+		observeQuery(result)
+
 		if workerDelay > 0 {
 			slog.Info("Delaying result submission", "job_id", job.ID, "delay", workerDelay)
 			time.Sleep(workerDelay)
 		}
 
-		submitResult(w.proxyURL, job.ID, result)
+		_, span = tracer.Start(ctx, "skein.worker.submit_result", tracing.String("job.id", job.ID), tracing.String("worker.id", w.workerID))
+		if upload != nil {
+			// The rows are already encoded and sent, only the result line is left.
+			result.Timeline.Add(api.StageResultSerialized, w.workerID)
+			if err := upload.Finish(result); err != nil {
+				slog.Error("failed to stream result to proxy", "job_id", job.ID, "error", err)
+				span.SetError(err)
+			}
+		} else {
+			w.submitResult(job.ID, result)
+		}
+		span.End()
 	}
 }
 
 // register contacts the proxy to get a unique worker ID.
 func (w *Worker) register() error {
-	resp, err := httpClient.Post(w.proxyURL+"/internal/worker/register", "application/json", nil)
+	body, err := json.Marshal(api.RegisterWorkerRequest{Slots: w.slots, Capacity: w.capacity})
+	if err != nil {
+		return fmt.Errorf("failed to marshal registration request: %w", err)
+	}
+	resp, err := httpClient.Post(w.proxyURL+"/internal/worker/register", "application/json", bytes.NewBuffer(body))
 	if err != nil {
 		return fmt.Errorf("failed to send registration request: %w", err)
 	}
@@ -91,9 +203,7 @@ func (w *Worker) register() error {
 		return fmt.Errorf("registration failed with status: %s", resp.Status)
 	}
 
-	var payload struct {
-		WorkerID string `json:"worker_id"`
-	}
+	var payload api.RegisterWorkerResponse
 	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
 		return fmt.Errorf("failed to decode registration response: %w", err)
 	}
@@ -101,26 +211,87 @@ func (w *Worker) register() error {
 		return errors.New("proxy did not return a worker_id")
 	}
 	w.workerID = payload.WorkerID
+	if payload.Token != "" {
+		workerCredentials.set(payload.Token)
+	}
 	return nil
 }
 
-// runHeartbeat sends periodic heartbeats to the proxy.
-func runHeartbeat(proxyURL, workerID string) {
+// runHeartbeat sends periodic heartbeats to the proxy, renewing the leases of
+// the running jobs. Jobs the proxy reports as lost are cancelled.
+func (w *Worker) runHeartbeat() {
 	ticker := time.NewTicker(settings.HeartbeatInterval)
 	defer ticker.Stop()
 
 	for {
 		<-ticker.C
-		payload, _ := json.Marshal(map[string]string{"worker_id": workerID})
-		resp, err := httpClient.Post(proxyURL+"/internal/worker/heartbeat", "application/json", bytes.NewBuffer(payload))
+		payload, _ := json.Marshal(api.HeartbeatRequest{WorkerID: w.workerID, JobIDs: w.runningJobs()})
+		resp, err := httpClient.Post(w.proxyURL+"/internal/worker/heartbeat", "application/json", bytes.NewBuffer(payload))
 		if err != nil {
-			slog.Warn("failed to send heartbeat", "worker_id", workerID, "error", err)
+			slog.Warn("failed to send heartbeat", "worker_id", w.workerID, "error", err)
 			continue
 		}
-		resp.Body.Close()
 		if resp.StatusCode != http.StatusOK {
-			slog.Warn("heartbeat request failed", "worker_id", workerID, "status", resp.Status)
+			resp.Body.Close()
+			slog.Warn("heartbeat request failed", "worker_id", w.workerID, "status", resp.Status)
+			continue
+		}
+		var hb api.HeartbeatResponse
+		if err := json.NewDecoder(resp.Body).Decode(&hb); err != nil {
+			slog.Warn("failed to decode heartbeat response", "worker_id", w.workerID, "error", err)
 		}
+		resp.Body.Close()
+		for _, jobID := range hb.LostJobIDs {
+			w.cancelJob(jobID, errLeaseLost)
+		}
+	}
+}
+
+// ackJob acknowledges a fetched job, the proxy redelivers jobs that aren't
+// acknowledged in time.
+func (w *Worker) ackJob(jobID string) error {
+	body, err := json.Marshal(api.JobAck{WorkerID: w.workerID, JobID: jobID})
+	if err != nil {
+		return fmt.Errorf("failed to marshal job ack: %w", err)
+	}
+	resp, err := httpClient.Post(w.proxyURL+"/internal/job/ack", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("failed to send job ack: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("job ack failed with status: %s", resp.Status)
+	}
+	return nil
+}
+
+func (w *Worker) trackJob(jobID string, cancel context.CancelCauseFunc) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.running[jobID] = cancel
+}
+
+func (w *Worker) untrackJob(jobID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.running, jobID)
+}
+
+func (w *Worker) runningJobs() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ids := make([]string, 0, len(w.running))
+	for id := range w.running {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (w *Worker) cancelJob(jobID string, cause error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if cancel, ok := w.running[jobID]; ok {
+		cancel(cause)
 	}
 }
 
@@ -139,10 +310,49 @@ func (w *Worker) setupGracefulShutdown() {
 		} else {
 			resp.Body.Close()
 		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tracer.Shutdown(ctx); err != nil {
+			slog.Warn("failed to flush spans", "error", err)
+		}
 		os.Exit(0)
 	}()
 }
 
+// watchCancellation long-polls the proxy until the job is cancelled or ctx is done.
+// A cancelled job has its context cancelled with errJobCancelled.
+func (w *Worker) watchCancellation(ctx context.Context, jobID string, cancel context.CancelCauseFunc) {
+	reqURL := fmt.Sprintf("%s/internal/job/cancel?worker_id=%s&job_id=%s", w.proxyURL, w.workerID, jobID)
+	for ctx.Err() == nil {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+		if err != nil {
+			slog.Error("failed to create cancellation watch request", "job_id", jobID, "error", err)
+			return
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("failed to watch job cancellation", "job_id", jobID, "error", err)
+			time.Sleep(2 * time.Second)
+			continue
+		}
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			slog.Info("Job cancelled by proxy", "job_id", jobID, "worker_id", w.workerID)
+			cancel(errJobCancelled)
+			return
+		case http.StatusNoContent:
+		default:
+			slog.Warn("proxy returned unexpected status for cancellation watch", "job_id", jobID, "status", resp.Status)
+			return
+		}
+	}
+}
+
 // fetchJob long-polls the proxy for the next available job.
 func (w *Worker) fetchJob() *api.Job {
 	slog.Info("Polling for next job...", "worker_id", w.workerID)
@@ -174,27 +384,34 @@ func (w *Worker) fetchJob() *api.Job {
 	return &job
 }
 
-func runQuery(ctx context.Context, db *sql.DB, job *api.Job) (*api.JobResult, error) {
+// queryArgs returns the job's parameters as named query arguments.
+func queryArgs(job *api.Job) []any {
 	args := make([]any, 0, len(job.Params))
 	for k, v := range job.Params {
 		args = append(args, sql.Named(k, v))
 	}
+	return args
+}
 
-	start := time.Now()
-	rows, err := db.QueryContext(ctx, job.Query, args...)
+// openRows runs the job's query and returns its rows with the column metadata.
+// A query with columns of textTypes runs again with them cast to VARCHAR, see
+// textCastQuery; the column types stay the original ones.
+func openRows(ctx context.Context, db *sql.Conn, job *api.Job) (*sql.Rows, []string, []api.ColumnType, error) {
+	rows, err := db.QueryContext(ctx, job.Query, queryArgs(job)...)
 	if err != nil {
-		return nil, fmt.Errorf("query execution failed: %w", err)
+		return nil, nil, nil, fmt.Errorf("query execution failed: %w", err)
 	}
-	defer rows.Close()
 
 	columnNames, err := rows.Columns()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get columns: %w", err)
+		rows.Close()
+		return nil, nil, nil, fmt.Errorf("failed to get columns: %w", err)
 	}
 
 	sqlColumnTypes, err := rows.ColumnTypes()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get column types: %w", err)
+		rows.Close()
+		return nil, nil, nil, fmt.Errorf("failed to get column types: %w", err)
 	}
 
 	apiColumnTypes := make([]api.ColumnType, len(sqlColumnTypes))
@@ -206,6 +423,47 @@ func runQuery(ctx context.Context, db *sql.DB, job *api.Job) (*api.JobResult, er
 		}
 	}
 
+	if query, ok := textCastQuery(job.Query, apiColumnTypes); ok {
+		rows.Close()
+		textRows, err := db.QueryContext(ctx, query, queryArgs(job)...)
+		if err == nil {
+			return textRows, columnNames, apiColumnTypes, nil
+		}
+		slog.Warn("failed to cast columns to text", "job_id", job.ID, "error", err)
+		if rows, err = db.QueryContext(ctx, job.Query, queryArgs(job)...); err != nil {
+			return nil, nil, nil, fmt.Errorf("query execution failed: %w", err)
+		}
+	}
+	return rows, columnNames, apiColumnTypes, nil
+}
+
+// scanRow scans the current row and converts the values to their wire form
+// by the column types.
+func scanRow(rows *sql.Rows, types []*api.DataType) ([]interface{}, error) {
+	values := make([]interface{}, len(types))
+	valuePtrs := make([]interface{}, len(types))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	if err := rows.Scan(valuePtrs...); err != nil {
+		return nil, fmt.Errorf("failed to scan row: %w", err)
+	}
+	for i, val := range values {
+		values[i] = wireValue(types[i], val)
+	}
+	return values, nil
+}
+
+func runQuery(ctx context.Context, db *sql.Conn, job *api.Job) (*api.JobResult, error) {
+	start := time.Now()
+	rows, columnNames, columnTypes, err := openRows(ctx, db, job)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	dataTypes := columnDataTypes(columnTypes)
+
 	// Initialize columnData as a slice of empty slices, one for each column
 	columnData := make([]interface{}, len(columnNames))
 	for i := range columnData {
@@ -213,21 +471,13 @@ func runQuery(ctx context.Context, db *sql.DB, job *api.Job) (*api.JobResult, er
 	}
 
 	for rows.Next() {
-		values := make([]interface{}, len(columnNames))
-		valuePtrs := make([]interface{}, len(columnNames))
-		for i := range columnNames {
-			valuePtrs[i] = &values[i]
-		}
-
-		if err := rows.Scan(valuePtrs...); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
+		values, err := scanRow(rows, dataTypes)
+		if err != nil {
+			return nil, err
 		}
 
 		// Append scanned values to their respective column slices
 		for i, val := range values {
-			if b, ok := val.([]byte); ok {
-				val = string(b)
-			}
 			columnData[i] = append(columnData[i].([]interface{}), val)
 		}
 	}
@@ -240,7 +490,7 @@ func runQuery(ctx context.Context, db *sql.DB, job *api.Job) (*api.JobResult, er
 
 	return &api.JobResult{
 		ColumnNames: columnNames,
-		ColumnTypes: apiColumnTypes,
+		ColumnTypes: columnTypes,
 		ColumnData:  columnData,
 		GoProfile: api.GoProfileStats{
 			QueryTime: duration,
@@ -248,7 +498,65 @@ func runQuery(ctx context.Context, db *sql.DB, job *api.Job) (*api.JobResult, er
 	}, nil
 }
 
-func enableProfiling(ctx context.Context, db *sql.DB, id string) (string, error) {
+// streamQuery runs the job's query and writes the result to out as
+// api.StreamMessage lines: the schema first, then batches of streamBatchRows rows.
+func streamQuery(ctx context.Context, db *sql.Conn, job *api.Job, out io.Writer) (*api.JobResult, error) {
+	start := time.Now()
+	rows, columnNames, columnTypes, err := openRows(ctx, db, job)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	dataTypes := columnDataTypes(columnTypes)
+
+	enc := json.NewEncoder(out)
+	schema := &api.StreamSchema{ColumnNames: columnNames, ColumnTypes: columnTypes}
+	if err := enc.Encode(api.StreamMessage{Schema: schema}); err != nil {
+		return nil, fmt.Errorf("failed to write result schema: %w", err)
+	}
+
+	batch := make([][]interface{}, 0, streamBatchRows)
+	rowCount := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := enc.Encode(api.StreamMessage{Rows: batch}); err != nil {
+			return fmt.Errorf("failed to write result rows: %w", err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+	for rows.Next() {
+		values, err := scanRow(rows, dataTypes)
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, values)
+		rowCount++
+		if len(batch) == streamBatchRows {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during row iteration: %w", err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	duration := time.Since(start)
+	slog.Info("query execution completed", "duration_ms", duration.Milliseconds(), "rows", rowCount)
+
+	return &api.JobResult{
+		GoProfile: api.GoProfileStats{
+			QueryTime: duration,
+		},
+	}, nil
+}
+
+func enableProfiling(ctx context.Context, db *sql.Conn, id string) (string, error) {
 	profileFileName := path.Join(os.TempDir(), id+".json")
 	slog.Info("Generated profile file name", "file", profileFileName)
 
@@ -263,8 +571,8 @@ func enableProfiling(ctx context.Context, db *sql.DB, id string) (string, error)
 	return profileFileName, nil
 }
 
-func disableProfiling(ctx context.Context, db *sql.DB) error {
-	if _, err := db.Exec("PRAGMA disable_profiling;"); err != nil {
+func disableProfiling(ctx context.Context, db *sql.Conn) error {
+	if _, err := db.ExecContext(context.WithoutCancel(ctx), "PRAGMA disable_profiling;"); err != nil {
 		slog.Warn("failed to disable profiling", "error", err)
 	}
 	return nil
@@ -281,28 +589,123 @@ func collectProfileStats(profileFileName string) []byte {
 	return profileBytes
 }
 
-func ExecuteJob(ctx context.Context, job *api.Job, dbPath string) (*api.JobResult, error) {
+// DBConfig holds the resources of a DuckDB instance, zero values keep DuckDB's defaults.
+type DBConfig struct {
+	Threads          int
+	MemoryLimitBytes int64
+	// Slots caps the open connections, one per execution slot.
+	Slots int
+}
+
+// DB is the DuckDB instance shared by the worker's execution slots.
+type DB struct {
+	*sql.DB
+	// settings restore the instance-wide settings the worker configured.
+	settings []string
+}
+
+// OpenDB opens the DuckDB instance shared by the worker's execution slots.
+//
+// Keeping one instance open preserves DuckDB's caches (parquet metadata,
+// object cache) between jobs and shares them between the slots. Every job
+// runs on its own connection and connections are never reused, so
+// connection-local state such as profiling pragmas and temporary objects
+// dies with the job. Settings like threads and memory_limit belong to the
+// instance, a job can change them for everybody, so they're restored after
+// every job. Until then jobs running in the other slots see the change.
+func OpenDB(dbPath string, cfg DBConfig) (*DB, error) {
 	db, err := sql.Open("duckdb", dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("open duckdb: %w", err)
 	}
-	defer db.Close()
+	db.SetMaxIdleConns(0)
+	if cfg.Slots > 0 {
+		db.SetMaxOpenConns(cfg.Slots)
+	}
+
+	var threads, memoryLimit string
+	err = db.QueryRow("SELECT current_setting('threads')::VARCHAR, current_setting('memory_limit')").Scan(&threads, &memoryLimit)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("read duckdb settings: %w", err)
+	}
+	if cfg.Threads > 0 {
+		threads = strconv.Itoa(cfg.Threads)
+	}
+	if cfg.MemoryLimitBytes > 0 {
+		memoryLimit = fmt.Sprintf("%dB", cfg.MemoryLimitBytes)
+	}
+	stmts := []string{
+		"SET GLOBAL enable_object_cache = true",
+		"SET GLOBAL parquet_metadata_cache = true",
+		"SET GLOBAL threads = " + threads,
+		fmt.Sprintf("SET GLOBAL memory_limit = '%s'", memoryLimit),
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("configure duckdb: %w", err)
+		}
+	}
+	return &DB{DB: db, settings: stmts}, nil
+}
+
+// restoreSettings undoes the changes a job made to the instance's settings.
+func (db *DB) restoreSettings(ctx context.Context, conn *sql.Conn) {
+	for _, stmt := range db.settings {
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), stmt); err != nil {
+			slog.Warn("failed to restore duckdb setting", "statement", stmt, "error", err)
+		}
+	}
+}
+
+// ExecuteJob runs the job on a fresh connection of db and returns its result
+// in the job's format. FormatNDJSON jobs have to use StreamJob.
+func ExecuteJob(ctx context.Context, db *DB, job *api.Job) (*api.JobResult, error) {
+	run := runQuery
+	switch {
+	case job.Format == api.FormatArrow:
+		run = runArrowQuery
+	case job.Format.IsExport():
+		run = runExport
+	}
+	return execute(ctx, db, job, run)
+}
+
+// StreamJob runs the job on a fresh connection of db and writes its result
+// to out as it's produced. The returned result carries the profile.
+func StreamJob(ctx context.Context, db *DB, job *api.Job, out io.Writer) (*api.JobResult, error) {
+	return execute(ctx, db, job, func(ctx context.Context, conn *sql.Conn, job *api.Job) (*api.JobResult, error) {
+		return streamQuery(ctx, conn, job, out)
+	})
+}
+
+func execute(ctx context.Context, db *DB, job *api.Job, run func(context.Context, *sql.Conn, *api.Job) (*api.JobResult, error)) (*api.JobResult, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("open duckdb connection: %w", err)
+	}
+	defer conn.Close()
+	defer db.restoreSettings(ctx, conn)
 
 	var profileFileName string
 	if !job.DisableProfiling {
-		if profileFileName, err = enableProfiling(ctx, db, job.ID); err != nil {
+		if profileFileName, err = enableProfiling(ctx, conn, job.ID); err != nil {
 			return nil, fmt.Errorf("enable profiling: %w", err)
 		}
 	}
 
-	result, runSqlErr := runQuery(ctx, db, job)
+	result, runSqlErr := run(ctx, conn, job)
+	if result == nil {
+		result = &api.JobResult{}
+	}
+	result.Timeline.Add(api.StageDuckDBFinished, job.WorkerID)
 	if !job.DisableProfiling {
-		if result == nil {
-			result = &api.JobResult{}
-		}
+		_, span := tracer.Start(ctx, "skein.worker.profile", tracing.String("job.id", job.ID))
 		result.Profile = collectProfileStats(profileFileName)
+		span.End()
 
-		if err = disableProfiling(ctx, db); err != nil {
+		if err = disableProfiling(ctx, conn); err != nil {
 			slog.Warn("failed to disable profiling", "error", err)
 		}
 	}
@@ -310,10 +713,23 @@ func ExecuteJob(ctx context.Context, job *api.Job, dbPath string) (*api.JobResul
 	return result, runSqlErr
 }
 
-func submitResult(proxyURL, jobID string, result *api.JobResult) {
+func (w *Worker) submitResult(jobID string, result *api.JobResult) {
+	if len(result.Arrow) > 0 {
+		if err := w.submitArrowResult(jobID, result); err != nil {
+			slog.Error("failed to submit result to proxy", "job_id", jobID, "error", err)
+		}
+		return
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		slog.Error("failed to marshal result", "job_id", jobID, "error", err)
+		return
+	}
 	payload := map[string]interface{}{
-		"job_id": jobID,
-		"result": result,
+		"worker_id":     w.workerID,
+		"job_id":        jobID,
+		"result":        json.RawMessage(encoded),
+		"serialized_at": time.Now().UTC(),
 	}
 
 	body, err := json.Marshal(payload)
@@ -322,7 +738,7 @@ func submitResult(proxyURL, jobID string, result *api.JobResult) {
 		return
 	}
 
-	resp, err := httpClient.Post(proxyURL+"/internal/job/result", "application/json", bytes.NewBuffer(body))
+	resp, err := httpClient.Post(w.proxyURL+"/internal/job/result", "application/json", bytes.NewBuffer(body))
 	if err != nil {
 		slog.Error("failed to submit result to proxy", "job_id", jobID, "error", err)
 		return
@@ -333,3 +749,35 @@ func submitResult(proxyURL, jobID string, result *api.JobResult) {
 		slog.Error("proxy returned non-OK status for result submission", "job_id", jobID, "status", resp.Status)
 	}
 }
+
+// submitArrowResult sends an Arrow result as the raw IPC stream, the rest of
+// the result goes in api.ResultHeader.
+func (w *Worker) submitArrowResult(jobID string, result *api.JobResult) error {
+	stream := result.Arrow
+	meta := *result
+	meta.Arrow = nil
+	meta.Timeline = append(slices.Clip(meta.Timeline), api.TimelineEntry{
+		Stage: api.StageResultSerialized, At: time.Now().UTC(), WorkerID: w.workerID,
+	})
+	header, err := json.Marshal(&meta)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	reqURL := fmt.Sprintf("%s/internal/job/result?worker_id=%s&job_id=%s", w.proxyURL, w.workerID, jobID)
+	req, err := http.NewRequest(http.MethodPost, reqURL, bytes.NewReader(stream))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", api.ArrowStreamMediaType)
+	req.Header.Set(api.ResultHeader, string(header))
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload arrow result: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("proxy returned %s for arrow result", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+// Package driver is a database/sql driver running queries through the skein
+// proxy. It registers itself as "skein":
+//
+//	db, err := sql.Open("skein", "http://proxy:8080?user=alice&priority=high")
+//	rows, err := db.QueryContext(ctx, "SELECT * FROM trips WHERE passenger_count = $pax", sql.Named("pax", 2))
+//
+// The DSN is the proxy's URL with these optional query parameters: user,
+// api_key, token, priority (low, normal, high or a number), class, size and
+// timeout (a Go duration, 30s by default). The proxy stops waiting for a
+// synchronous query after 30s, so with a longer timeout, or 0 for none,
+// queries are submitted as jobs and their results polled until the timeout.
+// Parameters are passed by name only, there are no transactions, and
+// cancelling a query's context cancels its job. Statements other than
+// queries (SELECT, WITH, FROM, VALUES) and everything run with Exec bypass
+// the proxy's result cache.
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"skein/client"
+	"skein/internal/api"
+	"strings"
+	"time"
+)
+
+func init() {
+	sql.Register("skein", &Driver{})
+}
+
+// Driver implements driver.Driver and driver.DriverContext.
+type Driver struct{}
+
+// Open returns a connection to the proxy of the DSN.
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	connector, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(context.Background())
+}
+
+// OpenConnector parses the DSN once for all connections of a sql.DB.
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	return parseDSN(d, dsn)
+}
+
+type connector struct {
+	driver *Driver
+	client *client.Client
+	// request holds the settings of the DSN every query is sent with.
+	request api.QueryRequest
+}
+
+func parseDSN(d *Driver, dsn string) (*connector, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("parsing DSN: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("DSN %q isn't an http(s) URL", dsn)
+	}
+	params := u.Query()
+	u.RawQuery = ""
+
+	c := &connector{driver: d, client: client.New(u.String())}
+	c.client.UserID = params.Get("user")
+	c.client.APIKey = params.Get("api_key")
+	c.client.Token = params.Get("token")
+	c.client.Timeout = client.QueryTimeout
+	if s := params.Get("timeout"); s != "" {
+		if c.client.Timeout, err = time.ParseDuration(s); err != nil {
+			return nil, fmt.Errorf("invalid timeout %q", s)
+		}
+	}
+	if s := params.Get("priority"); s != "" {
+		if c.request.Priority, err = api.ParsePriority(s); err != nil {
+			return nil, err
+		}
+	} else {
+		c.request.Priority = api.PriorityNormal
+	}
+	c.request.Class = api.QueryClass(params.Get("class"))
+	c.request.Size = api.WorkerSize(params.Get("size"))
+	if !c.request.Size.Valid() {
+		return nil, fmt.Errorf("unknown worker size %q", c.request.Size)
+	}
+	return c, nil
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{connector: c}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// conn is a connection to the proxy, it's stateless since every query is a
+// request of its own.
+type conn struct {
+	connector *connector
+}
+
+var (
+	_ driver.QueryerContext     = (*conn)(nil)
+	_ driver.ExecerContext      = (*conn)(nil)
+	_ driver.NamedValueChecker  = (*conn)(nil)
+	_ driver.ConnPrepareContext = (*conn)(nil)
+)
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
+	return c.Prepare(query)
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, errors.New("skein doesn't support transactions")
+}
+
+// CheckNamedValue accepts the values the worker can bind after they went
+// through JSON.
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nv.Name == "" {
+		return errors.New("skein only supports named parameters, use sql.Named")
+	}
+	if _, ok := nv.Value.([]byte); ok {
+		return fmt.Errorf("parameter %s: binary parameters aren't supported", nv.Name)
+	}
+	var err error
+	nv.Value, err = driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	return err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	results, err := c.run(ctx, query, args, !readOnly(query))
+	if err != nil {
+		return nil, err
+	}
+	return newRows(results)
+}
+
+// ExecContext runs a statement, its RowsAffected is the Count DuckDB returns
+// for INSERT, UPDATE and DELETE. It always runs, even if the proxy cached a
+// result of the same statement.
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	results, err := c.run(ctx, query, args, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(results.ColumnNames) == 1 && results.ColumnNames[0] == "Count" {
+		col := api.AsColumn(results.ColumnData[0])
+		if count, ok := col.Value(0).(int64); ok && col.Len() == 1 {
+			return driver.RowsAffected(count), nil
+		}
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *conn) run(ctx context.Context, query string, args []driver.NamedValue, bypassCache bool) (*api.QueryResults, error) {
+	req := c.connector.request
+	req.Query = query
+	req.BypassCache = bypassCache
+	if len(args) > 0 {
+		req.Params = make(map[string]interface{}, len(args))
+		for _, arg := range args {
+			req.Params[arg.Name] = arg.Value
+		}
+	}
+	// Query is cut off by the proxy after QueryTimeout, longer queries are
+	// submitted as jobs.
+	cl := c.connector.client
+	if timeout := cl.Timeout; timeout <= 0 || timeout > client.QueryTimeout {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return cl.Run(ctx, req)
+	}
+	return cl.Query(ctx, req)
+}
+
+// readOnly reports whether the statement is a query, the only statements
+// whose results the proxy may serve from its cache.
+func readOnly(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToLower(strings.TrimLeft(fields[0], "(")) {
+	case "select", "with", "from", "values":
+		return true
+	}
+	return false
+}
+
+// stmt is a prepared statement, the proxy has none so it only keeps the query.
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("skein only supports named parameters, use ExecContext")
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("skein only supports named parameters, use QueryContext")
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func (s *stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	return s.conn.CheckNamedValue(nv)
+}
@@ -0,0 +1,205 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"skein/client"
+	"skein/internal/api"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestDB(t *testing.T, handler http.HandlerFunc, params string) *sql.DB {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	db, err := sql.Open("skein", server.URL+params)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestQuery(t *testing.T) {
+	var got api.QueryRequest
+	db := openTestDB(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/query", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("X-API-Key"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"column_names":["pax","fare","day","vendor"],
+			"column_types":[{"type":"INTEGER","nullable":true},{"type":"DECIMAL(10,2)"},{"type":"DATE"},{"type":"VARCHAR"}],
+			"column_data":[[2,null],["12.50","0.00"],["2019-04-15","2019-04-16"],["a",""]]}`))
+	}, "?user=alice&api_key=secret&priority=high&class=system")
+
+	rows, err := db.QueryContext(context.Background(), "SELECT * FROM trips WHERE passenger_count = $pax AND day >= $since",
+		sql.Named("pax", 2), sql.Named("since", "2019-04-15"))
+	require.NoError(t, err)
+	defer rows.Close()
+	assert.Equal(t, "alice", got.UserID)
+	assert.Equal(t, api.PriorityHigh, got.Priority)
+	assert.Equal(t, api.ClassSystem, got.Class)
+	assert.Equal(t, map[string]any{"pax": float64(2), "since": "2019-04-15"}, got.Params)
+	assert.False(t, got.BypassCache, "queries may be served from the cache")
+
+	columns, err := rows.ColumnTypes()
+	require.NoError(t, err)
+	assert.Equal(t, "INTEGER", columns[0].DatabaseTypeName())
+	nullable, ok := columns[0].Nullable()
+	assert.True(t, ok)
+	assert.True(t, nullable)
+	precision, scale, ok := columns[1].DecimalSize()
+	assert.True(t, ok)
+	assert.Equal(t, []int64{10, 2}, []int64{precision, scale})
+	assert.Equal(t, "time.Time", columns[2].ScanType().String())
+
+	type trip struct {
+		pax    sql.NullInt64
+		fare   string
+		day    time.Time
+		vendor sql.NullString
+	}
+	var trips []trip
+	for rows.Next() {
+		var tr trip
+		require.NoError(t, rows.Scan(&tr.pax, &tr.fare, &tr.day, &tr.vendor))
+		trips = append(trips, tr)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []trip{
+		{sql.NullInt64{Int64: 2, Valid: true}, "12.50", time.Date(2019, 4, 15, 0, 0, 0, 0, time.UTC), sql.NullString{String: "a", Valid: true}},
+		{sql.NullInt64{}, "0.00", time.Date(2019, 4, 16, 0, 0, 0, 0, time.UTC), sql.NullString{String: "", Valid: true}},
+	}, trips)
+}
+
+func TestQueryTextTypes(t *testing.T) {
+	db := openTestDB(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"column_names":["t","u","b"],
+			"column_types":[{"type":"TIMETZ"},{"type":"UHUGEINT"},{"type":"BIT"}],
+			"column_data":[["12:34:56.5+05:30"],["340282366920938463463374607431768211455"],["0101"]]}`))
+	}, "")
+
+	var (
+		tz   time.Time
+		u    *big.Int
+		bits string
+	)
+	require.NoError(t, db.QueryRowContext(context.Background(), "SELECT t, u, b FROM types").Scan(&tz, &u, &bits))
+	assert.Equal(t, "12:34:56.5 +0530", tz.Format("15:04:05.9 -0700"), "TIMETZ values keep their offset")
+	assert.Equal(t, time.Date(1, 1, 1, 7, 4, 56, 5e8, time.UTC), tz.UTC())
+	assert.Equal(t, "340282366920938463463374607431768211455", u.String())
+	assert.Equal(t, "0101", bits)
+}
+
+func TestExec(t *testing.T) {
+	var bypassed []bool
+	db := openTestDB(t, func(w http.ResponseWriter, r *http.Request) {
+		var req api.QueryRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		bypassed = append(bypassed, req.BypassCache)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"column_names":["Count"],"column_types":[{"type":"BIGINT"}],"column_data":[[3]]}`))
+	}, "")
+	result, err := db.Exec("DELETE FROM trips")
+	require.NoError(t, err)
+	n, err := result.RowsAffected()
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	rows, err := db.Query("INSERT INTO trips VALUES (1) RETURNING *")
+	require.NoError(t, err)
+	rows.Close()
+	assert.Equal(t, []bool{true, true, true}, bypassed, "statements always run")
+}
+
+func TestQueryLongTimeout(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /query", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the proxy cuts synchronous queries off after 30s")
+	})
+	mux.HandleFunc("POST /jobs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"job_id":"j1"}`))
+	})
+	mux.HandleFunc("GET /jobs/j1/result", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"job_id":"j1","column_names":["n"],"column_types":[{"type":"INTEGER"}],"column_data":[[7]]}`))
+	})
+	db := openTestDB(t, mux.ServeHTTP, "?timeout=10m")
+
+	var n int
+	require.NoError(t, db.QueryRow("SELECT 7").Scan(&n))
+	assert.Equal(t, 7, n)
+}
+
+func TestQueryErrors(t *testing.T) {
+	db := openTestDB(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"Catalog Error: Table with name nope does not exist!"}`))
+	}, "")
+
+	_, err := db.Query("SELECT * FROM nope")
+	var queryErr *client.QueryError
+	require.ErrorAs(t, err, &queryErr)
+	assert.Contains(t, queryErr.Message, "nope")
+
+	_, err = db.Query("SELECT $1", 1)
+	assert.ErrorContains(t, err, "named parameters")
+	_, err = db.Begin()
+	assert.Error(t, err)
+}
+
+func TestQueryContextCancelled(t *testing.T) {
+	cancelled := make(chan struct{})
+	db := openTestDB(t, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+		close(cancelled)
+	}, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	_, err := db.QueryContext(ctx, "SELECT * FROM big")
+	assert.True(t, errors.Is(err, context.Canceled), err)
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request to the proxy wasn't cancelled")
+	}
+}
+
+func TestParseDSN(t *testing.T) {
+	c, err := parseDSN(&Driver{}, "https://proxy:8080/?user=bob&token=jwt&priority=5&size=large&timeout=10s")
+	require.NoError(t, err)
+	assert.Equal(t, "https://proxy:8080", c.client.BaseURL)
+	assert.Equal(t, "bob", c.client.UserID)
+	assert.Equal(t, "jwt", c.client.Token)
+	assert.Equal(t, 10*time.Second, c.client.Timeout)
+	assert.Equal(t, api.Priority(5), c.request.Priority)
+	assert.Equal(t, api.SizeLarge, c.request.Size)
+
+	c, err = parseDSN(&Driver{}, "http://proxy")
+	require.NoError(t, err)
+	assert.Equal(t, client.QueryTimeout, c.client.Timeout)
+
+	for _, dsn := range []string{"proxy:8080", "http://proxy?priority=urgent", "http://proxy?timeout=soon", "http://proxy?size=huge"} {
+		_, err := parseDSN(&Driver{}, dsn)
+		assert.Error(t, err, dsn)
+	}
+}
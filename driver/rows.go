@@ -0,0 +1,166 @@
+package driver
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"skein/internal/api"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// rows iterates over the columns of a query's result.
+type rows struct {
+	results *api.QueryResults
+	columns []api.Column
+	types   []*api.DataType
+	row     int
+	len     int
+}
+
+var (
+	_ driver.RowsColumnTypeDatabaseTypeName = (*rows)(nil)
+	_ driver.RowsColumnTypeNullable         = (*rows)(nil)
+	_ driver.RowsColumnTypeScanType         = (*rows)(nil)
+	_ driver.RowsColumnTypePrecisionScale   = (*rows)(nil)
+)
+
+func newRows(results *api.QueryResults) (*rows, error) {
+	r := &rows{
+		results: results,
+		columns: make([]api.Column, len(results.ColumnData)),
+		types:   make([]*api.DataType, len(results.ColumnTypes)),
+	}
+	for i, data := range results.ColumnData {
+		r.columns[i] = api.AsColumn(data)
+	}
+	for i, col := range results.ColumnTypes {
+		var err error
+		if r.types[i], err = api.ParseType(col.Type); err != nil {
+			return nil, fmt.Errorf("unsupported column type: %w", err)
+		}
+	}
+	if len(r.columns) > 0 {
+		r.len = r.columns[0].Len()
+	}
+	return r, nil
+}
+
+func (r *rows) Columns() []string {
+	return r.results.ColumnNames
+}
+
+func (r *rows) Close() error {
+	r.row = r.len
+	return nil
+}
+
+// Next converts the values to the types the DuckDB driver returns, except
+// for UUIDs and DECIMALs, which are strings.
+func (r *rows) Next(dest []driver.Value) error {
+	if r.row >= r.len {
+		return io.EOF
+	}
+	for i, col := range r.columns {
+		dest[i] = driverValue(col.Value(r.row))
+	}
+	r.row++
+	return nil
+}
+
+func driverValue(v any) driver.Value {
+	switch v := v.(type) {
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case api.Date:
+		return v.Time
+	case api.TimeOfDay:
+		return time.Time{}.Add(time.Duration(v))
+	case api.TimeTZ:
+		return time.Date(1, 1, 1, 0, 0, 0, 0, v.Location()).Add(time.Duration(v.Time))
+	case uuid.UUID:
+		return v.String()
+	case api.Decimal:
+		// Scans into strings and floats, with the exact value kept.
+		return v.String()
+	}
+	return v
+}
+
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	return r.results.ColumnTypes[index].Type
+}
+
+func (r *rows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	return r.results.ColumnTypes[index].Nullable, true
+}
+
+func (r *rows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	t := r.types[index]
+	if t.ID != "DECIMAL" {
+		return 0, 0, false
+	}
+	return int64(t.Width), int64(t.Scale), true
+}
+
+// scanTypes are the types Next returns for scalar columns.
+var scanTypes = map[string]reflect.Type{
+	"BOOLEAN":      reflect.TypeFor[bool](),
+	"TINYINT":      reflect.TypeFor[int64](),
+	"SMALLINT":     reflect.TypeFor[int64](),
+	"INTEGER":      reflect.TypeFor[int64](),
+	"BIGINT":       reflect.TypeFor[int64](),
+	"UTINYINT":     reflect.TypeFor[int64](),
+	"USMALLINT":    reflect.TypeFor[int64](),
+	"UINTEGER":     reflect.TypeFor[int64](),
+	"UBIGINT":      reflect.TypeFor[uint64](),
+	"HUGEINT":      reflect.TypeFor[*big.Int](),
+	"UHUGEINT":     reflect.TypeFor[*big.Int](),
+	"FLOAT":        reflect.TypeFor[float64](),
+	"DOUBLE":       reflect.TypeFor[float64](),
+	"DECIMAL":      reflect.TypeFor[string](),
+	"VARCHAR":      reflect.TypeFor[string](),
+	"ENUM":         reflect.TypeFor[string](),
+	"UUID":         reflect.TypeFor[string](),
+	"BLOB":         reflect.TypeFor[[]byte](),
+	"BIT":          reflect.TypeFor[string](),
+	"DATE":         reflect.TypeFor[time.Time](),
+	"TIME":         reflect.TypeFor[time.Time](),
+	"TIMETZ":       reflect.TypeFor[time.Time](),
+	"TIMESTAMP":    reflect.TypeFor[time.Time](),
+	"TIMESTAMPTZ":  reflect.TypeFor[time.Time](),
+	"TIMESTAMP_S":  reflect.TypeFor[time.Time](),
+	"TIMESTAMP_MS": reflect.TypeFor[time.Time](),
+	"TIMESTAMP_NS": reflect.TypeFor[time.Time](),
+	"INTERVAL":     reflect.TypeFor[api.Interval](),
+}
+
+func (r *rows) ColumnTypeScanType(index int) reflect.Type {
+	if typ, ok := scanTypes[r.types[index].ID]; ok {
+		return typ
+	}
+	switch r.types[index].ID {
+	case "LIST", "ARRAY":
+		return reflect.TypeFor[[]any]()
+	case "STRUCT":
+		return reflect.TypeFor[map[string]any]()
+	case "MAP":
+		return reflect.TypeFor[api.Map]()
+	case "UNION":
+		return reflect.TypeFor[api.Union]()
+	}
+	return reflect.TypeFor[any]()
+}
@@ -3,13 +3,13 @@ module skein
 go 1.25.0
 
 require (
+	github.com/apache/arrow-go/v18 v18.4.1
 	github.com/duckdb/duckdb-go/v2 v2.5.2
 	github.com/google/uuid v1.6.0
 	github.com/stretchr/testify v1.11.0
 )
 
 require (
-	github.com/apache/arrow-go/v18 v18.4.1 // indirect
 	github.com/davecgh/go-spew v1.1.2-0.20180830191138-d8f796af33cc // indirect
 	github.com/duckdb/duckdb-go-bindings v0.1.23 // indirect
 	github.com/duckdb/duckdb-go-bindings/darwin-amd64 v0.1.23 // indirect
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"math/big"
+	"math/bits"
+	"reflect"
+)
+
+// Bitmap is the validity bitmap of a column, bit i (LSB first) is set when
+// row i isn't NULL. A nil Bitmap has no NULLs.
+type Bitmap []byte
+
+// NewBitmap returns a bitmap of n rows, all of them NULL.
+func NewBitmap(n int) Bitmap {
+	return make(Bitmap, (n+7)/8)
+}
+
+// Valid reports whether row i isn't NULL.
+func (b Bitmap) Valid(i int) bool {
+	return b == nil || b[i/8]&(1<<(i%8)) != 0
+}
+
+// SetValid marks row i as not NULL.
+func (b Bitmap) SetValid(i int) {
+	b[i/8] |= 1 << (i % 8)
+}
+
+// NullCount returns the number of NULLs among the first n rows.
+func (b Bitmap) NullCount(n int) int {
+	if b == nil {
+		return 0
+	}
+	valid := 0
+	for i := 0; i < n/8; i++ {
+		valid += bits.OnesCount8(b[i])
+	}
+	for i := n / 8 * 8; i < n; i++ {
+		if b.Valid(i) {
+			valid++
+		}
+	}
+	return n - valid
+}
+
+// Slice returns the bitmap of the rows [from, to).
+func (b Bitmap) Slice(from, to int) Bitmap {
+	if b == nil {
+		return nil
+	}
+	sliced := NewBitmap(to - from)
+	for i := from; i < to; i++ {
+		if b.Valid(i) {
+			sliced.SetValid(i - from)
+		}
+	}
+	return sliced
+}
+
+// Column is a decoded result column. Values is a slice of the column's Go
+// type (see DecodeColumn) holding the zero value in NULL rows, Validity
+// tells those rows apart. Its JSON form is an array with null for NULL.
+type Column struct {
+	Values   any
+	Validity Bitmap
+}
+
+// Len returns the number of rows of the column.
+func (c Column) Len() int {
+	if c.Values == nil {
+		return 0
+	}
+	return reflect.ValueOf(c.Values).Len()
+}
+
+// IsNull reports whether row i is NULL.
+func (c Column) IsNull(i int) bool {
+	return !c.Validity.Valid(i)
+}
+
+// NullCount returns the number of NULL rows.
+func (c Column) NullCount() int {
+	return c.Validity.NullCount(c.Len())
+}
+
+// Value returns the value of row i, nil if it's NULL.
+func (c Column) Value(i int) any {
+	if c.IsNull(i) {
+		return nil
+	}
+	return reflect.ValueOf(c.Values).Index(i).Interface()
+}
+
+// Slice returns the rows [from, to) of the column.
+func (c Column) Slice(from, to int) Column {
+	if c.Values == nil {
+		return c
+	}
+	return Column{
+		Values:   reflect.ValueOf(c.Values).Slice(from, to).Interface(),
+		Validity: c.Validity.Slice(from, to),
+	}
+}
+
+func (c Column) MarshalJSON() ([]byte, error) {
+	switch c.Values.(type) {
+	case nil:
+		return []byte("[]"), nil
+	case []*big.Int, []any, [][]any, []map[string]any, []Map, []Union:
+		// HUGEINTs, also nested ones, go out as strings.
+		rows := make([]any, c.Len())
+		for i := range rows {
+			rows[i] = wireJSON(c.Value(i))
+		}
+		return json.Marshal(rows)
+	}
+	if c.Validity == nil {
+		return json.Marshal(c.Values)
+	}
+	rows := make([]any, c.Len())
+	for i := range rows {
+		rows[i] = c.Value(i)
+	}
+	return json.Marshal(rows)
+}
+
+// ColumnValue returns row i of a column of Go type T and false if the row is
+// NULL. It panics if the column's values aren't a []T.
+func ColumnValue[T any](c Column, i int) (T, bool) {
+	if c.IsNull(i) {
+		var zero T
+		return zero, false
+	}
+	return c.Values.([]T)[i], true
+}
+
+// AsColumn returns an entry of JobResult.ColumnData as a Column. Results
+// built by the worker hold []any slices with nil for NULL.
+func AsColumn(data any) Column {
+	switch col := data.(type) {
+	case Column:
+		return col
+	case []any:
+		validity := NewBitmap(len(col))
+		for i, v := range col {
+			if v != nil {
+				validity.SetValid(i)
+			}
+		}
+		if validity.NullCount(len(col)) == 0 {
+			validity = nil
+		}
+		return Column{Values: col, Validity: validity}
+	}
+	return Column{Values: data}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitmap(t *testing.T) {
+	b := NewBitmap(10)
+	for _, i := range []int{0, 3, 8, 9} {
+		b.SetValid(i)
+	}
+	assert.Equal(t, Bitmap{0b1001, 0b11}, b)
+	assert.True(t, b.Valid(3))
+	assert.False(t, b.Valid(4))
+	assert.Equal(t, 6, b.NullCount(10))
+	assert.Equal(t, Bitmap{0b1100001}, b.Slice(3, 10))
+	assert.Equal(t, 4, b.Slice(3, 10).NullCount(7))
+
+	var all Bitmap
+	assert.True(t, all.Valid(100))
+	assert.Zero(t, all.NullCount(100))
+	assert.Nil(t, all.Slice(2, 5))
+}
+
+func TestColumn_NullsRoundTrip(t *testing.T) {
+	columnData := `[[0, null, 7], ["", null, "x"], [false, null, true], [null, "1.50", null], [null, null, null]]`
+	var got JobResult
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"column_types": [{"type":"INTEGER"}, {"type":"VARCHAR"}, {"type":"BOOLEAN"}, {"type":"DECIMAL(4,2)"}, {"type":"DATE"}],
+		"column_data": `+columnData+`}`), &got))
+
+	ints := got.ColumnData[0].(Column)
+	assert.Equal(t, 3, ints.Len())
+	assert.Equal(t, 1, ints.NullCount())
+	v, ok := ColumnValue[int32](ints, 0)
+	assert.True(t, ok, "0 isn't NULL")
+	assert.Equal(t, int32(0), v)
+	_, ok = ColumnValue[int32](ints, 1)
+	assert.False(t, ok)
+	assert.Nil(t, ints.Value(1))
+	assert.Equal(t, int32(7), ints.Value(2))
+
+	strs := got.ColumnData[1].(Column)
+	s, ok := ColumnValue[string](strs, 0)
+	assert.True(t, ok, "an empty string isn't NULL")
+	assert.Equal(t, "", s)
+	assert.True(t, strs.IsNull(1))
+
+	assert.Equal(t, 3, got.ColumnData[4].(Column).NullCount())
+
+	again, err := json.Marshal(got.ColumnData)
+	require.NoError(t, err)
+	assert.JSONEq(t, columnData, string(again))
+}
+
+func TestColumn_Slice(t *testing.T) {
+	col := Column{Values: []int64{1, 0, 3, 0}, Validity: Bitmap{0b0101}}
+	sliced := col.Slice(1, 3)
+	assert.Equal(t, []int64{0, 3}, sliced.Values)
+	assert.True(t, sliced.IsNull(0))
+	assert.False(t, sliced.IsNull(1))
+
+	data, err := json.Marshal(sliced)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[null, 3]`, string(data))
+}
+
+func TestAsColumn(t *testing.T) {
+	col := AsColumn([]any{int32(1), nil})
+	assert.Equal(t, 1, col.NullCount())
+	assert.Equal(t, int32(1), col.Value(0))
+
+	assert.Nil(t, AsColumn([]any{"a"}).Validity)
+	assert.Equal(t, Column{Values: []int64{1}}, AsColumn([]int64{1}))
+	assert.Equal(t, col, AsColumn(col))
+}
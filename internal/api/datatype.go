@@ -0,0 +1,300 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DataType is a parsed DuckDB type name, e.g. DECIMAL(18,3), INTEGER[] or
+// STRUCT("a" VARCHAR, "b" MAP(VARCHAR, DOUBLE)).
+type DataType struct {
+	// ID is the type without its parameters, aliases are resolved: TEXT is
+	// VARCHAR, FLOAT8 is DOUBLE, INTEGER[] is LIST and INTEGER[3] is ARRAY.
+	ID string
+	// Width and Scale of a DECIMAL.
+	Width, Scale int
+	// Elem is the element type of a LIST or ARRAY, Size the length of an ARRAY.
+	Elem *DataType
+	Size int
+	// Fields are the fields of a STRUCT or the members of a UNION.
+	Fields []StructField
+	// Key and Value are the types of a MAP.
+	Key, Value *DataType
+}
+
+// StructField is a named field of a STRUCT or member of a UNION.
+type StructField struct {
+	Name string
+	Type *DataType
+}
+
+var typeAliases = map[string]string{
+	"TEXT":                     "VARCHAR",
+	"STRING":                   "VARCHAR",
+	"CHAR":                     "VARCHAR",
+	"BPCHAR":                   "VARCHAR",
+	"BOOL":                     "BOOLEAN",
+	"LOGICAL":                  "BOOLEAN",
+	"INT1":                     "TINYINT",
+	"INT2":                     "SMALLINT",
+	"SHORT":                    "SMALLINT",
+	"INT":                      "INTEGER",
+	"INT4":                     "INTEGER",
+	"SIGNED":                   "INTEGER",
+	"INT8":                     "BIGINT",
+	"LONG":                     "BIGINT",
+	"INT128":                   "HUGEINT",
+	"UINT8":                    "UTINYINT",
+	"UINT16":                   "USMALLINT",
+	"UINT32":                   "UINTEGER",
+	"UINT64":                   "UBIGINT",
+	"UINT128":                  "UHUGEINT",
+	"REAL":                     "FLOAT",
+	"FLOAT4":                   "FLOAT",
+	"FLOAT8":                   "DOUBLE",
+	"NUMERIC":                  "DECIMAL",
+	"BYTEA":                    "BLOB",
+	"BINARY":                   "BLOB",
+	"VARBINARY":                "BLOB",
+	"BITSTRING":                "BIT",
+	"DATETIME":                 "TIMESTAMP",
+	"TIMESTAMP_US":             "TIMESTAMP",
+	"TIMESTAMP WITH TIME ZONE": "TIMESTAMPTZ",
+	"TIME WITH TIME ZONE":      "TIMETZ",
+}
+
+// ParseType parses a DuckDB type name as reported for a result column.
+func ParseType(name string) (*DataType, error) {
+	p := &typeParser{s: name}
+	t, err := p.parseType()
+	if err != nil {
+		return nil, fmt.Errorf("parsing type %q: %w", name, err)
+	}
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return nil, fmt.Errorf("parsing type %q: unexpected %q at %d", name, p.s[p.pos:], p.pos)
+	}
+	return t, nil
+}
+
+type typeParser struct {
+	s   string
+	pos int
+}
+
+func (p *typeParser) skipSpace() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *typeParser) peek() byte {
+	p.skipSpace()
+	if p.pos == len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *typeParser) expect(c byte) error {
+	if p.peek() != c {
+		return fmt.Errorf("expected %q at %d", c, p.pos)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *typeParser) parseType() (*DataType, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune("([,)]", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	base := strings.ToUpper(strings.Join(strings.Fields(p.s[start:p.pos]), " "))
+	if base == "" {
+		return nil, fmt.Errorf("missing type name at %d", start)
+	}
+	if alias, ok := typeAliases[base]; ok {
+		base = alias
+	}
+	t := &DataType{ID: base}
+
+	if p.peek() == '(' {
+		p.pos++
+		var err error
+		switch base {
+		case "DECIMAL":
+			err = p.parseDecimal(t)
+		case "STRUCT", "UNION":
+			t.Fields, err = p.parseFields()
+		case "MAP":
+			err = p.parseMap(t)
+		default:
+			// Parameters of other types, e.g. the values of an ENUM, don't
+			// change how values are encoded.
+			err = p.skipParameters()
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else if base == "DECIMAL" {
+		t.Width, t.Scale = 18, 3
+	}
+
+	for p.peek() == '[' {
+		p.pos++
+		start := p.pos
+		for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+			p.pos++
+		}
+		digits := p.s[start:p.pos]
+		if err := p.expect(']'); err != nil {
+			return nil, err
+		}
+		if digits == "" {
+			t = &DataType{ID: "LIST", Elem: t}
+		} else {
+			size, _ := strconv.Atoi(digits)
+			t = &DataType{ID: "ARRAY", Elem: t, Size: size}
+		}
+	}
+	return t, nil
+}
+
+func (p *typeParser) parseInt() (int, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	return strconv.Atoi(p.s[start:p.pos])
+}
+
+func (p *typeParser) parseDecimal(t *DataType) error {
+	var err error
+	if t.Width, err = p.parseInt(); err != nil {
+		return fmt.Errorf("invalid DECIMAL width: %w", err)
+	}
+	if p.peek() == ',' {
+		p.pos++
+		if t.Scale, err = p.parseInt(); err != nil {
+			return fmt.Errorf("invalid DECIMAL scale: %w", err)
+		}
+	}
+	return p.expect(')')
+}
+
+func (p *typeParser) parseMap(t *DataType) error {
+	var err error
+	if t.Key, err = p.parseType(); err != nil {
+		return err
+	}
+	if err := p.expect(','); err != nil {
+		return err
+	}
+	if t.Value, err = p.parseType(); err != nil {
+		return err
+	}
+	return p.expect(')')
+}
+
+func (p *typeParser) parseFields() ([]StructField, error) {
+	var fields []StructField
+	for {
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		typ, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, StructField{Name: name, Type: typ})
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ')':
+			p.pos++
+			return fields, nil
+		default:
+			return nil, fmt.Errorf("expected ',' or ')' at %d", p.pos)
+		}
+	}
+}
+
+// parseName parses a field name, bare or in double quotes with "" escaping a quote.
+func (p *typeParser) parseName() (string, error) {
+	if p.peek() != '"' {
+		start := p.pos
+		for p.pos < len(p.s) && p.s[p.pos] != ' ' {
+			p.pos++
+		}
+		if start == p.pos {
+			return "", fmt.Errorf("missing field name at %d", start)
+		}
+		return p.s[start:p.pos], nil
+	}
+	p.pos++
+	var name strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		if c != '"' {
+			name.WriteByte(c)
+			continue
+		}
+		if p.pos < len(p.s) && p.s[p.pos] == '"' {
+			name.WriteByte('"')
+			p.pos++
+			continue
+		}
+		return name.String(), nil
+	}
+	return "", fmt.Errorf("unterminated field name")
+}
+
+// skipParameters skips to the parenthesis closing the parameters, over
+// nested parentheses and quoted strings.
+func (p *typeParser) skipParameters() error {
+	depth := 1
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch c {
+		case '\'', '"':
+			for p.pos < len(p.s) && p.s[p.pos] != c {
+				p.pos++
+			}
+			p.pos++
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("unterminated type parameters")
+}
+
+// String formats the type as DuckDB does.
+func (t *DataType) String() string {
+	switch t.ID {
+	case "DECIMAL":
+		return fmt.Sprintf("DECIMAL(%d,%d)", t.Width, t.Scale)
+	case "LIST":
+		return t.Elem.String() + "[]"
+	case "ARRAY":
+		return fmt.Sprintf("%s[%d]", t.Elem, t.Size)
+	case "MAP":
+		return fmt.Sprintf("MAP(%s, %s)", t.Key, t.Value)
+	case "STRUCT", "UNION":
+		fields := make([]string, len(t.Fields))
+		for i, f := range t.Fields {
+			fields[i] = `"` + strings.ReplaceAll(f.Name, `"`, `""`) + `" ` + f.Type.String()
+		}
+		return t.ID + "(" + strings.Join(fields, ", ") + ")"
+	}
+	return t.ID
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseType(t *testing.T) {
+	for name, want := range map[string]*DataType{
+		"INTEGER":                  {ID: "INTEGER"},
+		"text":                     {ID: "VARCHAR"},
+		"FLOAT8":                   {ID: "DOUBLE"},
+		"TIMESTAMP WITH TIME ZONE": {ID: "TIMESTAMPTZ"},
+		"DECIMAL(38,9)":            {ID: "DECIMAL", Width: 38, Scale: 9},
+		"DECIMAL":                  {ID: "DECIMAL", Width: 18, Scale: 3},
+		"ENUM('a', 'b)')":          {ID: "ENUM"},
+		"INTEGER[]":                {ID: "LIST", Elem: &DataType{ID: "INTEGER"}},
+		"DATE[3][]":                {ID: "LIST", Elem: &DataType{ID: "ARRAY", Size: 3, Elem: &DataType{ID: "DATE"}}},
+		"MAP(VARCHAR, DOUBLE[])":   {ID: "MAP", Key: &DataType{ID: "VARCHAR"}, Value: &DataType{ID: "LIST", Elem: &DataType{ID: "DOUBLE"}}},
+		`STRUCT("a b" INTEGER, """q""" DECIMAL(3,1))[]`: {ID: "LIST", Elem: &DataType{ID: "STRUCT", Fields: []StructField{
+			{Name: "a b", Type: &DataType{ID: "INTEGER"}},
+			{Name: `"q"`, Type: &DataType{ID: "DECIMAL", Width: 3, Scale: 1}},
+		}}},
+		"UNION(num INTEGER, str VARCHAR)": {ID: "UNION", Fields: []StructField{
+			{Name: "num", Type: &DataType{ID: "INTEGER"}},
+			{Name: "str", Type: &DataType{ID: "VARCHAR"}},
+		}},
+	} {
+		got, err := ParseType(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, got, name)
+	}
+
+	for _, name := range []string{"", "INTEGER[", "DECIMAL(a,b)", "MAP(VARCHAR)", `STRUCT("a INTEGER)`, "INTEGER)"} {
+		_, err := ParseType(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestDataType_String(t *testing.T) {
+	for _, name := range []string{"INTEGER", "DECIMAL(38,9)", "DATE[3][]", "MAP(VARCHAR, DOUBLE[])", `STRUCT("a" INTEGER, "b""c" VARCHAR)`} {
+		parsed, err := ParseType(name)
+		require.NoError(t, err)
+		assert.Equal(t, name, parsed.String())
+	}
+}
@@ -0,0 +1,77 @@
+package api
+
+import "fmt"
+
+// ExportOptions tune the file written for FormatParquet and FormatCSV queries.
+type ExportOptions struct {
+	// Compression is the codec, parquet: snappy (default), zstd, gzip, lz4,
+	// brotli, uncompressed; csv: none (default), gzip, zstd.
+	Compression string `json:"compression,omitempty"`
+	// Delimiter is the CSV field separator, a single character, "," by default.
+	Delimiter string `json:"delimiter,omitempty"`
+	// Header writes the CSV header line, true by default.
+	Header *bool `json:"header,omitempty"`
+}
+
+var exportCompressions = map[ResultFormat][]string{
+	FormatParquet: {"snappy", "zstd", "gzip", "lz4", "brotli", "uncompressed"},
+	FormatCSV:     {"none", "gzip", "zstd"},
+}
+
+// IsExport reports whether the result is written to a file instead of being returned.
+func (f ResultFormat) IsExport() bool {
+	return f == FormatParquet || f == FormatCSV
+}
+
+// Validate checks the options for the export format.
+func (o ExportOptions) Validate(format ResultFormat) error {
+	if o.Compression != "" {
+		known := false
+		for _, c := range exportCompressions[format] {
+			known = known || c == o.Compression
+		}
+		if !known {
+			return fmt.Errorf("unknown %s compression %q", format, o.Compression)
+		}
+	}
+	if format != FormatCSV && (o.Delimiter != "" || o.Header != nil) {
+		return fmt.Errorf("delimiter and header are csv options")
+	}
+	if len([]rune(o.Delimiter)) > 1 {
+		return fmt.Errorf("delimiter must be a single character")
+	}
+	return nil
+}
+
+// FileExtension returns the extension of an exported file, including the
+// compression suffix of compressed CSV files.
+func (o ExportOptions) FileExtension(format ResultFormat) string {
+	switch {
+	case format == FormatParquet:
+		return ".parquet"
+	case o.Compression == "gzip":
+		return ".csv.gz"
+	case o.Compression == "zstd":
+		return ".csv.zst"
+	}
+	return ".csv"
+}
+
+// ExportFileName returns the name of the file the job's result is exported to.
+func (j *Job) ExportFileName() string {
+	var opts ExportOptions
+	if j.ExportOptions != nil {
+		opts = *j.ExportOptions
+	}
+	return j.ID + opts.FileExtension(j.Format)
+}
+
+// ExportFile describes a result exported to a file.
+type ExportFile struct {
+	Format    ResultFormat `json:"format"`
+	FileName  string       `json:"file_name"`
+	SizeBytes int64        `json:"size_bytes"`
+	Rows      int64        `json:"rows"`
+	// DownloadURL is the path of the proxy endpoint serving the file.
+	DownloadURL string `json:"download_url,omitempty"`
+}
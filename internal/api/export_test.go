@@ -0,0 +1,39 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportOptions_Validate(t *testing.T) {
+	noHeader := false
+	tests := []struct {
+		format  ResultFormat
+		opts    ExportOptions
+		wantErr bool
+	}{
+		{FormatParquet, ExportOptions{}, false},
+		{FormatParquet, ExportOptions{Compression: "zstd"}, false},
+		{FormatParquet, ExportOptions{Compression: "none"}, true},
+		{FormatParquet, ExportOptions{Delimiter: ";"}, true},
+		{FormatCSV, ExportOptions{Compression: "gzip", Delimiter: "\t", Header: &noHeader}, false},
+		{FormatCSV, ExportOptions{Compression: "snappy"}, true},
+		{FormatCSV, ExportOptions{Delimiter: ";;"}, true},
+	}
+	for _, tt := range tests {
+		err := tt.opts.Validate(tt.format)
+		if tt.wantErr {
+			assert.Error(t, err, "%s %+v", tt.format, tt.opts)
+		} else {
+			assert.NoError(t, err, "%s %+v", tt.format, tt.opts)
+		}
+	}
+}
+
+func TestJob_ExportFileName(t *testing.T) {
+	assert.Equal(t, "j1.parquet", (&Job{ID: "j1", Format: FormatParquet}).ExportFileName())
+	assert.Equal(t, "j1.csv", (&Job{ID: "j1", Format: FormatCSV}).ExportFileName())
+	assert.Equal(t, "j1.csv.gz", (&Job{ID: "j1", Format: FormatCSV, ExportOptions: &ExportOptions{Compression: "gzip"}}).ExportFileName())
+	assert.Equal(t, "j1.parquet", (&Job{ID: "j1", Format: FormatParquet, ExportOptions: &ExportOptions{Compression: "gzip"}}).ExportFileName())
+}
@@ -1,6 +1,9 @@
 package api
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // ProfilingStats holds the extracted profiling information for a query.
 type ProfilingStats struct {
@@ -11,21 +14,45 @@ type ProfilingStats struct {
 	CPUTime           float64 `json:"cpu_time"`
 }
 
+// GoProfileStats holds the times measured around a query, in nanoseconds on
+// the wire: ExecuteTime is the worker's whole run of the job, QueryTime the
+// run of the query and reading its result, DispatchLatency the time the job
+// waited on the proxy before a worker got it.
 type GoProfileStats struct {
-	ExecuteTime       time.Duration
-	QueryTime         time.Duration
-	DispatchLatencyMs int64
+	ExecuteTime     time.Duration `json:"execute_time_ns"`
+	QueryTime       time.Duration `json:"query_time_ns"`
+	DispatchLatency time.Duration `json:"dispatch_latency_ns"`
 }
 
 // QueryResults is the structure of the data returned to the API user.
 // It contains both the query result data and profiling information.
 type QueryResults struct {
+	JobID       string         `json:"job_id,omitempty"`
 	ColumnNames []string       `json:"column_names,omitempty"`
 	ColumnTypes []ColumnType   `json:"column_types,omitempty"`
 	ColumnData  []interface{}  `json:"column_data,omitempty"`
+	Export      *ExportFile    `json:"export,omitempty"`
 	Error       string         `json:"error,omitempty"`
+	CacheHit    bool           `json:"cache_hit"`
 	Profile     ProfilingStats `json:"profile,omitempty"`
 	GoProfile   GoProfileStats `json:"go_profile,omitempty"`
+	Timeline    Timeline       `json:"timeline,omitempty"`
+}
+
+// UnmarshalJSON decodes ColumnData into a Column per column by its type, like
+// JobResult does.
+func (r *QueryResults) UnmarshalJSON(data []byte) error {
+	type plain QueryResults
+	aux := struct {
+		*plain
+		ColumnData []json.RawMessage `json:"column_data,omitempty"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	var err error
+	r.ColumnData, err = decodeColumns(r.ColumnTypes, aux.ColumnData)
+	return err
 }
 
 // This struct is used to extract profiling data from the DuckDB JSON output.
@@ -36,3 +63,14 @@ type DuckDBProfile struct {
 	Latency           float64 `json:"latency"`
 	CPUTime           float64 `json:"cpu_time"`
 }
+
+// ResultPage is a page of a finished job's result. NextCursor continues with
+// the following page and is empty on the last one.
+type ResultPage struct {
+	ColumnNames []string      `json:"column_names,omitempty"`
+	ColumnTypes []ColumnType  `json:"column_types,omitempty"`
+	ColumnData  []interface{} `json:"column_data,omitempty"`
+	Offset      int           `json:"offset"`
+	TotalRows   int           `json:"total_rows"`
+	NextCursor  string        `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,23 @@
+package api
+
+// NDJSONMediaType is the media type of a streamed result, one StreamMessage per line.
+const NDJSONMediaType = "application/x-ndjson"
+
+// StreamMessage is a line of a streamed result. A stream starts with the
+// schema, continues with batches of rows and ends with a single message
+// carrying the outcome of the query.
+type StreamMessage struct {
+	Schema *StreamSchema   `json:"schema,omitempty"`
+	Rows   [][]interface{} `json:"rows,omitempty"`
+	// Result ends the stream a worker uploads to the proxy.
+	Result *JobResult `json:"result,omitempty"`
+	// Trailer ends the stream sent to the client, it holds the error and the
+	// profiling data but no result data.
+	Trailer *QueryResults `json:"trailer,omitempty"`
+}
+
+// StreamSchema describes the columns of a streamed result.
+type StreamSchema struct {
+	ColumnNames []string     `json:"column_names"`
+	ColumnTypes []ColumnType `json:"column_types"`
+}
@@ -0,0 +1,66 @@
+package api
+
+import "time"
+
+// Stage is a step of a job's lifecycle.
+type Stage string
+
+const (
+	// StageReceived is when the proxy received the query.
+	StageReceived Stage = "received"
+	// StageQueued is when the job entered its class' queue, again after a redelivery.
+	StageQueued Stage = "queued"
+	// StageDispatched is when a worker got the job.
+	StageDispatched Stage = "dispatched"
+	// StageWorkerStarted is when the worker started executing the job.
+	StageWorkerStarted Stage = "worker_started"
+	// StageDuckDBFinished is when DuckDB finished the query.
+	StageDuckDBFinished Stage = "duckdb_finished"
+	// StageResultSerialized is when the worker finished encoding the result.
+	StageResultSerialized Stage = "result_serialized"
+	// StageResultReceived is when the proxy received the result.
+	StageResultReceived Stage = "result_received"
+	// StageResponseSent is when the proxy started sending the result to the client.
+	StageResponseSent Stage = "response_sent"
+)
+
+// TimelineEntry records when a job reached a stage. WorkerID is the worker
+// the job was on, empty before the job was dispatched. Worker stages use
+// the worker's clock.
+type TimelineEntry struct {
+	Stage    Stage     `json:"stage"`
+	At       time.Time `json:"at"`
+	WorkerID string    `json:"worker_id,omitempty"`
+}
+
+// Timeline lists the stages a job went through in the order they happened.
+type Timeline []TimelineEntry
+
+// Add appends the stage reached now.
+func (t *Timeline) Add(stage Stage, workerID string) {
+	*t = append(*t, TimelineEntry{Stage: stage, At: time.Now().UTC(), WorkerID: workerID})
+}
+
+// Last returns the latest entry of the stage.
+func (t Timeline) Last(stage Stage) (TimelineEntry, bool) {
+	for i := len(t) - 1; i >= 0; i-- {
+		if t[i].Stage == stage {
+			return t[i], true
+		}
+	}
+	return TimelineEntry{}, false
+}
+
+// Between returns the time from the latest entry of one stage to the latest
+// entry of another, or false if the timeline lacks either.
+func (t Timeline) Between(from, to Stage) (time.Duration, bool) {
+	start, ok := t.Last(from)
+	if !ok {
+		return 0, false
+	}
+	end, ok := t.Last(to)
+	if !ok {
+		return 0, false
+	}
+	return end.At.Sub(start.At), true
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeline(t *testing.T) {
+	start := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	timeline := Timeline{
+		{Stage: StageReceived, At: start},
+		{Stage: StageQueued, At: start.Add(time.Millisecond)},
+		{Stage: StageDispatched, At: start.Add(5 * time.Millisecond), WorkerID: "w1"},
+		{Stage: StageQueued, At: start.Add(time.Second)},
+		{Stage: StageDispatched, At: start.Add(2 * time.Second), WorkerID: "w2"},
+	}
+
+	last, ok := timeline.Last(StageDispatched)
+	require.True(t, ok)
+	assert.Equal(t, "w2", last.WorkerID)
+	_, ok = timeline.Last(StageResponseSent)
+	assert.False(t, ok)
+
+	d, ok := timeline.Between(StageQueued, StageDispatched)
+	require.True(t, ok, "the wait of the last delivery")
+	assert.Equal(t, time.Second, d)
+	_, ok = timeline.Between(StageReceived, StageResultReceived)
+	assert.False(t, ok)
+}
+
+func TestJobResult_UnmarshalTimeline(t *testing.T) {
+	var got JobResult
+	require.NoError(t, json.Unmarshal([]byte(`{"timeline":[{"stage":"worker_started","at":"2026-10-16T12:00:00Z","worker_id":"w1"}]}`), &got))
+	assert.Equal(t, Timeline{{Stage: StageWorkerStarted, At: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC), WorkerID: "w1"}}, got.Timeline)
+}
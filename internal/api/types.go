@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 )
 
@@ -15,13 +16,85 @@ const (
 	PriorityHigh   Priority = 20
 )
 
+// ParsePriority parses a priority name, low, normal or high, or number.
+func ParsePriority(s string) (Priority, error) {
+	switch s {
+	case "low":
+		return PriorityLow, nil
+	case "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid priority %q", s)
+	}
+	return Priority(n), nil
+}
+
+// QueryClass is the scheduling context of a query. Every class has its own
+// queue and its own limit of concurrently running queries.
+type QueryClass string
+
+const (
+	ClassUser   QueryClass = "user"
+	ClassSystem QueryClass = "system"
+)
+
+// Valid reports whether c is a known query class.
+func (c QueryClass) Valid() bool {
+	return c == ClassUser || c == ClassSystem
+}
+
+// ResultFormat is the encoding of a query result.
+type ResultFormat string
+
+const (
+	// FormatJSON returns the result as column slices in QueryResults.
+	FormatJSON ResultFormat = "json"
+	// FormatArrow returns the result as an Arrow IPC stream.
+	FormatArrow ResultFormat = "arrow"
+	// FormatNDJSON streams the result as StreamMessage lines while the query runs.
+	FormatNDJSON ResultFormat = "ndjson"
+	// FormatParquet and FormatCSV export the result to a file, see ExportOptions.
+	FormatParquet ResultFormat = "parquet"
+	FormatCSV     ResultFormat = "csv"
+)
+
+// ArrowStreamMediaType is the media type of the Arrow IPC stream format.
+const ArrowStreamMediaType = "application/vnd.apache.arrow.stream"
+
+// ResultHeader carries the JobResult, without its stream, of a result a
+// worker uploads as a raw Arrow IPC stream.
+const ResultHeader = "X-Skein-Result"
+
+// Valid reports whether f is a known format, the empty format means JSON.
+func (f ResultFormat) Valid() bool {
+	switch f {
+	case "", FormatJSON, FormatArrow, FormatNDJSON, FormatParquet, FormatCSV:
+		return true
+	}
+	return false
+}
+
 // QueryRequest is the structure of a query submission from a client.
 type QueryRequest struct {
-	UserID           string                 `json:"user_id"`
-	Query            string                 `json:"query"`
-	Params           map[string]interface{} `json:"params,omitempty"`
-	Priority         Priority               `json:"priority"`
-	DisableProfiling bool                   `json:"disable_profiling,omitempty"`
+	UserID   string                 `json:"user_id"`
+	Query    string                 `json:"query"`
+	Params   map[string]interface{} `json:"params,omitempty"`
+	Priority Priority               `json:"priority"`
+	Class    QueryClass             `json:"class,omitempty"`
+	// Size is the minimum size of the worker the query should run on.
+	Size WorkerSize `json:"size,omitempty"`
+	// Format is the result encoding, a request with an Accept header asking
+	// for ArrowStreamMediaType or NDJSONMediaType defaults to the matching format.
+	Format           ResultFormat   `json:"format,omitempty"`
+	ExportOptions    *ExportOptions `json:"export_options,omitempty"`
+	DisableProfiling bool           `json:"disable_profiling,omitempty"`
+	// BypassCache runs the query even if the proxy has a cached result, the
+	// fresh result still replaces the cached one.
+	BypassCache bool `json:"bypass_cache,omitempty"`
 }
 
 // QueryResponse is the initial response sent to the client after a query is submitted.
@@ -37,6 +110,7 @@ const (
 	StatusRunning   JobStatus = "running"
 	StatusCompleted JobStatus = "completed"
 	StatusFailed    JobStatus = "failed"
+	StatusCancelled JobStatus = "cancelled"
 )
 
 // Job represents a query to be executed by a worker.
@@ -46,23 +120,45 @@ type Job struct {
 	Query            string                 `json:"query"`
 	Params           map[string]interface{} `json:"params,omitempty"`
 	Priority         Priority               `json:"priority"`
+	Class            QueryClass             `json:"class"`
+	Size             WorkerSize             `json:"size,omitempty"`
+	Format           ResultFormat           `json:"format,omitempty"`
+	ExportOptions    *ExportOptions         `json:"export_options,omitempty"`
 	Status           JobStatus              `json:"status"`
+	WorkerID         string                 `json:"worker_id,omitempty"`
+	Attempts         int                    `json:"attempts,omitempty"`
 	CreatedAt        time.Time              `json:"created_at"`
 	DispatchedAt     time.Time              `json:"dispatched_at"`
 	UpdatedAt        time.Time              `json:"updated_at"`
 	Result           *JobResult             `json:"result,omitempty"`
 	DisableProfiling bool                   `json:"disable_profiling,omitempty"`
+	// CacheHit is set when the result came from the proxy's result cache.
+	CacheHit bool `json:"cache_hit,omitempty"`
+	// CacheKey is the job's key in the proxy's result cache, it never leaves the proxy.
+	CacheKey string `json:"-"`
+	// Traceparent is the W3C trace context the job's spans are children of.
+	Traceparent string   `json:"traceparent,omitempty"`
+	Timeline    Timeline `json:"timeline,omitempty"`
 }
 
 // JobResult holds the outcome of a query's execution.
 // For simplicity, we'll represent results as a JSON raw message.
+// The result of a FormatArrow job is an Arrow IPC stream in Arrow, workers
+// upload it as the raw request body with the rest in ResultHeader.
 type JobResult struct {
-	ColumnNames []string        `json:"column_names,omitempty"`
-	ColumnTypes []ColumnType    `json:"column_types,omitempty"`
-	ColumnData  []interface{}   `json:"column_data,omitempty"`
-	Error       string          `json:"error,omitempty"`
-	Profile     json.RawMessage `json:"profile,omitempty"`
-	GoProfile   GoProfileStats  `json:"go_profile,omitempty"`
+	ColumnNames []string     `json:"column_names,omitempty"`
+	ColumnTypes []ColumnType `json:"column_types,omitempty"`
+	// ColumnData holds a Column per column once decoded, AsColumn also reads
+	// the []interface{} columns the worker builds.
+	ColumnData []interface{}   `json:"column_data,omitempty"`
+	Arrow      []byte          `json:"arrow,omitempty"`
+	Export     *ExportFile     `json:"export,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Cancelled  bool            `json:"cancelled,omitempty"`
+	Profile    json.RawMessage `json:"profile,omitempty"`
+	GoProfile  GoProfileStats  `json:"go_profile,omitempty"`
+	// Timeline holds the stages of the job on the worker.
+	Timeline Timeline `json:"timeline,omitempty"`
 }
 
 func (r *JobResult) UnmarshalJSON(data []byte) error {
@@ -72,64 +168,49 @@ func (r *JobResult) UnmarshalJSON(data []byte) error {
 	}
 	r.ColumnNames = aux.ColumnNames
 	r.ColumnTypes = aux.ColumnTypes
-	r.ColumnData = make([]interface{}, len(aux.ColumnTypes))
+	r.Arrow = aux.Arrow
+	r.Export = aux.Export
 	r.Error = aux.Error
+	r.Cancelled = aux.Cancelled
 	r.Profile = aux.Profile
 	r.GoProfile = aux.GoProfile
+	r.Timeline = aux.Timeline
+
+	var err error
+	r.ColumnData, err = decodeColumns(aux.ColumnTypes, aux.ColumnData)
+	return err
+}
 
-	for i, colType := range r.ColumnTypes {
-		switch colType.Type {
-		case "INTEGER":
-			var col []int32
-			if err := json.Unmarshal(aux.ColumnData[i], &col); err != nil {
-				return err
-			}
-			r.ColumnData[i] = col
-		case "BIGINT":
-			var col []int64
-			if err := json.Unmarshal(aux.ColumnData[i], &col); err != nil {
-				return err
-			}
-			r.ColumnData[i] = col
-		case "REAL", "FLOAT":
-			var col []float32
-			if err := json.Unmarshal(aux.ColumnData[i], &col); err != nil {
-				return err
-			}
-			r.ColumnData[i] = col
-		case "DOUBLE", "FLOAT8":
-			var col []float64
-			if err := json.Unmarshal(aux.ColumnData[i], &col); err != nil {
-				return err
-			}
-			r.ColumnData[i] = col
-		case "TEXT", "VARCHAR":
-			var col []string
-			if err := json.Unmarshal(aux.ColumnData[i], &col); err != nil {
-				return err
-			}
-			r.ColumnData[i] = col
-		case "BOOLEAN":
-			var col []bool
-			if err := json.Unmarshal(aux.ColumnData[i], &col); err != nil {
-				return err
-			}
-			r.ColumnData[i] = col
-		default:
-			return fmt.Errorf("unsupported column type: %s", colType.Type)
+// decodeColumns decodes the JSON arrays of columns of the given types into
+// Columns.
+func decodeColumns(types []ColumnType, data []json.RawMessage) ([]interface{}, error) {
+	columns := make([]interface{}, len(types))
+	for i, colType := range types {
+		t, err := ParseType(colType.Type)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported column type: %w", err)
+		}
+		if i >= len(data) {
+			return nil, fmt.Errorf("missing data of column %d", i)
+		}
+		if columns[i], err = DecodeColumn(t, data[i]); err != nil {
+			return nil, fmt.Errorf("decoding %s column %d: %w", colType.Type, i, err)
 		}
 	}
-
-	return nil
+	return columns, nil
 }
 
 type internalJobResult struct {
 	ColumnNames []string          `json:"column_names,omitempty"`
 	ColumnTypes []ColumnType      `json:"column_types,omitempty"`
 	ColumnData  []json.RawMessage `json:"column_data,omitempty"`
+	Arrow       []byte            `json:"arrow,omitempty"`
+	Export      *ExportFile       `json:"export,omitempty"`
 	Error       string            `json:"error,omitempty"`
+	Cancelled   bool              `json:"cancelled,omitempty"`
 	Profile     json.RawMessage   `json:"profile,omitempty"`
 	GoProfile   GoProfileStats    `json:"go_profile,omitempty"`
+	Timeline    Timeline          `json:"timeline,omitempty"`
 }
 
 // ColumnType holds the type and nullability information for a result column.
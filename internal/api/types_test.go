@@ -3,8 +3,10 @@ package api
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var testData = `{
@@ -28,8 +30,29 @@ func TestInternalJobResult_UnmarshalJSON(t *testing.T) {
 	want := JobResult{
 		ColumnNames: []string{"a", "b", "c"},
 		ColumnTypes: []ColumnType{{Type: "BIGINT"}, {Type: "TEXT"}, {Type: "BOOLEAN"}, {Type: "FLOAT"}},
-		ColumnData:  []interface{}{[]int64{1, 2, 3}, []string{"foo", "bar", "baz"}, []bool{true, false, true}, []float32{1.2, 3.4, 5.6}},
-		Error:       "łóżko",
+		ColumnData: []interface{}{
+			Column{Values: []int64{1, 2, 3}},
+			Column{Values: []string{"foo", "bar", "baz"}},
+			Column{Values: []bool{true, false, true}},
+			Column{Values: []float32{1.2, 3.4, 5.6}},
+		},
+		Error: "łóżko",
 	}
 	assert.Equal(t, want, got, "expected empty result")
 }
+
+func TestParsePriority(t *testing.T) {
+	for s, want := range map[string]Priority{"low": PriorityLow, "normal": PriorityNormal, "high": PriorityHigh, "15": 15, "-1": -1} {
+		got, err := ParsePriority(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, got, s)
+	}
+	_, err := ParsePriority("urgent")
+	assert.Error(t, err)
+}
+
+func TestGoProfileStats_JSON(t *testing.T) {
+	data, err := json.Marshal(GoProfileStats{ExecuteTime: 1500 * time.Microsecond, QueryTime: time.Millisecond, DispatchLatency: 20 * time.Millisecond})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"execute_time_ns":1500000,"query_time_ns":1000000,"dispatch_latency_ns":20000000}`, string(data))
+}
@@ -0,0 +1,484 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Decimal is an exact DECIMAL value, Unscaled * 10^-Scale. Its JSON form is
+// a string like "-12.50", so no client parses it into a float by accident.
+type Decimal struct {
+	Unscaled *big.Int
+	Scale    int
+}
+
+// ParseDecimal parses a decimal number like "-12.50" keeping its scale.
+func ParseDecimal(s string) (Decimal, error) {
+	digits := s
+	scale := 0
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		scale = len(s) - i - 1
+		digits = s[:i] + s[i+1:]
+	}
+	unscaled, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	return Decimal{Unscaled: unscaled, Scale: scale}, nil
+}
+
+func (d Decimal) String() string {
+	if d.Unscaled == nil {
+		return "0"
+	}
+	digits := new(big.Int).Abs(d.Unscaled).String()
+	sign := ""
+	if d.Unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	if d.Scale <= 0 {
+		return sign + digits + strings.Repeat("0", -d.Scale)
+	}
+	if len(digits) <= d.Scale {
+		digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-d.Scale] + "." + digits[len(digits)-d.Scale:]
+}
+
+// Rat returns the exact value as a fraction.
+func (d Decimal) Rat() *big.Rat {
+	r, _ := new(big.Rat).SetString(d.String())
+	return r
+}
+
+// Float64 returns the nearest float64 to the value.
+func (d Decimal) Float64() float64 {
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+// MarshalJSON encodes the decimal as a string.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON decodes the decimal from a string or a number.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	s := string(bytes.Trim(data, `"`))
+	v, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+const dateLayout = "2006-01-02"
+
+// Date is a DATE value, midnight UTC of the day. Its JSON form is "2006-01-02".
+type Date struct {
+	time.Time
+}
+
+// MarshalJSON encodes the date as "2006-01-02".
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Format(dateLayout))
+}
+
+// UnmarshalJSON decodes a "2006-01-02" date.
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil || s == "" {
+		return err
+	}
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return err
+	}
+	d.Time = t
+	return nil
+}
+
+const timeOfDayLayout = "15:04:05.999999"
+
+// TimeOfDay is a TIME value, the time since midnight. Its JSON form is
+// "15:04:05.999999".
+type TimeOfDay time.Duration
+
+func (t TimeOfDay) String() string {
+	return time.Time{}.Add(time.Duration(t)).Format(timeOfDayLayout)
+}
+
+// MarshalJSON encodes the time as "15:04:05.999999".
+func (t TimeOfDay) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON decodes a "15:04:05.999999" time.
+func (t *TimeOfDay) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil || s == "" {
+		return err
+	}
+	parsed, err := time.Parse(timeOfDayLayout, s)
+	if err != nil {
+		return err
+	}
+	*t = TimeOfDay(parsed.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)))
+	return nil
+}
+
+// TimeTZ is a TIMETZ value, a local time of day and its UTC offset in
+// seconds east of UTC. Its JSON form is "15:04:05.999999+05:30" like
+// DuckDB prints it, the minutes and seconds of the offset only when set.
+type TimeTZ struct {
+	Time   TimeOfDay
+	Offset int32
+}
+
+// ParseTimeTZ parses a time with its offset, like "12:34:56.5+05:30" or
+// "01:02:03-08".
+func ParseTimeTZ(s string) (TimeTZ, error) {
+	i := strings.LastIndexAny(s, "+-")
+	if i < 0 {
+		return TimeTZ{}, fmt.Errorf("invalid time with time zone %q", s)
+	}
+	parsed, err := time.Parse(timeOfDayLayout, s[:i])
+	if err != nil {
+		return TimeTZ{}, err
+	}
+	parts := strings.Split(s[i+1:], ":")
+	if len(parts) > 3 {
+		return TimeTZ{}, fmt.Errorf("invalid time zone offset %q", s[i:])
+	}
+	var offset int32
+	for j, unit := range []int32{3600, 60, 1} {
+		if j >= len(parts) {
+			break
+		}
+		n, err := strconv.ParseInt(parts[j], 10, 32)
+		if err != nil || n < 0 || j > 0 && n >= 60 {
+			return TimeTZ{}, fmt.Errorf("invalid time zone offset %q", s[i:])
+		}
+		offset += int32(n) * unit
+	}
+	if s[i] == '-' {
+		offset = -offset
+	}
+	return TimeTZ{Time: TimeOfDay(parsed.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC))), Offset: offset}, nil
+}
+
+func (t TimeTZ) String() string {
+	sign, offset := '+', t.Offset
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	s := fmt.Sprintf("%s%c%02d", t.Time, sign, offset/3600)
+	if offset%3600 != 0 {
+		s += fmt.Sprintf(":%02d", offset/60%60)
+	}
+	if offset%60 != 0 {
+		s += fmt.Sprintf(":%02d", offset%60)
+	}
+	return s
+}
+
+// Location returns the time zone of the offset.
+func (t TimeTZ) Location() *time.Location {
+	return time.FixedZone("", int(t.Offset))
+}
+
+// MarshalJSON encodes the time as "15:04:05.999999+05:30".
+func (t TimeTZ) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON decodes a time with its offset, see ParseTimeTZ.
+func (t *TimeTZ) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil || s == "" {
+		return err
+	}
+	parsed, err := ParseTimeTZ(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// Interval is an INTERVAL value, months and days are kept apart from the
+// time as their length varies.
+type Interval struct {
+	Months int32 `json:"months"`
+	Days   int32 `json:"days"`
+	Micros int64 `json:"micros"`
+}
+
+// MapEntry is a key and its value in a MAP.
+type MapEntry struct {
+	Key   any `json:"key"`
+	Value any `json:"value"`
+}
+
+// Map is a MAP value. It's a list of entries as the keys can be of any type,
+// its JSON form is [{"key": ..., "value": ...}].
+type Map []MapEntry
+
+// Union is a UNION value, the member named Tag holds Value.
+type Union struct {
+	Tag   string `json:"tag"`
+	Value any    `json:"value"`
+}
+
+// scalarTypes are the Go types values of scalar types decode into.
+var scalarTypes = map[string]reflect.Type{
+	"BOOLEAN":  reflect.TypeFor[bool](),
+	"TINYINT":  reflect.TypeFor[int8](),
+	"SMALLINT": reflect.TypeFor[int16](),
+	"INTEGER":  reflect.TypeFor[int32](),
+	"BIGINT":   reflect.TypeFor[int64](),
+	// A []uint8 column would encode as base64.
+	"UTINYINT":     reflect.TypeFor[uint16](),
+	"USMALLINT":    reflect.TypeFor[uint16](),
+	"UINTEGER":     reflect.TypeFor[uint32](),
+	"UBIGINT":      reflect.TypeFor[uint64](),
+	"FLOAT":        reflect.TypeFor[float32](),
+	"DOUBLE":       reflect.TypeFor[float64](),
+	"DECIMAL":      reflect.TypeFor[Decimal](),
+	"VARCHAR":      reflect.TypeFor[string](),
+	"ENUM":         reflect.TypeFor[string](),
+	"BLOB":         reflect.TypeFor[[]byte](),
+	"BIT":          reflect.TypeFor[string](),
+	"UUID":         reflect.TypeFor[uuid.UUID](),
+	"DATE":         reflect.TypeFor[Date](),
+	"TIME":         reflect.TypeFor[TimeOfDay](),
+	"TIMETZ":       reflect.TypeFor[TimeTZ](),
+	"TIMESTAMP":    reflect.TypeFor[time.Time](),
+	"TIMESTAMPTZ":  reflect.TypeFor[time.Time](),
+	"TIMESTAMP_S":  reflect.TypeFor[time.Time](),
+	"TIMESTAMP_MS": reflect.TypeFor[time.Time](),
+	"TIMESTAMP_NS": reflect.TypeFor[time.Time](),
+	"INTERVAL":     reflect.TypeFor[Interval](),
+}
+
+// DecodeColumn decodes the JSON array of a column of type t. The values of
+// scalar types decode into slices of their Go type, with the zero value in
+// NULL rows and the NULLs in the column's Validity:
+//
+//	BOOLEAN                            []bool
+//	TINYINT, SMALLINT, INTEGER, BIGINT []int8, []int16, []int32, []int64
+//	UTINYINT, USMALLINT                []uint16
+//	UINTEGER, UBIGINT                  []uint32, []uint64
+//	HUGEINT, UHUGEINT                  []*big.Int, sent as decimal strings
+//	BIT                                []string of 0s and 1s
+//	FLOAT, DOUBLE                      []float32, []float64
+//	DECIMAL                            []Decimal
+//	VARCHAR, ENUM                      []string
+//	BLOB                               [][]byte
+//	UUID                               []uuid.UUID
+//	DATE                               []Date
+//	TIME                               []TimeOfDay
+//	TIMETZ                             []TimeTZ
+//	TIMESTAMP, TIMESTAMPTZ, ...        []time.Time
+//	INTERVAL                           []Interval
+//
+// Nested types decode into [][]any (LIST and ARRAY), []map[string]any
+// (STRUCT), []Map and []Union; their values are decoded by the same rules,
+// with nil for NULL. Values of other types decode as generic JSON.
+func DecodeColumn(t *DataType, data []byte) (Column, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Column{}, err
+	}
+	col := Column{Validity: NewBitmap(len(raw))}
+	for i, v := range raw {
+		if string(v) != "null" {
+			col.Validity.SetValid(i)
+		}
+	}
+	if col.Validity.NullCount(len(raw)) == 0 {
+		col.Validity = nil
+	}
+
+	var err error
+	if typ, ok := scalarTypes[t.ID]; ok {
+		values := reflect.New(reflect.SliceOf(typ))
+		err = json.Unmarshal(data, values.Interface())
+		col.Values = values.Elem().Interface()
+		return col, err
+	}
+	switch t.ID {
+	case "HUGEINT", "UHUGEINT":
+		col.Values, err = decodeRows[*big.Int](t, raw)
+	case "LIST", "ARRAY":
+		col.Values, err = decodeRows[[]any](t, raw)
+	case "STRUCT":
+		col.Values, err = decodeRows[map[string]any](t, raw)
+	case "MAP":
+		col.Values, err = decodeRows[Map](t, raw)
+	case "UNION":
+		col.Values, err = decodeRows[Union](t, raw)
+	default:
+		col.Values, err = decodeRows[any](t, raw)
+	}
+	return col, err
+}
+
+func decodeRows[T any](t *DataType, raw []json.RawMessage) ([]T, error) {
+	col := make([]T, len(raw))
+	for i, v := range raw {
+		decoded, err := DecodeValue(t, v)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		if decoded != nil {
+			col[i] = decoded.(T)
+		}
+	}
+	return col, nil
+}
+
+// DecodeValue decodes a single JSON value of type t by the rules of
+// DecodeColumn, NULL decodes to nil.
+func DecodeValue(t *DataType, data json.RawMessage) (any, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	if typ, ok := scalarTypes[t.ID]; ok {
+		v := reflect.New(typ)
+		if err := json.Unmarshal(data, v.Interface()); err != nil {
+			return nil, err
+		}
+		return v.Elem().Interface(), nil
+	}
+
+	switch t.ID {
+	case "HUGEINT", "UHUGEINT":
+		return decodeBigInt(data)
+	case "LIST", "ARRAY":
+		var raw []json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+		list := make([]any, len(raw))
+		for i, v := range raw {
+			var err error
+			if list[i], err = DecodeValue(t.Elem, v); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case "STRUCT":
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+		fields := make(map[string]any, len(t.Fields))
+		for _, f := range t.Fields {
+			var err error
+			if fields[f.Name], err = DecodeValue(f.Type, raw[f.Name]); err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.Name, err)
+			}
+		}
+		return fields, nil
+	case "MAP":
+		var raw []struct {
+			Key   json.RawMessage `json:"key"`
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+		m := make(Map, len(raw))
+		for i, e := range raw {
+			var err error
+			if m[i].Key, err = DecodeValue(t.Key, e.Key); err != nil {
+				return nil, err
+			}
+			if m[i].Value, err = DecodeValue(t.Value, e.Value); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case "UNION":
+		var raw struct {
+			Tag   string          `json:"tag"`
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+		for _, member := range t.Fields {
+			if member.Name == raw.Tag {
+				v, err := DecodeValue(member.Type, raw.Value)
+				return Union{Tag: raw.Tag, Value: v}, err
+			}
+		}
+		return nil, fmt.Errorf("unknown UNION member %q", raw.Tag)
+	}
+
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// decodeBigInt decodes a HUGEINT or UHUGEINT. They're sent as decimal strings
+// since JSON clients round numbers above 2^53, bare numbers are accepted too.
+func decodeBigInt(data json.RawMessage) (*big.Int, error) {
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer %s", data)
+	}
+	return n, nil
+}
+
+// wireJSON returns v with the *big.Int values in it as decimal strings, the
+// wire form of HUGEINTs.
+func wireJSON(v any) any {
+	switch v := v.(type) {
+	case *big.Int:
+		if v == nil {
+			return nil
+		}
+		return v.String()
+	case []any:
+		list := make([]any, len(v))
+		for i, e := range v {
+			list[i] = wireJSON(e)
+		}
+		return list
+	case map[string]any:
+		fields := make(map[string]any, len(v))
+		for name, e := range v {
+			fields[name] = wireJSON(e)
+		}
+		return fields
+	case Map:
+		m := make(Map, len(v))
+		for i, e := range v {
+			m[i] = MapEntry{Key: wireJSON(e.Key), Value: wireJSON(e.Value)}
+		}
+		return m
+	case Union:
+		return Union{Tag: v.Tag, Value: wireJSON(v.Value)}
+	}
+	return v
+}
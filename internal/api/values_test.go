@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecimal(t *testing.T) {
+	for s, want := range map[string]Decimal{
+		"12.50":  {Unscaled: big.NewInt(1250), Scale: 2},
+		"-0.05":  {Unscaled: big.NewInt(-5), Scale: 2},
+		"42":     {Unscaled: big.NewInt(42)},
+		"0.0001": {Unscaled: big.NewInt(1), Scale: 4},
+	} {
+		got, err := ParseDecimal(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, got, s)
+		assert.Equal(t, s, got.String())
+	}
+	for _, s := range []string{"", ".", "1.2.3", "1e5", "abc"} {
+		_, err := ParseDecimal(s)
+		assert.Error(t, err, s)
+	}
+
+	d, _ := ParseDecimal("12345678901234567890.123456789")
+	data, err := json.Marshal(d)
+	require.NoError(t, err)
+	assert.Equal(t, `"12345678901234567890.123456789"`, string(data))
+	var back Decimal
+	require.NoError(t, json.Unmarshal(data, &back))
+	assert.Equal(t, d, back)
+	assert.Equal(t, "1/3", Decimal{Unscaled: big.NewInt(1), Scale: 0}.Rat().Quo(big.NewRat(1, 1), big.NewRat(3, 1)).String())
+	assert.Equal(t, -0.05, Decimal{Unscaled: big.NewInt(-5), Scale: 2}.Float64())
+	assert.Equal(t, "0", Decimal{}.String())
+}
+
+func TestDateAndTimeOfDay_JSON(t *testing.T) {
+	var v struct {
+		D Date      `json:"d"`
+		T TimeOfDay `json:"t"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"d":"2024-02-29","t":"23:59:59.000001"}`), &v))
+	assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), v.D.Time)
+	assert.Equal(t, TimeOfDay(24*time.Hour-time.Second+time.Microsecond), v.T)
+
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"d":"2024-02-29","t":"23:59:59.000001"}`, string(data))
+}
+
+func TestTimeTZ_JSON(t *testing.T) {
+	for _, s := range []string{"12:34:56.5+05:30", "01:02:03-08", "00:00:00+00", "10:00:00-15:59:59"} {
+		var v TimeTZ
+		require.NoError(t, json.Unmarshal([]byte(`"`+s+`"`), &v), s)
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		assert.Equal(t, `"`+s+`"`, string(data))
+	}
+
+	v, err := ParseTimeTZ("12:34:56.5+05:30")
+	require.NoError(t, err)
+	assert.Equal(t, TimeTZ{Time: TimeOfDay(12*time.Hour + 34*time.Minute + 56*time.Second + 500*time.Millisecond), Offset: 5*3600 + 30*60}, v)
+	v, err = ParseTimeTZ("01:02:03-08")
+	require.NoError(t, err)
+	assert.Equal(t, int32(-8*3600), v.Offset)
+
+	for _, s := range []string{"12:34:56", "12:34:56+5:xx", "12:34:56+01:60", "25:00:00+00"} {
+		_, err := ParseTimeTZ(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestJobResult_UnmarshalNestedTypes(t *testing.T) {
+	var got JobResult
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"column_types": [{"type":"TIMESTAMP"}, {"type":"STRUCT(\"a\" DATE, \"b\" INTEGER[])"}, {"type":"MAP(INTEGER, VARCHAR)"}, {"type":"GEOMETRY"}],
+		"column_data": [
+			["2024-01-02T03:04:05.5Z", null],
+			[{"a": "2024-01-01", "b": [1, null]}, null],
+			[[{"key": 1, "value": "x"}], []],
+			[{"any": "json"}, 1]
+		]
+	}`), &got))
+
+	assert.Equal(t, Column{Values: []time.Time{time.Date(2024, 1, 2, 3, 4, 5, 5e8, time.UTC), {}}, Validity: Bitmap{0b01}}, got.ColumnData[0])
+	assert.Equal(t, Column{Values: []map[string]any{
+		{"a": Date{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, "b": []any{int32(1), nil}},
+		nil,
+	}, Validity: Bitmap{0b01}}, got.ColumnData[1])
+	assert.Equal(t, Column{Values: []Map{{{Key: int32(1), Value: "x"}}, {}}}, got.ColumnData[2])
+	assert.Equal(t, Column{Values: []any{map[string]any{"any": "json"}, float64(1)}}, got.ColumnData[3], "unknown types decode as generic JSON")
+}
+
+func TestHugeint_JSON(t *testing.T) {
+	typ, err := ParseType("HUGEINT")
+	require.NoError(t, err)
+	col, err := DecodeColumn(typ, []byte(`["-170141183460469231731687303715884105727", 42, null]`))
+	require.NoError(t, err)
+	smallest, _ := new(big.Int).SetString("-170141183460469231731687303715884105727", 10)
+	assert.Equal(t, Column{Values: []*big.Int{smallest, big.NewInt(42), nil}, Validity: Bitmap{0b011}}, col)
+
+	data, err := json.Marshal(col)
+	require.NoError(t, err)
+	assert.JSONEq(t, `["-170141183460469231731687303715884105727", "42", null]`, string(data), "HUGEINTs go out as strings")
+
+	_, err = DecodeColumn(typ, []byte(`["1.5"]`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,98 @@
+package api
+
+import "time"
+
+// WorkerSize is the size class of a worker. A query can ask for a minimum
+// worker size, e.g. heavy aggregations over several months of data should
+// run on large workers.
+type WorkerSize string
+
+const (
+	SizeSmall  WorkerSize = "small"
+	SizeMedium WorkerSize = "medium"
+	SizeLarge  WorkerSize = "large"
+)
+
+func (s WorkerSize) rank() int {
+	switch s {
+	case SizeSmall:
+		return 1
+	case SizeMedium:
+		return 2
+	case SizeLarge:
+		return 3
+	}
+	return 0
+}
+
+// Valid reports whether s is a known size, the empty size is valid and means any size.
+func (s WorkerSize) Valid() bool {
+	return s == "" || s.rank() > 0
+}
+
+// Satisfies reports whether a worker of size s can run a query asking for the required size.
+// A worker without a size counts as small.
+func (s WorkerSize) Satisfies(required WorkerSize) bool {
+	return max(s.rank(), SizeSmall.rank()) >= required.rank()
+}
+
+// Compare orders sizes from the smallest to the largest, a worker without a size counts as small.
+func (s WorkerSize) Compare(other WorkerSize) int {
+	return max(s.rank(), SizeSmall.rank()) - max(other.rank(), SizeSmall.rank())
+}
+
+// WorkerCapacity describes the resources of a worker.
+type WorkerCapacity struct {
+	Size             WorkerSize `json:"size,omitempty"`
+	Threads          int        `json:"threads,omitempty"`
+	MemoryLimitBytes int64      `json:"memory_limit_bytes,omitempty"`
+	// Arrow reports whether the worker can produce FormatArrow results.
+	Arrow bool `json:"arrow,omitempty"`
+}
+
+// WorkerInfo describes a registered worker as listed by GET /workers.
+type WorkerInfo struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Slots is the number of jobs the worker can run at the same time,
+	// Running the number of jobs it holds now.
+	Slots         int            `json:"slots"`
+	Running       int            `json:"running"`
+	Ready         bool           `json:"ready"`
+	Capacity      WorkerCapacity `json:"capacity"`
+	LastHeartbeat time.Time      `json:"last_heartbeat"`
+}
+
+// RegisterWorkerRequest is sent by a worker when it registers with the proxy.
+type RegisterWorkerRequest struct {
+	// Slots is the number of jobs the worker can execute at the same time.
+	Slots    int            `json:"slots"`
+	Capacity WorkerCapacity `json:"capacity"`
+}
+
+// RegisterWorkerResponse is the proxy's answer to a worker registration.
+type RegisterWorkerResponse struct {
+	WorkerID string `json:"worker_id"`
+	// Token authenticates the worker's further requests, it's sent as a
+	// bearer token.
+	Token string `json:"token"`
+}
+
+// HeartbeatRequest is sent periodically by a worker. It renews the leases of
+// the jobs the worker is running.
+type HeartbeatRequest struct {
+	WorkerID string   `json:"worker_id"`
+	JobIDs   []string `json:"job_ids,omitempty"`
+}
+
+// HeartbeatResponse lists the jobs the worker reported but doesn't hold
+// anymore, e.g. because their lease expired and they were redelivered.
+type HeartbeatResponse struct {
+	LostJobIDs []string `json:"lost_job_ids,omitempty"`
+}
+
+// JobAck is sent by a worker to acknowledge a job it received.
+type JobAck struct {
+	WorkerID string `json:"worker_id"`
+	JobID    string `json:"job_id"`
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerSize_Satisfies(t *testing.T) {
+	tests := []struct {
+		worker   WorkerSize
+		required WorkerSize
+		want     bool
+	}{
+		{SizeSmall, "", true},
+		{"", "", true},
+		{"", SizeSmall, true},
+		{"", SizeMedium, false},
+		{SizeSmall, SizeMedium, false},
+		{SizeMedium, SizeMedium, true},
+		{SizeLarge, SizeMedium, true},
+		{SizeMedium, SizeLarge, false},
+		{SizeLarge, SizeLarge, true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.worker.Satisfies(tt.required), "worker %q, required %q", tt.worker, tt.required)
+	}
+}
+
+func TestWorkerSize_Valid(t *testing.T) {
+	assert.True(t, WorkerSize("").Valid())
+	assert.True(t, SizeLarge.Valid())
+	assert.False(t, WorkerSize("huge").Valid())
+}
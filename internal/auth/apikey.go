@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader is the request header carrying an API key.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeys authenticates requests by a static API key, sent in the X-API-Key
+// header or as a bearer token.
+type APIKeys struct {
+	// principals maps the SHA-256 of a key to its principal, so keys are
+	// looked up without comparing them byte by byte.
+	principals map[[sha256.Size]byte]string
+}
+
+// NewAPIKeys creates an authenticator for the keys, mapped to their principals.
+func NewAPIKeys(keys map[string]string) *APIKeys {
+	a := &APIKeys{principals: make(map[[sha256.Size]byte]string, len(keys))}
+	for key, principal := range keys {
+		a.principals[sha256.Sum256([]byte(key))] = principal
+	}
+	return a
+}
+
+// Authenticate implements Authenticator. A bearer token that looks like a
+// JWT is left to the JWT authenticator.
+func (a *APIKeys) Authenticate(r *http.Request) (string, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		token, ok := bearerToken(r)
+		if !ok || strings.Count(token, ".") == 2 {
+			return "", ErrNoCredentials
+		}
+		key = token
+	}
+	principal, ok := a.principals[sha256.Sum256([]byte(key))]
+	if !ok {
+		return "", ErrInvalidCredentials
+	}
+	return principal, nil
+}
+
+// bearerToken returns the token of the request's bearer Authorization header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
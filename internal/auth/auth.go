@@ -0,0 +1,71 @@
+// Package auth authenticates the callers of the proxy's public API.
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator for a request without
+	// credentials it understands.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned for credentials that don't identify anyone.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authenticator identifies the caller of a request.
+type Authenticator interface {
+	// Authenticate returns the principal the request's credentials belong to.
+	Authenticate(r *http.Request) (string, error)
+}
+
+// Chain tries its authenticators in order, the first one finding credentials
+// in the request decides.
+type Chain []Authenticator
+
+// Authenticate implements Authenticator.
+func (c Chain) Authenticate(r *http.Request) (string, error) {
+	for _, a := range c {
+		principal, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return "", ErrNoCredentials
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated principal.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFrom returns the principal of an authenticated request.
+func PrincipalFrom(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(principalKey{}).(string)
+	return principal, ok
+}
+
+// Middleware rejects requests a can't authenticate with 401 and passes the
+// others to next with the principal in their context.
+func Middleware(a Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.Authenticate(r)
+		if err != nil {
+			slog.Warn("unauthenticated request", "event", "auth.failed", "path", r.URL.Path, "error", err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="skein"`)
+			if errors.Is(err, ErrNoCredentials) {
+				http.Error(w, "Authentication required", http.StatusUnauthorized)
+			} else {
+				http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+			}
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	})
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(a Authenticator, header, value string) *httptest.ResponseRecorder {
+	h := Middleware(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := PrincipalFrom(r.Context())
+		w.Write([]byte(principal))
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/query", nil)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware_APIKeys(t *testing.T) {
+	keys := NewAPIKeys(map[string]string{"k-alice": "alice"})
+
+	rec := serve(keys, APIKeyHeader, "k-alice")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "alice", rec.Body.String())
+
+	rec = serve(keys, "Authorization", "Bearer k-alice")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "alice", rec.Body.String())
+
+	rec = serve(keys, APIKeyHeader, "k-mallory")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Invalid credentials")
+
+	rec = serve(keys, "", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Authentication required")
+	assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	_, pemData := newRSAKey(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "idp.pem"), pemData, 0o600))
+	path := filepath.Join(dir, "auth.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"api_keys": [{"key": "k-bob", "user_id": "bob"}],
+		"jwt": {"hmac_secrets": {"hs": "secret"}, "rsa_public_key_files": {"rs": "idp.pem"}, "audience": "skein"}
+	}`), 0o600))
+
+	chain, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Len(t, chain, 2)
+
+	rec := serve(chain, APIKeyHeader, "k-bob")
+	assert.Equal(t, "bob", rec.Body.String())
+
+	token := signHS(t, "HS256", "hs", []byte("secret"), map[string]any{"sub": "carol", "aud": []string{"skein"}})
+	rec = serve(chain, "Authorization", "Bearer "+token)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "carol", rec.Body.String())
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"api_keys": [{"key": "k"}]}`), 0o600))
+	_, err = LoadConfig(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Config is the authentication config file, e.g.
+//
+//	{
+//	  "api_keys": [{"key": "s3cr3t", "user_id": "dashboard"}],
+//	  "jwt": {
+//	    "hmac_secrets": {"default": "shared secret"},
+//	    "rsa_public_key_files": {"idp-2026": "keys/idp.pem"},
+//	    "issuer": "https://idp.example.com",
+//	    "audience": "skein"
+//	  }
+//	}
+//
+// Relative key file paths are relative to the config file.
+type Config struct {
+	APIKeys []APIKeyConfig `json:"api_keys"`
+	JWT     *JWTConfig     `json:"jwt"`
+}
+
+type APIKeyConfig struct {
+	Key    string `json:"key"`
+	UserID string `json:"user_id"`
+}
+
+type JWTConfig struct {
+	HMACSecrets       map[string]string `json:"hmac_secrets"`
+	RSAPublicKeyFiles map[string]string `json:"rsa_public_key_files"`
+	Issuer            string            `json:"issuer"`
+	Audience          string            `json:"audience"`
+}
+
+// LoadConfig reads the config file at path and returns the authenticators
+// it configures, API keys first.
+func LoadConfig(path string) (Chain, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	var chain Chain
+	if len(cfg.APIKeys) > 0 {
+		keys := make(map[string]string, len(cfg.APIKeys))
+		for _, k := range cfg.APIKeys {
+			if k.Key == "" || k.UserID == "" {
+				return nil, errors.New("api_keys entries need a key and a user_id")
+			}
+			keys[k.Key] = k.UserID
+		}
+		chain = append(chain, NewAPIKeys(keys))
+	}
+	if cfg.JWT != nil {
+		j := &JWT{
+			HMACKeys: make(map[string][]byte, len(cfg.JWT.HMACSecrets)),
+			RSAKeys:  make(map[string]*rsa.PublicKey, len(cfg.JWT.RSAPublicKeyFiles)),
+			Issuer:   cfg.JWT.Issuer,
+			Audience: cfg.JWT.Audience,
+		}
+		for kid, secret := range cfg.JWT.HMACSecrets {
+			j.HMACKeys[kid] = []byte(secret)
+		}
+		for kid, file := range cfg.JWT.RSAPublicKeyFiles {
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(filepath.Dir(path), file)
+			}
+			pemData, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			if j.RSAKeys[kid], err = ParseRSAPublicKey(pemData); err != nil {
+				return nil, fmt.Errorf("RSA key %q: %w", kid, err)
+			}
+		}
+		if len(j.HMACKeys) == 0 && len(j.RSAKeys) == 0 {
+			return nil, errors.New("jwt needs hmac_secrets or rsa_public_key_files")
+		}
+		chain = append(chain, j)
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("no authenticators configured")
+	}
+	return chain, nil
+}
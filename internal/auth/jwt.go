@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// jwtLeeway is the clock skew tolerated when checking exp and nbf.
+const jwtLeeway = 30 * time.Second
+
+// JWT authenticates requests by a bearer JSON Web Token signed with one of
+// the configured HMAC secrets or RSA keys. The token's sub claim is the
+// principal.
+type JWT struct {
+	// HMACKeys and RSAKeys are the verification keys by key ID. A token with
+	// a kid header is checked only against the key with that ID.
+	HMACKeys map[string][]byte
+	RSAKeys  map[string]*rsa.PublicKey
+	// Issuer and Audience, when set, have to match the iss and aud claims.
+	Issuer   string
+	Audience string
+
+	now func() time.Time
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Sub string   `json:"sub"`
+	Iss string   `json:"iss"`
+	Aud audience `json:"aud"`
+	Exp *int64   `json:"exp"`
+	Nbf *int64   `json:"nbf"`
+}
+
+// audience is the aud claim, a single string or a list of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+var jwtHashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+}
+
+// Authenticate implements Authenticator.
+func (j *JWT) Authenticate(r *http.Request) (string, error) {
+	token, ok := bearerToken(r)
+	if !ok || strings.Count(token, ".") != 2 {
+		return "", ErrNoCredentials
+	}
+	sub, err := j.Verify(token)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return sub, nil
+}
+
+// Verify checks the token's signature and claims and returns its subject.
+func (j *JWT) Verify(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed token")
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return "", fmt.Errorf("bad header: %w", err)
+	}
+	hash, ok := jwtHashes[header.Alg]
+	if !ok {
+		return "", fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errors.New("bad signature encoding")
+	}
+	if !j.verifySignature(header, hash, parts[0]+"."+parts[1], sig) {
+		return "", errors.New("bad signature")
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return "", fmt.Errorf("bad claims: %w", err)
+	}
+	now := time.Now()
+	if j.now != nil {
+		now = j.now()
+	}
+	if claims.Exp != nil && now.After(time.Unix(*claims.Exp, 0).Add(jwtLeeway)) {
+		return "", errors.New("token expired")
+	}
+	if claims.Nbf != nil && now.Add(jwtLeeway).Before(time.Unix(*claims.Nbf, 0)) {
+		return "", errors.New("token not valid yet")
+	}
+	if j.Issuer != "" && claims.Iss != j.Issuer {
+		return "", fmt.Errorf("unexpected issuer %q", claims.Iss)
+	}
+	if j.Audience != "" && !slices.Contains(claims.Aud, j.Audience) {
+		return "", errors.New("token not meant for this audience")
+	}
+	if claims.Sub == "" {
+		return "", errors.New("token without a subject")
+	}
+	return claims.Sub, nil
+}
+
+// verifySignature checks the signature against the keys matching the
+// token's algorithm and key ID.
+func (j *JWT) verifySignature(header jwtHeader, hash crypto.Hash, signed string, sig []byte) bool {
+	if strings.HasPrefix(header.Alg, "HS") {
+		for kid, key := range j.HMACKeys {
+			if header.Kid != "" && kid != header.Kid {
+				continue
+			}
+			mac := hmac.New(hash.New, key)
+			mac.Write([]byte(signed))
+			if hmac.Equal(mac.Sum(nil), sig) {
+				return true
+			}
+		}
+		return false
+	}
+
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+	for kid, key := range j.RSAKeys {
+		if header.Kid != "" && kid != header.Kid {
+			continue
+		}
+		if rsa.VerifyPKCS1v15(key, hash, digest, sig) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// ParseRSAPublicKey parses a PEM encoded RSA public key, either PKIX
+// ("PUBLIC KEY") or PKCS #1 ("RSA PUBLIC KEY").
+func ParseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("not an RSA key: %T", key)
+	}
+	return rsaKey, nil
+}
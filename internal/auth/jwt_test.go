@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeSegments(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func signHS(t *testing.T, alg, kid string, secret []byte, claims map[string]any) string {
+	t.Helper()
+	signed := encodeSegments(t, alg, kid, claims)
+	mac := hmac.New(jwtHashes[alg].New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS(t *testing.T, alg, kid string, key *rsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+	signed := encodeSegments(t, alg, kid, claims)
+	h := jwtHashes[alg].New()
+	h.Write([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, jwtHashes[alg], h.Sum(nil))
+	require.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func newRSAKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestJWT_Verify(t *testing.T) {
+	rsaKey, pemData := newRSAKey(t)
+	pub, err := ParseRSAPublicKey(pemData)
+	require.NoError(t, err)
+	otherKey, _ := newRSAKey(t)
+
+	now := time.Unix(1_800_000_000, 0)
+	j := &JWT{
+		HMACKeys: map[string][]byte{"hs": []byte("secret")},
+		RSAKeys:  map[string]*rsa.PublicKey{"rs": pub},
+		Issuer:   "idp",
+		now:      func() time.Time { return now },
+	}
+	claims := func(extra map[string]any) map[string]any {
+		c := map[string]any{"sub": "alice", "iss": "idp", "exp": now.Add(time.Hour).Unix()}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+
+	for name, tc := range map[string]struct {
+		token string
+		ok    bool
+	}{
+		"HS256":           {signHS(t, "HS256", "hs", []byte("secret"), claims(nil)), true},
+		"HS512 no kid":    {signHS(t, "HS512", "", []byte("secret"), claims(nil)), true},
+		"RS256":           {signRS(t, "RS256", "rs", rsaKey, claims(nil)), true},
+		"RS384 no kid":    {signRS(t, "RS384", "", rsaKey, claims(nil)), true},
+		"wrong secret":    {signHS(t, "HS256", "hs", []byte("guess"), claims(nil)), false},
+		"wrong RSA key":   {signRS(t, "RS256", "rs", otherKey, claims(nil)), false},
+		"unknown kid":     {signHS(t, "HS256", "other", []byte("secret"), claims(nil)), false},
+		"alg none":        {encodeSegments(t, "none", "", claims(nil)) + ".", false},
+		"expired":         {signHS(t, "HS256", "hs", []byte("secret"), claims(map[string]any{"exp": now.Add(-time.Hour).Unix()})), false},
+		"not yet valid":   {signHS(t, "HS256", "hs", []byte("secret"), claims(map[string]any{"nbf": now.Add(time.Hour).Unix()})), false},
+		"wrong issuer":    {signHS(t, "HS256", "hs", []byte("secret"), claims(map[string]any{"iss": "evil"})), false},
+		"without subject": {signHS(t, "HS256", "hs", []byte("secret"), claims(map[string]any{"sub": ""})), false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			sub, err := j.Verify(tc.token)
+			if tc.ok {
+				require.NoError(t, err)
+				assert.Equal(t, "alice", sub)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestJWT_HMACTokenSignedWithRSAPublicKey(t *testing.T) {
+	_, pemData := newRSAKey(t)
+	pub, err := ParseRSAPublicKey(pemData)
+	require.NoError(t, err)
+	j := &JWT{RSAKeys: map[string]*rsa.PublicKey{"rs": pub}}
+
+	// The classic algorithm confusion attack: an HS256 token keyed with the
+	// RSA public key must not pass as RS256.
+	token := signHS(t, "HS256", "rs", pemData, map[string]any{"sub": "mallory"})
+	_, err = j.Verify(token)
+	assert.Error(t, err)
+}
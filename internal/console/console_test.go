@@ -0,0 +1,41 @@
+package console
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	server := httptest.NewServer(http.StripPrefix("/console", Handler()))
+	defer server.Close()
+
+	tests := []struct {
+		path        string
+		contentType string
+		contains    string
+	}{
+		{"/console/", "text/html; charset=utf-8", `<textarea id="sql"`},
+		{"/console/app.js", "text/javascript; charset=utf-8", `request("POST", "/jobs"`},
+		{"/console/style.css", "text/css; charset=utf-8", "#grid"},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(server.URL + tt.path)
+		require.NoError(t, err, tt.path)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, tt.path)
+		assert.Equal(t, tt.contentType, resp.Header.Get("Content-Type"), tt.path)
+		assert.Equal(t, "default-src 'self'", resp.Header.Get("Content-Security-Policy"), tt.path)
+		assert.Contains(t, string(body), tt.contains, tt.path)
+	}
+
+	resp, err := http.Get(server.URL + "/console/missing.js")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,291 @@
+// The skein console: submits the editor's query as a job, polls its result
+// and renders the columns and the profile. It only uses the public API.
+"use strict";
+
+const MAX_GRID_ROWS = 1000;
+const POLL_INTERVAL_MS = 250;
+const NUMERIC_TYPE = /^(U?(TINYINT|SMALLINT|INTEGER|BIGINT|HUGEINT)|FLOAT|DOUBLE|DECIMAL)\b/;
+
+const $ = (id) => document.getElementById(id);
+
+let currentJob = null;
+
+// Settings persisted in the browser, credentials only for the session.
+const SECRETS = new Set(["api-key", "token"]);
+const storage = (key) => (SECRETS.has(key) ? sessionStorage : localStorage);
+const stored = {
+  get: (key, fallback = "") => storage(key).getItem("skein." + key) ?? fallback,
+  set: (key, value) => storage(key).setItem("skein." + key, value),
+};
+
+function headers() {
+  const h = { "Content-Type": "application/json" };
+  const apiKey = $("api-key").value.trim();
+  const token = $("token").value.trim();
+  if (apiKey) h["X-API-Key"] = apiKey;
+  if (token) h["Authorization"] = "Bearer " + token;
+  return h;
+}
+
+async function request(method, path, body) {
+  const resp = await fetch(path, {
+    method,
+    headers: headers(),
+    body: body === undefined ? undefined : JSON.stringify(body),
+  });
+  const text = await resp.text();
+  let data = null;
+  if ((resp.headers.get("Content-Type") || "").startsWith("application/json")) {
+    try {
+      data = JSON.parse(text);
+    } catch {
+      // Keep the raw text for the error message.
+    }
+  }
+  return { status: resp.status, data, text: text.trim() };
+}
+
+// parseValue reads a parameter the way the skein CLI does: JSON if it parses,
+// a string otherwise.
+function parseValue(s) {
+  try {
+    return JSON.parse(s);
+  } catch {
+    return s;
+  }
+}
+
+function collectParams() {
+  const params = {};
+  for (const row of document.querySelectorAll("#param-rows .param")) {
+    const name = row.querySelector(".param-name").value.trim();
+    if (name) params[name] = parseValue(row.querySelector(".param-value").value);
+  }
+  return Object.keys(params).length ? params : undefined;
+}
+
+function addParam(name = "", value = "") {
+  const row = $("param-row").content.firstElementChild.cloneNode(true);
+  row.querySelector(".param-name").value = name;
+  row.querySelector(".param-value").value = value;
+  row.querySelector(".remove-param").addEventListener("click", () => row.remove());
+  $("param-rows").append(row);
+  return row;
+}
+
+// addMissingParams adds an input for every $name of the query without one.
+function addMissingParams() {
+  const names = new Set([...document.querySelectorAll("#param-rows .param-name")].map((el) => el.value.trim()));
+  for (const [, name] of $("sql").value.matchAll(/\$([A-Za-z_][A-Za-z0-9_]*)/g)) {
+    if (!names.has(name)) {
+      addParam(name);
+      names.add(name);
+    }
+  }
+}
+
+function setRunning(running) {
+  $("run").disabled = running;
+  $("cancel").disabled = !running;
+}
+
+function setStatus(text) {
+  $("status").textContent = text;
+}
+
+function showError(message) {
+  $("error").textContent = message;
+  $("error").hidden = false;
+}
+
+function clearOutput() {
+  $("error").hidden = true;
+  $("grid").replaceChildren();
+  $("grid-note").textContent = "";
+  $("profile").hidden = true;
+}
+
+function errorMessage(resp) {
+  return (resp.data && resp.data.error) || resp.text || "HTTP " + resp.status;
+}
+
+async function run() {
+  const query = $("sql").value.trim();
+  if (!query) return;
+  clearOutput();
+  setRunning(true);
+  const started = performance.now();
+  try {
+    const submitted = await request("POST", "/jobs", {
+      user_id: $("user-id").value.trim(),
+      query,
+      priority: Number($("priority").value),
+      class: $("class").value,
+      params: collectParams(),
+    });
+    if (submitted.status !== 202) {
+      showError(errorMessage(submitted));
+      setStatus("");
+      return;
+    }
+    currentJob = submitted.data.job_id;
+    const resp = await poll(currentJob);
+    const elapsed = ((performance.now() - started) / 1000).toFixed(2);
+    if (resp.status === 200 && resp.data) {
+      renderGrid(resp.data);
+      setStatus(`job ${currentJob} finished in ${elapsed}s`);
+    } else {
+      showError(errorMessage(resp));
+      setStatus(`job ${currentJob} ${resp.status === 409 ? "cancelled" : "failed"} after ${elapsed}s`);
+    }
+    if (resp.data) renderProfile(resp.data);
+  } catch (err) {
+    showError(String(err));
+    setStatus("");
+  } finally {
+    currentJob = null;
+    setRunning(false);
+  }
+}
+
+// poll waits for the result of a job, the proxy answers 202 while it's queued
+// or running.
+async function poll(jobID) {
+  for (;;) {
+    const resp = await request("GET", `/jobs/${encodeURIComponent(jobID)}/result`);
+    if (resp.status !== 202) return resp;
+    setStatus(`job ${jobID} ${resp.data ? resp.data.status : "running"}…`);
+    await new Promise((resolve) => setTimeout(resolve, POLL_INTERVAL_MS));
+  }
+}
+
+async function cancel() {
+  if (!currentJob) return;
+  const resp = await request("DELETE", `/jobs/${encodeURIComponent(currentJob)}`);
+  if (resp.status !== 200 && resp.status !== 409) showError(errorMessage(resp));
+}
+
+function formatCell(value) {
+  if (value === null || value === undefined) return null;
+  if (typeof value === "object") return JSON.stringify(value);
+  return String(value);
+}
+
+function renderGrid(results) {
+  const names = results.column_names || [];
+  const types = results.column_types || [];
+  const data = results.column_data || [];
+  const rows = data.length ? data[0].length : 0;
+  const numeric = types.map((t) => NUMERIC_TYPE.test(t.type));
+
+  const table = document.createElement("table");
+  const head = table.createTHead().insertRow();
+  names.forEach((name, i) => {
+    const th = document.createElement("th");
+    th.textContent = name;
+    const type = document.createElement("span");
+    type.className = "type";
+    type.textContent = types[i] ? types[i].type : "";
+    th.append(type);
+    head.append(th);
+  });
+  const body = table.createTBody();
+  for (let r = 0; r < Math.min(rows, MAX_GRID_ROWS); r++) {
+    const tr = body.insertRow();
+    for (let c = 0; c < data.length; c++) {
+      const td = tr.insertCell();
+      const text = formatCell(data[c][r]);
+      if (text === null) {
+        td.textContent = "NULL";
+        td.className = "null";
+      } else {
+        td.textContent = text;
+        if (numeric[c]) td.className = "number";
+      }
+    }
+  }
+  $("grid").replaceChildren(table);
+  $("grid-note").textContent =
+    rows > MAX_GRID_ROWS ? `Showing the first ${MAX_GRID_ROWS} of ${rows} rows.` : `${rows} row${rows === 1 ? "" : "s"}`;
+}
+
+function ms(value) {
+  return value.toFixed(value < 10 ? 2 : 0) + " ms";
+}
+
+function bytes(n) {
+  const units = ["B", "KiB", "MiB", "GiB", "TiB"];
+  let i = 0;
+  while (n >= 1024 && i < units.length - 1) {
+    n /= 1024;
+    i++;
+  }
+  return (i ? n.toFixed(1) : n) + " " + units[i];
+}
+
+function renderProfile(results) {
+  const profile = results.profile || {};
+  const goProfile = results.go_profile || {};
+  const stats = [
+    ["Job", results.job_id || currentJob || "-"],
+    ["Cache hit", results.cache_hit ? "yes" : "no"],
+    ["Rows returned", profile.rows_returned ?? 0],
+    ["DuckDB latency", ms((profile.latency || 0) * 1000)],
+    ["DuckDB CPU time", ms((profile.cpu_time || 0) * 1000)],
+    ["Bytes read", bytes(profile.total_bytes_read || 0)],
+    ["Bytes written", bytes(profile.total_bytes_written || 0)],
+    // time.Duration values are nanoseconds.
+    ["Worker execute time", ms((goProfile.execute_time_ns || 0) / 1e6)],
+    ["Worker query time", ms((goProfile.query_time_ns || 0) / 1e6)],
+    ["Dispatch latency", ms((goProfile.dispatch_latency_ns || 0) / 1e6)],
+  ];
+  const dl = $("profile-stats");
+  dl.replaceChildren();
+  for (const [name, value] of stats) {
+    const dt = document.createElement("dt");
+    dt.textContent = name;
+    const dd = document.createElement("dd");
+    dd.textContent = value;
+    dl.append(dt, dd);
+  }
+
+  const timeline = results.timeline || [];
+  const start = timeline.length ? Date.parse(timeline[0].at) : 0;
+  $("timeline").replaceChildren(
+    ...timeline.map((entry) => {
+      const li = document.createElement("li");
+      li.textContent = `+${ms(Date.parse(entry.at) - start)} ${entry.stage}` + (entry.worker_id ? ` (${entry.worker_id})` : "");
+      return li;
+    }),
+  );
+  $("profile").hidden = false;
+}
+
+function init() {
+  for (const id of ["user-id", "api-key", "token", "sql", "priority", "class"]) {
+    const el = $(id);
+    el.value = stored.get(id, el.value);
+    el.addEventListener("change", () => stored.set(id, el.value));
+  }
+  $("sql").addEventListener("input", () => stored.set("sql", $("sql").value));
+  $("sql").addEventListener("blur", addMissingParams);
+  $("sql").addEventListener("keydown", (e) => {
+    if (e.key === "Enter" && (e.ctrlKey || e.metaKey)) {
+      e.preventDefault();
+      addMissingParams();
+      run();
+    } else if (e.key === "Tab") {
+      e.preventDefault();
+      e.target.setRangeText("  ", e.target.selectionStart, e.target.selectionEnd, "end");
+    }
+  });
+  $("run").addEventListener("click", () => {
+    addMissingParams();
+    run();
+  });
+  $("cancel").addEventListener("click", cancel);
+  $("add-param").addEventListener("click", () => addParam().querySelector(".param-name").focus());
+  addMissingParams();
+}
+
+init();
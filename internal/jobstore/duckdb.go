@@ -0,0 +1,180 @@
+// Package jobstore persists the proxy's jobs in an embedded database.
+package jobstore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"skein/internal/api"
+	"sync"
+
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS jobs (
+	id         VARCHAR PRIMARY KEY,
+	status     VARCHAR NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	job        VARCHAR NOT NULL,
+	result     VARCHAR
+);
+CREATE TABLE IF NOT EXISTS job_transitions (
+	job_id     VARCHAR NOT NULL,
+	status     VARCHAR NOT NULL,
+	worker_id  VARCHAR,
+	attempts   INTEGER NOT NULL,
+	changed_at TIMESTAMP NOT NULL
+);`
+
+// DuckDB keeps jobs in a DuckDB database file: the latest state of every job
+// in the jobs table and every status it went through in job_transitions.
+type DuckDB struct {
+	db          *sql.DB
+	keepResults bool
+
+	mu sync.Mutex
+	// saved is what the database holds of every job, so Save only writes
+	// what changed.
+	saved map[string]savedJob
+}
+
+type savedJob struct {
+	status api.JobStatus
+	result *api.JobResult
+}
+
+// OpenDuckDB opens or creates the database at path. With keepResults the
+// result data of completed jobs is stored too, otherwise only small results,
+// like errors and exported files, are.
+func OpenDuckDB(path string, keepResults bool) (*DuckDB, error) {
+	db, err := sql.Open("duckdb", path)
+	if err != nil {
+		return nil, fmt.Errorf("opening job database %q: %w", path, err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating job tables: %w", err)
+	}
+	return &DuckDB{db: db, keepResults: keepResults, saved: make(map[string]savedJob)}, nil
+}
+
+// Save stores the current state of the job and records its status if it
+// changed. The result is only written when the job has a new one, a job's
+// result is never changed in place.
+func (d *DuckDB) Save(job *api.Job) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	prev, known := d.saved[job.ID]
+
+	stored := *job
+	stored.Result = nil
+	jobJSON, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("encoding job: %w", err)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if known && prev.result == job.Result {
+		if _, err := tx.Exec(`UPDATE jobs SET status = ?, updated_at = ?, job = ? WHERE id = ?`,
+			job.Status, job.UpdatedAt, string(jobJSON), job.ID); err != nil {
+			return fmt.Errorf("saving job: %w", err)
+		}
+	} else {
+		var resultJSON sql.NullString
+		if r := job.Result; r != nil && (d.keepResults || (len(r.ColumnData) == 0 && len(r.Arrow) == 0)) {
+			b, err := json.Marshal(r)
+			if err != nil {
+				return fmt.Errorf("encoding result: %w", err)
+			}
+			resultJSON = sql.NullString{String: string(b), Valid: true}
+		}
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO jobs VALUES (?, ?, ?, ?, ?, ?)`,
+			job.ID, job.Status, job.CreatedAt, job.UpdatedAt, string(jobJSON), resultJSON); err != nil {
+			return fmt.Errorf("saving job: %w", err)
+		}
+	}
+	if !known || prev.status != job.Status {
+		if _, err := tx.Exec(`INSERT INTO job_transitions VALUES (?, ?, ?, ?, ?)`,
+			job.ID, job.Status, sql.NullString{String: job.WorkerID, Valid: job.WorkerID != ""}, job.Attempts, job.UpdatedAt); err != nil {
+			return fmt.Errorf("saving job transition: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	d.saved[job.ID] = savedJob{status: job.Status, result: job.Result}
+	return nil
+}
+
+// Load returns all stored jobs in submission order.
+func (d *DuckDB) Load() ([]*api.Job, error) {
+	rows, err := d.db.Query(`SELECT job, result FROM jobs ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*api.Job
+	for rows.Next() {
+		var (
+			jobJSON    string
+			resultJSON sql.NullString
+		)
+		if err := rows.Scan(&jobJSON, &resultJSON); err != nil {
+			return nil, err
+		}
+		var job api.Job
+		if err := json.Unmarshal([]byte(jobJSON), &job); err != nil {
+			return nil, fmt.Errorf("decoding job: %w", err)
+		}
+		if resultJSON.Valid {
+			job.Result = &api.JobResult{}
+			if err := json.Unmarshal([]byte(resultJSON.String), job.Result); err != nil {
+				return nil, fmt.Errorf("decoding result of job %s: %w", job.ID, err)
+			}
+		}
+		jobs = append(jobs, &job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, job := range jobs {
+		d.saved[job.ID] = savedJob{status: job.Status, result: job.Result}
+	}
+	return jobs, nil
+}
+
+// Delete removes the job and its transitions.
+func (d *DuckDB) Delete(jobID string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM jobs WHERE id = ?`, jobID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM job_transitions WHERE job_id = ?`, jobID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	delete(d.saved, jobID)
+	d.mu.Unlock()
+	return nil
+}
+
+// Close closes the database.
+func (d *DuckDB) Close() error {
+	return d.db.Close()
+}
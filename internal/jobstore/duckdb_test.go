@@ -0,0 +1,100 @@
+package jobstore
+
+import (
+	"path/filepath"
+	"skein/internal/api"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuckDB_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.duckdb")
+	d, err := OpenDuckDB(path, true)
+	require.NoError(t, err)
+
+	now := time.Now().UTC()
+	queued := &api.Job{ID: "queued", UserID: "u1", Query: "SELECT 1", Status: api.StatusPending, CreatedAt: now, UpdatedAt: now}
+	done := &api.Job{ID: "done", UserID: "u1", Query: "SELECT 2 AS n", Status: api.StatusPending, CreatedAt: now.Add(-time.Second), UpdatedAt: now}
+	require.NoError(t, d.Save(queued))
+	require.NoError(t, d.Save(done))
+	done.Status = api.StatusRunning
+	done.WorkerID = "w1"
+	require.NoError(t, d.Save(done))
+	done.Status = api.StatusCompleted
+	done.Result = &api.JobResult{
+		ColumnNames: []string{"n"},
+		ColumnTypes: []api.ColumnType{{Type: "INTEGER"}},
+		ColumnData:  []interface{}{[]int32{2}},
+	}
+	require.NoError(t, d.Save(done))
+
+	var transitions int
+	require.NoError(t, d.db.QueryRow(`SELECT count(*) FROM job_transitions WHERE job_id = 'done'`).Scan(&transitions))
+	assert.Equal(t, 3, transitions)
+	require.NoError(t, d.Close())
+
+	d, err = OpenDuckDB(path, true)
+	require.NoError(t, err)
+	defer d.Close()
+	jobs, err := d.Load()
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "done", jobs[0].ID, "jobs are loaded in submission order")
+	assert.Equal(t, api.StatusCompleted, jobs[0].Status)
+	assert.Equal(t, "w1", jobs[0].WorkerID)
+	require.NotNil(t, jobs[0].Result)
+	assert.Equal(t, []interface{}{api.Column{Values: []int32{2}}}, jobs[0].Result.ColumnData)
+	assert.Equal(t, api.StatusPending, jobs[1].Status)
+	assert.Nil(t, jobs[1].Result)
+
+	require.NoError(t, d.Delete("done"))
+	jobs, err = d.Load()
+	require.NoError(t, err)
+	assert.Len(t, jobs, 1)
+}
+
+func TestDuckDB_WithoutResults(t *testing.T) {
+	d, err := OpenDuckDB(filepath.Join(t.TempDir(), "jobs.duckdb"), false)
+	require.NoError(t, err)
+	defer d.Close()
+
+	now := time.Now().UTC()
+	require.NoError(t, d.Save(&api.Job{ID: "data", Status: api.StatusCompleted, CreatedAt: now, UpdatedAt: now,
+		Result: &api.JobResult{ColumnNames: []string{"n"}, ColumnTypes: []api.ColumnType{{Type: "BIGINT"}}, ColumnData: []interface{}{[]int64{1}}}}))
+	require.NoError(t, d.Save(&api.Job{ID: "failed", Status: api.StatusFailed, CreatedAt: now.Add(time.Second), UpdatedAt: now,
+		Result: &api.JobResult{Error: "boom"}}))
+
+	jobs, err := d.Load()
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Nil(t, jobs[0].Result, "result data is not stored")
+	require.NotNil(t, jobs[1].Result)
+	assert.Equal(t, "boom", jobs[1].Result.Error)
+}
+
+func TestDuckDB_SaveWritesOnlyChanges(t *testing.T) {
+	d, err := OpenDuckDB(filepath.Join(t.TempDir(), "jobs.duckdb"), true)
+	require.NoError(t, err)
+	defer d.Close()
+
+	now := time.Now().UTC()
+	job := &api.Job{ID: "j", Status: api.StatusCompleted, CreatedAt: now, UpdatedAt: now,
+		Result: &api.JobResult{Error: "boom"}}
+	require.NoError(t, d.Save(job))
+	job.Result.Error = "changed in memory only"
+	job.Timeline = append(job.Timeline, api.TimelineEntry{Stage: "cached", At: now})
+	require.NoError(t, d.Save(job))
+
+	var transitions int
+	require.NoError(t, d.db.QueryRow(`SELECT count(*) FROM job_transitions WHERE job_id = 'j'`).Scan(&transitions))
+	assert.Equal(t, 1, transitions, "saves without a status change add no transition")
+
+	jobs, err := d.Load()
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Len(t, jobs[0].Timeline, 1)
+	assert.Equal(t, "boom", jobs[0].Result.Error, "the same result is not written again")
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_TextFormat(t *testing.T) {
+	r := NewRegistry()
+	latency := r.NewHistogram("latency_seconds", "Query latency.", []float64{0.1, 1})
+	r.NewGaugeFunc("queue_depth", "Queued jobs.", []string{"priority"}, func(set func(float64, ...string)) {
+		set(3, "10")
+		set(1, `a"b`)
+	})
+
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(5)
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+	assert.Equal(t, `# HELP latency_seconds Query latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 5.55
+latency_seconds_count 3
+# HELP queue_depth Queued jobs.
+# TYPE queue_depth gauge
+queue_depth{priority="10"} 3
+queue_depth{priority="a\"b"} 1
+`, rec.Body.String())
+}
+
+func TestHistogram_WrongLabelCount(t *testing.T) {
+	h := NewRegistry().NewHistogram("h", "help", DefBuckets, "a", "b")
+	assert.Panics(t, func() { h.Observe(1, "only one") })
+}
+
+func TestExponentialBuckets(t *testing.T) {
+	assert.Equal(t, []float64{1, 4, 16}, ExponentialBuckets(1, 4, 3))
+}
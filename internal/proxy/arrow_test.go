@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"skein/internal/api"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func arrowStream(t *testing.T, values ...int64) []byte {
+	t.Helper()
+	schema := arrow.NewSchema([]arrow.Field{{Name: "n", Type: arrow.PrimitiveTypes.Int64}}, nil)
+	builder := array.NewRecordBuilder(memory.NewGoAllocator(), schema)
+	defer builder.Release()
+	builder.Field(0).(*array.Int64Builder).AppendValues(values, nil)
+	rec := builder.NewRecordBatch()
+	defer rec.Release()
+
+	var buf bytes.Buffer
+	writer := ipc.NewWriter(&buf, ipc.WithSchema(schema))
+	require.NoError(t, writer.Write(rec))
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestSubmitJobHandler_ArrowFormat(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{Arrow: true})
+
+	req := httptest.NewRequest(http.MethodPost, "/jobs", bytes.NewBufferString(`{"user_id":"u1","query":"SELECT 1"}`))
+	req.Header.Set("Accept", "application/json;q=0.5, "+api.ArrowStreamMediaType)
+	rec := httptest.NewRecorder()
+	p.SubmitJobHandler(rec, req)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	var resp api.QueryResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+
+	job := fetchNextJob(t, p, worker.ID)
+	assert.Equal(t, api.FormatArrow, job.Format, "the worker learns the format from the job")
+
+	stream := arrowStream(t, 1, 2, 3)
+	body, err := json.Marshal(map[string]any{"worker_id": worker.ID, "job_id": resp.JobID, "result": api.JobResult{Arrow: stream}})
+	require.NoError(t, err)
+	rec = httptest.NewRecorder()
+	p.ResultHandler(rec, httptest.NewRequest(http.MethodPost, "/internal/job/result", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = getJobResult(p, resp.JobID)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, api.ArrowStreamMediaType, rec.Header().Get("Content-Type"))
+
+	reader, err := ipc.NewReader(rec.Body)
+	require.NoError(t, err)
+	defer reader.Release()
+	require.True(t, reader.Next())
+	assert.Equal(t, []int64{1, 2, 3}, reader.RecordBatch().Column(0).(*array.Int64).Int64Values())
+}
+
+func TestSubmitJobHandler_UnknownFormat(t *testing.T) {
+	p := newTestProxy()
+
+	rec := httptest.NewRecorder()
+	body := `{"user_id":"u1","query":"SELECT 1","format":"xml"}`
+	p.SubmitJobHandler(rec, httptest.NewRequest(http.MethodPost, "/jobs", bytes.NewBufferString(body)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestResultHandler_RawArrowStream(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{Arrow: true})
+	jobID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1", Format: api.FormatArrow})
+	fetchNextJob(t, p, worker.ID)
+
+	stream := arrowStream(t, 4, 5)
+	req := httptest.NewRequest(http.MethodPost, "/internal/job/result?worker_id="+worker.ID+"&job_id="+jobID, bytes.NewReader(stream))
+	req.Header.Set("Content-Type", api.ArrowStreamMediaType)
+	req.Header.Set(api.ResultHeader, `{"profile":{"latency":0.5},"timeline":[{"stage":"result_serialized","at":"2026-10-16T12:00:00Z"}]}`)
+	rec := httptest.NewRecorder()
+	p.ResultHandler(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	job := getJob(t, p, jobID)
+	assert.Equal(t, api.StatusCompleted, job.Status)
+	stored, ok := p.jobStore.Get(jobID)
+	require.True(t, ok)
+	assert.Equal(t, stream, stored.Result.Arrow)
+	assert.JSONEq(t, `{"latency":0.5}`, string(stored.Result.Profile))
+	assert.Contains(t, stages(stored.Timeline), api.StageResultSerialized)
+
+	rec = getJobResult(p, jobID)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, stream, rec.Body.Bytes())
+}
+
+func TestSubmitJobHandler_ArrowNeedsCapableWorker(t *testing.T) {
+	p := newTestProxy()
+	plain := p.registry.Register(1, api.WorkerCapacity{})
+
+	body := `{"user_id":"u1","query":"SELECT 1","format":"arrow"}`
+	rec := httptest.NewRecorder()
+	p.SubmitJobHandler(rec, httptest.NewRequest(http.MethodPost, "/jobs", bytes.NewBufferString(body)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "no registered worker supports arrow")
+
+	p.registry.Register(1, api.WorkerCapacity{Arrow: true})
+	jobID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1", Format: api.FormatArrow})
+	plainJob := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 2"})
+	assert.Equal(t, plainJob, fetchNextJob(t, p, plain.ID).ID, "the arrow job is skipped for a worker without arrow")
+	assert.Equal(t, api.StatusPending, getJob(t, p, jobID).Status)
+}
@@ -0,0 +1,338 @@
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// sqlToken is a word, a punctuation character or a quoted string of a query.
+// quote is the quote character of string literals and quoted identifiers, 0 otherwise.
+type sqlToken struct {
+	text  string
+	quote rune
+}
+
+func (t sqlToken) is(word string) bool {
+	return t.quote == 0 && strings.EqualFold(t.text, word)
+}
+
+// volatileWords are functions and clauses whose result changes from one run
+// to the next.
+var volatileWords = map[string]bool{
+	"random": true, "setseed": true, "uuid": true, "gen_random_uuid": true, "uuidv4": true, "uuidv7": true,
+	"now": true, "today": true, "current_date": true, "current_time": true, "current_timestamp": true,
+	"current_localtime": true, "current_localtimestamp": true, "localtime": true, "localtimestamp": true,
+	"get_current_time": true, "get_current_timestamp": true, "transaction_timestamp": true,
+	"nextval": true, "currval": true, "getenv": true, "current_setting": true,
+	"sample": true, "tablesample": true,
+}
+
+// fileReaders are the table functions reading the files named by their first argument.
+var fileReaders = map[string]bool{
+	"read_parquet": true, "parquet_scan": true, "read_csv": true, "read_csv_auto": true,
+	"read_json": true, "read_json_auto": true, "read_ndjson": true, "read_ndjson_auto": true,
+	"read_json_objects": true, "read_text": true, "read_blob": true,
+}
+
+// pureTableFunctions are the table functions that don't read anything.
+var pureTableFunctions = map[string]bool{"range": true, "generate_series": true, "unnest": true}
+
+// valueFromFunctions use FROM inside their arguments, like EXTRACT(year FROM x).
+var valueFromFunctions = map[string]bool{"extract": true, "substring": true, "trim": true, "overlay": true}
+
+// fromItemKeywords end a FROM item, any other word after it is its alias.
+var fromItemKeywords = map[string]bool{
+	"where": true, "group": true, "order": true, "limit": true, "offset": true, "having": true,
+	"qualify": true, "window": true, "union": true, "except": true, "intersect": true, "join": true,
+	"inner": true, "left": true, "right": true, "full": true, "outer": true, "cross": true,
+	"natural": true, "positional": true, "asof": true, "anti": true, "semi": true, "on": true,
+	"using": true, "pivot": true, "unpivot": true, "fetch": true, "select": true,
+}
+
+// cacheableQuery reports whether a result of the query can be served again:
+// the query is a single read-only statement without volatile functions and
+// reads only files the proxy can see, so their versions go into the cache
+// key. Tables and views live on the workers and URLs can't be versioned, so
+// queries reading them are not cacheable. When in doubt the answer is no.
+func cacheableQuery(query string, params map[string]interface{}) bool {
+	tokens := tokenizeSQL(query)
+	for len(tokens) > 0 && tokens[len(tokens)-1].is(";") {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) == 0 {
+		return false
+	}
+	if first := tokens[0]; !first.is("select") && !first.is("with") && !first.is("from") && !first.is("values") {
+		return false
+	}
+
+	ctes := cteNames(tokens)
+	// calls holds the function, if any, of every open parenthesis.
+	var calls []string
+	for i, tok := range tokens {
+		if tok.quote == '\'' {
+			if strings.Contains(tok.text, "://") {
+				return false
+			}
+			continue
+		}
+		if tok.quote != 0 {
+			continue
+		}
+		word := strings.ToLower(tok.text)
+		switch {
+		case word == ";":
+			return false
+		case word == "(":
+			call := ""
+			if i > 0 && tokens[i-1].quote == 0 {
+				call = strings.ToLower(tokens[i-1].text)
+			}
+			calls = append(calls, call)
+		case word == ")":
+			if len(calls) > 0 {
+				calls = calls[:len(calls)-1]
+			}
+		case volatileWords[word]:
+			return false
+		case word == "from" && len(calls) > 0 && valueFromFunctions[calls[len(calls)-1]]:
+		case word == "from" && i > 0 && tokens[i-1].is("distinct"):
+		case word == "from" || word == "join":
+			if !localSources(tokens[i+1:], ctes, params) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// localSources checks the items of a FROM clause: files the proxy can see,
+// subqueries (their own FROM clauses are checked on their own), common table
+// expressions and table functions that don't read anything else.
+func localSources(tokens []sqlToken, ctes map[string]bool, params map[string]interface{}) bool {
+	for {
+		for len(tokens) > 0 && tokens[0].is("lateral") {
+			tokens = tokens[1:]
+		}
+		if len(tokens) == 0 {
+			return false
+		}
+		tok := tokens[0]
+		switch {
+		case tok.quote == '\'':
+			if !visibleOnProxy(tok.text) {
+				return false
+			}
+			tokens = tokens[1:]
+		case tok.is("("):
+			tokens = skipParens(tokens)
+		case tok.quote == 0 && len(tokens) > 1 && tokens[1].is("("):
+			name := strings.ToLower(tok.text)
+			if fileReaders[name] {
+				if !visibleArgument(tokens[2:], params) {
+					return false
+				}
+			} else if !pureTableFunctions[name] {
+				return false
+			}
+			tokens = skipParens(tokens[1:])
+		case ctes[strings.ToLower(tok.text)]:
+			tokens = tokens[1:]
+		default:
+			return false
+		}
+
+		// Skip the item's alias and column names.
+		if len(tokens) > 0 && tokens[0].is("as") {
+			tokens = tokens[1:]
+		}
+		if len(tokens) > 0 && (tokens[0].quote == '"' || tokens[0].quote == 0 && isWord(tokens[0].text) && !fromItemKeywords[strings.ToLower(tokens[0].text)]) {
+			tokens = tokens[1:]
+			if len(tokens) > 0 && tokens[0].is("(") {
+				tokens = skipParens(tokens)
+			}
+		}
+		if len(tokens) == 0 || !tokens[0].is(",") {
+			return true
+		}
+		tokens = tokens[1:]
+	}
+}
+
+// visibleArgument checks the first argument of a file reader, a path, a list
+// of paths or a parameter holding them.
+func visibleArgument(tokens []sqlToken, params map[string]interface{}) bool {
+	var paths []string
+	depth := 0
+loop:
+	for _, tok := range tokens {
+		switch {
+		case tok.quote == '\'':
+			paths = append(paths, tok.text)
+		case tok.quote != 0:
+			return false
+		case tok.text == "(" || tok.text == "[":
+			depth++
+		case tok.text == ")" || tok.text == "]":
+			if depth == 0 {
+				break loop
+			}
+			depth--
+		case tok.text == ",":
+			if depth == 0 {
+				break loop
+			}
+		case strings.HasPrefix(tok.text, "$"):
+			switch value := params[tok.text[1:]].(type) {
+			case string:
+				paths = append(paths, value)
+			case []interface{}:
+				for _, v := range value {
+					s, ok := v.(string)
+					if !ok {
+						return false
+					}
+					paths = append(paths, s)
+				}
+			default:
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	if len(paths) == 0 {
+		return false
+	}
+	for _, path := range paths {
+		if !visibleOnProxy(path) {
+			return false
+		}
+	}
+	return true
+}
+
+// visibleOnProxy reports whether the proxy sees the files of the path, so
+// fileVersions can tell when they change.
+func visibleOnProxy(path string) bool {
+	if path == "" || strings.Contains(path, "://") || strings.Contains(path, "**") {
+		return false
+	}
+	if strings.ContainsAny(path, "*?[") {
+		matches, err := filepath.Glob(path)
+		return err == nil && len(matches) > 0
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// cteNames returns the lower cased names of the query's common table expressions.
+func cteNames(tokens []sqlToken) map[string]bool {
+	names := make(map[string]bool)
+	for i := 1; i+1 < len(tokens); i++ {
+		next := tokens[i+1]
+		if !tokens[i].is("as") || !next.is("(") && !next.is("materialized") && !next.is("not") {
+			continue
+		}
+		j := i - 1
+		if tokens[j].is(")") {
+			// name(column, ...) AS (...)
+			for depth := 0; j >= 0; j-- {
+				if tokens[j].is(")") {
+					depth++
+				} else if tokens[j].is("(") {
+					if depth--; depth == 0 {
+						break
+					}
+				}
+			}
+			j--
+		}
+		if j >= 0 {
+			names[strings.ToLower(tokens[j].text)] = true
+		}
+	}
+	return names
+}
+
+// skipParens drops the tokens up to the parenthesis closing the first one.
+func skipParens(tokens []sqlToken) []sqlToken {
+	depth := 0
+	for i, tok := range tokens {
+		if tok.is("(") {
+			depth++
+		} else if tok.is(")") {
+			if depth--; depth == 0 {
+				return tokens[i+1:]
+			}
+		}
+	}
+	return nil
+}
+
+// tokenizeSQL splits a query into words, punctuation and quoted strings,
+// dropping whitespace and comments.
+func tokenizeSQL(query string) []sqlToken {
+	var tokens []sqlToken
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i+1 < len(runes) && (runes[i] != '*' || runes[i+1] != '/') {
+				i++
+			}
+			i += 2
+		case r == '\'' || r == '"':
+			var b strings.Builder
+			i++
+			for i < len(runes) {
+				if runes[i] == r {
+					// A doubled quote is an escaped one.
+					if i+1 < len(runes) && runes[i+1] == r {
+						b.WriteRune(r)
+						i += 2
+						continue
+					}
+					break
+				}
+				b.WriteRune(runes[i])
+				i++
+			}
+			i++
+			tokens = append(tokens, sqlToken{text: b.String(), quote: r})
+		case isWordRune(r):
+			start := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{text: string(runes[start:i])})
+		default:
+			tokens = append(tokens, sqlToken{text: string(r)})
+			i++
+		}
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || r == '$' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isWord(s string) bool {
+	for _, r := range s {
+		if !isWordRune(r) {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"skein/internal/api"
+)
+
+// ExportUploadHandler is an internal endpoint for workers to upload the file
+// of an exported result. The file is kept in the spool directory.
+func (p *Proxy) ExportUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	workerID := r.URL.Query().Get("worker_id")
+	jobID := r.URL.Query().Get("job_id")
+	if workerID == "" || jobID == "" {
+		http.Error(w, "worker_id and job_id query parameters are required", http.StatusBadRequest)
+		return
+	}
+	if !p.authorizeWorker(w, r, workerID) {
+		return
+	}
+	job, ok := p.jobStore.Get(jobID)
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if job.WorkerID != workerID {
+		http.Error(w, "Job is not leased to this worker", http.StatusConflict)
+		return
+	}
+	if !job.Format.IsExport() {
+		http.Error(w, "Job doesn't export its result", http.StatusBadRequest)
+		return
+	}
+
+	name := job.ExportFileName()
+	size, err := p.exports.Save(name, r.Body)
+	if err != nil {
+		slog.Error("failed to save exported file", "job_id", jobID, "error", err)
+		http.Error(w, "Failed to save exported file", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("exported file received", "event", "query.export.received", "job_id", jobID,
+		"worker_id", workerID, "file", name, "size_bytes", size)
+	w.WriteHeader(http.StatusOK)
+}
+
+// DownloadHandler serves the exported file of a finished job. While the job
+// is still pending or running it responds with 202 and the job status.
+func (p *Proxy) DownloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	job, ok := p.callerJob(r)
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if !isFinished(job.Status) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+		return
+	}
+	if job.Status != api.StatusCompleted {
+		p.writeJobResult(w, job)
+		return
+	}
+	if job.Result == nil || job.Result.Export == nil {
+		http.Error(w, "Job has no exported file", http.StatusNotFound)
+		return
+	}
+
+	name := job.Result.Export.FileName
+	f, err := p.exports.Open(name)
+	if err != nil {
+		slog.Warn("exported file not available", "job_id", job.ID, "file", name, "error", err)
+		http.Error(w, "Exported file not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "Exported file not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", exportContentType(name))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ExportStore keeps the files of exported results in a spool directory.
+// Files are removed together with their jobs, after jobRetention.
+type ExportStore struct {
+	dir string
+}
+
+// NewExportStore creates an ExportStore in dir and starts its cleanup process.
+func NewExportStore(dir string) *ExportStore {
+	s := &ExportStore{dir: dir}
+	go s.cleanupLoop()
+	return s
+}
+
+// Save writes the content of r to the file with the given name.
+func (s *ExportStore) Save(name string, r io.Reader) (int64, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return 0, fmt.Errorf("create spool directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return 0, fmt.Errorf("create spool file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("write spool file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(name)); err != nil {
+		return 0, fmt.Errorf("move spool file: %w", err)
+	}
+	return n, nil
+}
+
+// Open opens the file with the given name.
+func (s *ExportStore) Open(name string) (*os.File, error) {
+	return os.Open(s.path(name))
+}
+
+func (s *ExportStore) path(name string) string {
+	return filepath.Join(s.dir, filepath.Base(name))
+}
+
+// cleanupLoop periodically removes files older than jobRetention.
+func (s *ExportStore) cleanupLoop() {
+	ticker := time.NewTicker(jobCleanupInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		entries, err := os.ReadDir(s.dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || time.Since(info.ModTime()) <= jobRetention {
+				continue
+			}
+			if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil {
+				slog.Warn("failed to remove expired export", "file", entry.Name(), "error", err)
+				continue
+			}
+			slog.Debug("removed expired export", "file", entry.Name())
+		}
+	}
+}
+
+// exportContentType returns the media type of an exported file.
+func exportContentType(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".parquet"):
+		return "application/vnd.apache.parquet"
+	case strings.HasSuffix(name, ".csv.gz"):
+		return "application/gzip"
+	case strings.HasSuffix(name, ".csv.zst"):
+		return "application/zstd"
+	}
+	return "text/csv"
+}
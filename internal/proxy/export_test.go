@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"skein/internal/api"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func uploadExport(p *Proxy, workerID, jobID, content string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	p.ExportUploadHandler(rec, httptest.NewRequest(http.MethodPost,
+		"/internal/job/export?worker_id="+workerID+"&job_id="+jobID, strings.NewReader(content)))
+	return rec
+}
+
+func download(p *Proxy, jobID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/jobs/"+jobID+"/download", nil)
+	req.SetPathValue("id", jobID)
+	rec := httptest.NewRecorder()
+	p.DownloadHandler(rec, req)
+	return rec
+}
+
+func TestDownloadHandler_CSVExport(t *testing.T) {
+	p := newTestProxy()
+	p.exports = NewExportStore(t.TempDir())
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+	other := p.registry.Register(1, api.WorkerCapacity{})
+
+	jobID := submitJob(t, p, api.QueryRequest{
+		UserID:        "u1",
+		Query:         "SELECT 1 AS a, 2 AS b",
+		Format:        api.FormatCSV,
+		ExportOptions: &api.ExportOptions{Delimiter: ";", Compression: "gzip"},
+	})
+	job := fetchNextJob(t, p, worker.ID)
+	assert.Equal(t, ";", job.ExportOptions.Delimiter)
+	assert.Equal(t, http.StatusAccepted, download(p, jobID).Code, "the job is still running")
+
+	assert.Equal(t, http.StatusConflict, uploadExport(p, other.ID, jobID, "x").Code)
+	require.Equal(t, http.StatusOK, uploadExport(p, worker.ID, jobID, "gzipped csv").Code)
+	postResult(t, p, jobID, `{"export":{"format":"csv","file_name":"`+jobID+`.csv.gz","size_bytes":11,"rows":1}}`)
+
+	rec := getJobResult(p, jobID)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var results api.QueryResults
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&results))
+	require.NotNil(t, results.Export)
+	assert.Equal(t, int64(1), results.Export.Rows)
+	assert.Equal(t, "/jobs/"+jobID+"/download", results.Export.DownloadURL)
+
+	rec = download(p, jobID)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/gzip", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), jobID+".csv.gz")
+	assert.Equal(t, "gzipped csv", rec.Body.String())
+}
+
+func TestDownloadHandler_NoExport(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+
+	jobID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1"})
+	fetchNextJob(t, p, worker.ID)
+	assert.Equal(t, http.StatusBadRequest, uploadExport(p, worker.ID, jobID, "x").Code)
+	postResult(t, p, jobID, `{"column_names":["x"],"column_types":[{"type":"INTEGER"}],"column_data":[[1]]}`)
+
+	assert.Equal(t, http.StatusNotFound, download(p, jobID).Code)
+}
+
+func TestSubmitJobHandler_InvalidExportOptions(t *testing.T) {
+	p := newTestProxy()
+
+	for _, body := range []string{
+		`{"query":"SELECT 1","export_options":{"compression":"zstd"}}`,
+		`{"query":"SELECT 1","format":"parquet","export_options":{"compression":"none"}}`,
+		`{"query":"SELECT 1","format":"csv","export_options":{"delimiter":"ab"}}`,
+	} {
+		rec := httptest.NewRecorder()
+		p.SubmitJobHandler(rec, httptest.NewRequest(http.MethodPost, "/jobs", bytes.NewBufferString(body)))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+}
@@ -3,10 +3,18 @@ package proxy
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
 	"skein/internal/api"
+	"skein/internal/auth"
 	"skein/internal/settings"
+	"skein/internal/tracing"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,22 +23,40 @@ import (
 const (
 	// Default timeout for a synchronous query.
 	requestTimeout = 30 * time.Second
+	// Short timeout for handing queued jobs to ready workers.
+	dispatchTimeout = 2 * time.Second
 )
 
 // Proxy holds the dependencies for the proxy server.
 type Proxy struct {
 	registry    *WorkerRegistry
-	jobQueue    *JobQueue
+	scheduler   *Scheduler
 	resultStore *ResultStore
+	jobStore    *JobStore
+	streams     *StreamStore
+	exports     *ExportStore
+	cache       *ResultCache
+	workerAuth  *WorkerAuth
+	metrics     *proxyMetrics
+	tracer      *tracing.Tracer
 }
 
-// NewProxy creates a new Proxy instance.
-func NewProxy(registry *WorkerRegistry, jobQueue *JobQueue, resultStore *ResultStore) *Proxy {
-	return &Proxy{
+// NewProxy creates a new Proxy instance, queues the unfinished jobs of the
+// job store and starts watching job leases.
+func NewProxy(registry *WorkerRegistry, scheduler *Scheduler, resultStore *ResultStore, jobStore *JobStore) *Proxy {
+	p := &Proxy{
 		registry:    registry,
-		jobQueue:    jobQueue,
+		scheduler:   scheduler,
 		resultStore: resultStore,
+		jobStore:    jobStore,
+		streams:     NewStreamStore(),
+		exports:     NewExportStore(settings.ExportSpoolDir),
+		cache:       NewResultCache(settings.ResultCacheTTL, settings.ResultCacheMaxBytes),
 	}
+	p.metrics = newProxyMetrics(p)
+	p.requeueUnfinished()
+	go p.leaseLoop()
+	return p
 }
 
 // RegisterWorkerHandler handles the registration of a new worker.
@@ -39,10 +65,30 @@ func (p *Proxy) RegisterWorkerHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	handler := p.registry.Register()
+	var name string
+	if p.workerAuth != nil {
+		var ok bool
+		if name, ok = p.workerAuth.checkRegistration(r); !ok {
+			slog.Warn("worker registration rejected", "event", "worker.auth.failed", "remote_addr", r.RemoteAddr)
+			http.Error(w, "Invalid worker credentials", http.StatusUnauthorized)
+			return
+		}
+	}
+	var req api.RegisterWorkerRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if !req.Capacity.Size.Valid() {
+		http.Error(w, "Unknown worker size", http.StatusBadRequest)
+		return
+	}
+	handler := p.registry.RegisterNamed(name, req.Slots, req.Capacity)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"worker_id": handler.ID})
+	json.NewEncoder(w).Encode(api.RegisterWorkerResponse{WorkerID: handler.ID, Token: handler.Token()})
 }
 
 // DeregisterWorkerHandler handles the graceful shutdown of a worker.
@@ -56,7 +102,14 @@ func (p *Proxy) DeregisterWorkerHandler(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "worker_id query parameter is required", http.StatusBadRequest)
 		return
 	}
-	p.registry.Deregister(workerID)
+	if !p.authorizeWorker(w, r, workerID) {
+		return
+	}
+	if handler, ok := p.registry.Deregister(workerID); ok {
+		if p.redeliverAll(handler.TakeJobs(), "worker deregistered") > 0 {
+			p.dispatchQueued(r.Context())
+		}
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -66,9 +119,7 @@ func (p *Proxy) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	var payload struct {
-		WorkerID string `json:"worker_id"`
-	}
+	var payload api.HeartbeatRequest
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
@@ -77,10 +128,57 @@ func (p *Proxy) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "worker_id is required", http.StatusBadRequest)
 		return
 	}
-	if !p.registry.Heartbeat(payload.WorkerID) {
+	if !p.authorizeWorker(w, r, payload.WorkerID) {
+		return
+	}
+	handler, ok := p.registry.Get(payload.WorkerID)
+	if !ok {
 		http.Error(w, "Worker not found", http.StatusNotFound)
 		return
 	}
+	handler.UpdateHeartbeat()
+	lost := handler.RenewLeases(payload.JobIDs, time.Now().UTC().Add(settings.JobLeaseDuration))
+	if len(lost) > 0 {
+		slog.Warn("worker runs jobs it doesn't hold", "worker_id", payload.WorkerID, "job_ids", lost)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(api.HeartbeatResponse{LostJobIDs: lost})
+}
+
+// AckJobHandler handles a worker's acknowledgement of a received job. From
+// then on the worker keeps the job's lease with heartbeats.
+func (p *Proxy) AckJobHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var payload api.JobAck
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.WorkerID == "" || payload.JobID == "" {
+		http.Error(w, "worker_id and job_id are required", http.StatusBadRequest)
+		return
+	}
+	if !p.authorizeWorker(w, r, payload.WorkerID) {
+		return
+	}
+	handler, ok := p.registry.Get(payload.WorkerID)
+	if !ok {
+		http.Error(w, "Worker not registered or has been deregistered", http.StatusForbidden)
+		return
+	}
+	if !handler.AckJob(payload.JobID, time.Now().UTC().Add(settings.JobLeaseDuration)) {
+		http.Error(w, "Job is not leased to this worker", http.StatusConflict)
+		return
+	}
+	slog.Debug("job acknowledged", "event", "query.acked", "job_id", payload.JobID, "worker_id", payload.WorkerID)
+	if job, ok := p.jobStore.Get(payload.JobID); ok {
+		p.traceJob(&job, "skein.proxy.dispatch", job.DispatchedAt, time.Now().UTC())
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -95,6 +193,9 @@ func (p *Proxy) JobDispatcherHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "worker_id query parameter is required", http.StatusBadRequest)
 		return
 	}
+	if !p.authorizeWorker(w, r, workerID) {
+		return
+	}
 
 	handler, ok := p.registry.Get(workerID)
 	if !ok {
@@ -108,13 +209,17 @@ func (p *Proxy) JobDispatcherHandler(w http.ResponseWriter, r *http.Request) {
 		timeout bool
 	)
 
-	job = p.jobQueue.Get()
+	job = p.scheduler.Next(handler.Accepts)
 	if job == nil {
-		// Mark worker as ready and defer setting it to not ready.
-		handler.SetReady(true)
-		defer handler.SetReady(false)
+		// Mark the worker as waiting for a job until this request is done.
+		handler.StartWaiting()
+		defer handler.StopWaiting()
 		slog.Debug("worker is ready and waiting for a job", "worker_id", workerID)
 
+		// A job might have been queued just before the worker became ready.
+		job = p.scheduler.Next(handler.Accepts)
+	}
+	if job == nil {
 		select {
 		case job = <-handler.JobChannel:
 			slog.Info("dispatching job to worker", "event", "query.assigned", "job_id", job.ID, "worker_id", workerID)
@@ -134,18 +239,35 @@ func (p *Proxy) JobDispatcherHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if job != nil {
+		now := time.Now().UTC()
+		// UpdatedAt is when the job was queued, its last state change.
+		queuedAt := job.UpdatedAt
 		job.Status = api.StatusRunning
-		job.DispatchedAt = time.Now().UTC()
-		job.UpdatedAt = time.Now().UTC()
+		job.WorkerID = workerID
+		job.Attempts++
+		job.DispatchedAt = now
+		job.UpdatedAt = now
+		if !p.jobStore.MarkRunning(job) {
+			// The job was cancelled or expired after it left the queue, its
+			// concurrency slot goes to the next queued job.
+			slog.Info("skipping job finished before dispatch", "job_id", job.ID, "worker_id", workerID)
+			if p.scheduler.Done(job.ID) {
+				p.dispatchQueued(context.Background())
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		p.traceJob(job, "skein.proxy.queue_wait", queuedAt, now)
+		p.metrics.observeDispatch(job, now)
+		handler.AssignJob(job, now.Add(settings.JobAckTimeout))
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		if err := json.NewEncoder(w).Encode(job); err != nil {
 			slog.Error("failed to encode job for worker", "job_id", job.ID, "error", err)
-			// If we fail to send, try to re-dispatch the job.
-			// This is best-effort and might fail if no other workers are available.
-			// TODO do this in some smart way
-			p.registry.Dispatch(context.Background(), job)
+			handler.ReleaseJob(job.ID)
+			p.redeliver(job, "failed to send job to worker")
+			p.dispatchQueued(context.Background())
 		}
 	} else {
 		w.WriteHeader(http.StatusNoContent)
@@ -159,112 +281,546 @@ func (p *Proxy) QueryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req, err := p.decodeQueryRequest(r)
+	if err != nil {
+		slog.Error("failed to decode request body", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	job := newJob(req)
+	span := p.traceReceive(r, job)
+	slog.Info("query received", "event", "query.received", "job_id", job.ID, "user_id", job.UserID)
+	if p.serveFromCache(job, req.BypassCache) {
+		span.SetAttributes(tracing.Bool("cache.hit", true))
+		span.End()
+		p.writeJobResult(w, *job)
+		return
+	}
+	resultChan := p.resultStore.Register(job.ID)
+	defer p.resultStore.Deregister(job.ID)
+
+	if job.Format == api.FormatNDJSON {
+		stream := p.streams.Register(job.ID)
+		defer p.streams.Deregister(job.ID)
+		p.submit(r.Context(), job)
+		span.End()
+		p.streamJobResult(w, r, job.ID, stream, resultChan)
+		return
+	}
+
+	p.submit(r.Context(), job)
+	span.End()
+
+	// Wait for the result or a timeout.
+	select {
+	case <-resultChan:
+		stored, _ := p.jobStore.Get(job.ID)
+		p.writeJobResult(w, stored)
+	case <-r.Context().Done():
+		slog.Warn("client cancelled request", "job_id", job.ID)
+		if err := p.cancelJob(job.ID); err != nil {
+			slog.Debug("failed to cancel job of a cancelled request", "job_id", job.ID, "error", err)
+		}
+		http.Error(w, "Request cancelled", 499) // 499 Client Closed Request
+	case <-time.After(requestTimeout):
+		slog.Error("request timed out waiting for result", "job_id", job.ID)
+		http.Error(w, "Request timed out", http.StatusGatewayTimeout)
+	}
+}
+
+// SubmitJobHandler handles the asynchronous submission of new queries.
+// It responds immediately with the job ID, which the client uses to poll
+// the job status and fetch its result.
+func (p *Proxy) SubmitJobHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := p.decodeQueryRequest(r)
+	if err != nil {
+		slog.Error("failed to decode request body", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Format == api.FormatNDJSON {
+		http.Error(w, "Streamed results are only available from /query", http.StatusBadRequest)
+		return
+	}
+
+	job := newJob(req)
+	span := p.traceReceive(r, job)
+	slog.Info("job submitted", "event", "query.received", "job_id", job.ID, "user_id", job.UserID)
+	if p.serveFromCache(job, req.BypassCache) {
+		span.SetAttributes(tracing.Bool("cache.hit", true))
+	} else {
+		p.submit(r.Context(), job)
+	}
+	span.End()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(api.QueryResponse{JobID: job.ID})
+}
+
+// callerJob returns the job the request's path names. An authenticated caller
+// only finds its own jobs, other users' jobs look like they don't exist.
+func (p *Proxy) callerJob(r *http.Request) (api.Job, bool) {
+	job, ok := p.jobStore.Get(r.PathValue("id"))
+	if !ok {
+		return api.Job{}, false
+	}
+	if principal, ok := auth.PrincipalFrom(r.Context()); ok && job.UserID != principal {
+		return api.Job{}, false
+	}
+	return job, true
+}
+
+// JobStatusHandler returns the current state of a job, without its result.
+func (p *Proxy) JobStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	job, ok := p.callerJob(r)
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	job.Result = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(job)
+}
+
+// JobResultHandler returns the result of a finished job. While the job is
+// still pending or running it responds with 202 and the job status. With the
+// cursor or limit query parameters it returns a page of the result.
+func (p *Proxy) JobResultHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	job, ok := p.callerJob(r)
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if !isFinished(job.Status) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+		return
+	}
+	if isPageRequest(r) {
+		p.writeResultPage(w, r, job)
+		return
+	}
+	p.writeJobResult(w, job)
+}
+
+// decodeQueryRequest decodes and validates a query submission.
+func (p *Proxy) decodeQueryRequest(r *http.Request) (api.QueryRequest, error) {
 	var req api.QueryRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		slog.Error("failed to decode request body", "error", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return req, errors.New("invalid request body")
+	}
+	if principal, ok := auth.PrincipalFrom(r.Context()); ok {
+		req.UserID = principal
+	}
+	if req.Class == "" {
+		req.Class = api.ClassUser
+	}
+	if !p.scheduler.HasClass(req.Class) {
+		return req, fmt.Errorf("unknown query class %q", req.Class)
+	}
+	if !req.Size.Valid() {
+		return req, fmt.Errorf("unknown worker size %q", req.Size)
+	}
+	if req.Format == "" {
+		req.Format = formatFromAccept(r)
+	}
+	if !req.Format.Valid() {
+		return req, fmt.Errorf("unknown result format %q", req.Format)
+	}
+	if req.Format == api.FormatArrow && !p.registry.HasArrowWorker() {
+		return req, errors.New("no worker can produce arrow results")
+	}
+	if req.ExportOptions != nil {
+		if !req.Format.IsExport() {
+			return req, errors.New("export_options need the parquet or csv format")
+		}
+		if err := req.ExportOptions.Validate(req.Format); err != nil {
+			return req, err
+		}
+	}
+	return req, nil
+}
+
+// formatFromAccept returns the result format the request's Accept header asks
+// for, or the empty format if it doesn't ask for a specific one.
+func formatFromAccept(r *http.Request) api.ResultFormat {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, _, _ := strings.Cut(mediaRange, ";")
+			switch strings.TrimSpace(mediaType) {
+			case api.ArrowStreamMediaType:
+				return api.FormatArrow
+			case api.NDJSONMediaType:
+				return api.FormatNDJSON
+			}
+		}
+	}
+	return ""
+}
+
+// CancelJobHandler cancels a queued or running job.
+func (p *Proxy) CancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Only DELETE method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	job, ok := p.callerJob(r)
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	switch err := p.cancelJob(job.ID); {
+	case errors.Is(err, ErrJobNotFound):
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrJobFinished):
+		http.Error(w, "Job already finished", http.StatusConflict)
 		return
 	}
 
-	job := &api.Job{
+	job, _ = p.jobStore.Get(job.ID)
+	job.Result = nil
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(job)
+}
+
+// CancelWatchHandler is an internal long-poll endpoint for a worker running
+// a job. It responds with 200 as soon as the job is cancelled, or with 204
+// after the long poll timeout.
+func (p *Proxy) CancelWatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	workerID := r.URL.Query().Get("worker_id")
+	jobID := r.URL.Query().Get("job_id")
+	if workerID == "" || jobID == "" {
+		http.Error(w, "worker_id and job_id query parameters are required", http.StatusBadRequest)
+		return
+	}
+	if !p.authorizeWorker(w, r, workerID) {
+		return
+	}
+	if _, ok := p.registry.Get(workerID); !ok {
+		http.Error(w, "Worker not registered or has been deregistered", http.StatusForbidden)
+		return
+	}
+	cancelled, ok := p.jobStore.CancelSignal(jobID)
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	select {
+	case <-cancelled:
+		slog.Info("notifying worker about cancelled job", "event", "query.cancel.sent", "job_id", jobID, "worker_id", workerID)
+		w.WriteHeader(http.StatusOK)
+	case <-time.After(settings.LongPollTimeout):
+		w.WriteHeader(http.StatusNoContent)
+	case <-r.Context().Done():
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// cancelJob cancels a job. A queued job is removed from its queue, the
+// worker running a job learns about it through CancelWatchHandler.
+func (p *Proxy) cancelJob(jobID string) error {
+	prev, err := p.jobStore.Cancel(jobID)
+	if err != nil {
+		return err
+	}
+	if p.scheduler.Remove(jobID) {
+		slog.Info("queued job cancelled", "event", "query.cancelled", "job_id", jobID)
+	} else {
+		slog.Info("running job cancelled", "event", "query.cancelled", "job_id", jobID, "worker_id", prev.WorkerID)
+	}
+	p.resultStore.Notify(jobID, &api.JobResult{Error: "job cancelled", Cancelled: true})
+	return nil
+}
+
+// newJob creates a pending job from a client request.
+func newJob(req api.QueryRequest) *api.Job {
+	now := time.Now().UTC()
+	return &api.Job{
 		ID:               uuid.NewString(),
 		UserID:           req.UserID,
 		Query:            req.Query,
 		Params:           req.Params,
 		Priority:         req.Priority,
+		Class:            req.Class,
+		Size:             req.Size,
+		Format:           req.Format,
+		ExportOptions:    req.ExportOptions,
 		Status:           api.StatusPending,
-		CreatedAt:        time.Now().UTC(),
-		UpdatedAt:        time.Now().UTC(),
+		CreatedAt:        now,
+		UpdatedAt:        now,
 		DisableProfiling: req.DisableProfiling,
+		Timeline:         api.Timeline{{Stage: api.StageReceived, At: now}},
 	}
+}
 
-	slog.Info("query received", "event", "query.received", "job_id", job.ID, "user_id", job.UserID)
-	resultChan := p.resultStore.Register(job.ID)
-	defer p.resultStore.Deregister(job.ID)
+// requeueUnfinished queues the jobs left pending or running by a previous
+// proxy process. Their workers registered with that process, so running jobs
+// start over.
+func (p *Proxy) requeueUnfinished() {
+	for _, job := range p.jobStore.Unfinished() {
+		if job.Status == api.StatusRunning {
+			p.jobStore.Requeue(job.ID)
+			job.Status = api.StatusPending
+			job.WorkerID = ""
+			job.UpdatedAt = time.Now().UTC()
+		}
+		p.scheduler.Add(job)
+		slog.Info("job restored", "event", "query.restored", "job_id", job.ID, "user_id", job.UserID)
+	}
+}
 
-	// Create a context for dispatching.
-	dispatchCtx, cancel := context.WithTimeout(r.Context(), 2*time.Second) // Short timeout for dispatch attempt
-	defer cancel()
+// submit records and queues the job, then hands queued jobs to ready workers
+// in priority order.
+func (p *Proxy) submit(ctx context.Context, job *api.Job) {
+	job.Timeline.Add(api.StageQueued, "")
+	p.jobStore.Add(job)
+	p.scheduler.Add(job)
+	p.dispatchQueued(ctx)
+}
 
-	if err := p.registry.Dispatch(dispatchCtx, job); err != nil {
-		// If dispatch fails (e.g., no workers), add to the fallback queue.
-		slog.Warn("direct dispatch failed, adding to fallback queue", "job_id", job.ID, "error", err)
-		p.jobQueue.Add(job)
-	}
+// dispatchQueued sends queued jobs to ready workers until there's no job
+// allowed to run or no worker takes a job.
+func (p *Proxy) dispatchQueued(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, dispatchTimeout)
+	defer cancel()
 
-	// Wait for the result or a timeout.
-	select {
-	case result := <-resultChan:
-		w.Header().Set("Content-Type", "application/json")
-		if result.Error != "" {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(api.QueryResults{Error: result.Error})
+	for {
+		job := p.scheduler.Next(p.registry.HasReadyWorkerFor)
+		if job == nil {
 			return
 		}
-
-		var duckdbProfile api.DuckDBProfile
-		if len(result.Profile) > 0 {
-			if err := json.Unmarshal(result.Profile, &duckdbProfile); err != nil {
-				slog.Error("failed to unmarshal DuckDB profile", "job_id", job.ID, "error", err)
-				http.Error(w, "Internal server error: failed to process profiling data", http.StatusInternalServerError)
-				return
-			}
+		if err := p.registry.Dispatch(ctx, job); err != nil {
+			// If dispatch fails (e.g., no workers), the job waits in the queue
+			// for the next worker asking for a job.
+			slog.Debug("direct dispatch failed, job stays queued", "job_id", job.ID, "error", err)
+			p.scheduler.Requeue(job)
+			return
 		}
+	}
+}
 
-		queryResults := api.QueryResults{
-			ColumnNames: result.ColumnNames,
-			ColumnTypes: result.ColumnTypes,
-			ColumnData:  result.ColumnData,
-			Profile: api.ProfilingStats{
-				TotalBytesWritten: duckdbProfile.TotalBytesWritten,
-				TotalBytesRead:    duckdbProfile.TotalBytesRead,
-				RowsReturned:      duckdbProfile.RowsReturned,
-				Latency:           duckdbProfile.Latency,
-				CPUTime:           duckdbProfile.CPUTime,
-			},
-			GoProfile: api.GoProfileStats{
-				ExecuteTime:       result.GoProfile.ExecuteTime,
-				QueryTime:         result.GoProfile.QueryTime,
-				DispatchLatencyMs: job.DispatchedAt.Sub(job.CreatedAt).Milliseconds(),
-			},
-		}
+// writeJobResult writes the result of a finished job as api.QueryResults.
+func (p *Proxy) writeJobResult(w http.ResponseWriter, job api.Job) {
+	result := job.Result
+	if result == nil {
+		result = &api.JobResult{Error: "job finished without a result"}
+	}
+
+	timeline := p.responseTimeline(job)
 
+	w.Header().Set("Content-Type", "application/json")
+	if job.CacheHit {
+		w.Header().Set("X-Cache", "HIT")
+	} else {
+		w.Header().Set("X-Cache", "MISS")
+	}
+	if job.Status == api.StatusCancelled {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(api.QueryResults{Error: "job cancelled", Timeline: timeline})
+		return
+	}
+	if result.Error != "" {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(api.QueryResults{Error: result.Error, Timeline: timeline})
+		return
+	}
+	if job.Format == api.FormatArrow {
+		w.Header().Set("Content-Type", api.ArrowStreamMediaType)
 		w.WriteHeader(http.StatusOK)
-		if err := json.NewEncoder(w).Encode(queryResults); err != nil {
-			slog.Error("failed to encode query results", "job_id", job.ID, "error", err)
+		if _, err := w.Write(result.Arrow); err != nil {
+			slog.Error("failed to write arrow result", "job_id", job.ID, "error", err)
 		}
-	case <-r.Context().Done():
-		slog.Warn("client cancelled request", "job_id", job.ID)
-		http.Error(w, "Request cancelled", 499) // 499 Client Closed Request
-	case <-time.After(requestTimeout):
-		slog.Error("request timed out waiting for result", "job_id", job.ID)
-		http.Error(w, "Request timed out", http.StatusGatewayTimeout)
+		return
+	}
+
+	queryResults, err := newQueryResults(job, result)
+	if err != nil {
+		slog.Error("failed to unmarshal DuckDB profile", "job_id", job.ID, "error", err)
+		http.Error(w, "Internal server error: failed to process profiling data", http.StatusInternalServerError)
+		return
+	}
+	queryResults.Timeline = timeline
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(queryResults); err != nil {
+		slog.Error("failed to encode query results", "job_id", job.ID, "error", err)
+	}
+}
+
+// responseTimeline returns the job's timeline with the result being sent to
+// the client now. Only the response has that stage, a job's result can be
+// read any number of times.
+func (p *Proxy) responseTimeline(job api.Job) api.Timeline {
+	sent := api.TimelineEntry{Stage: api.StageResponseSent, At: time.Now().UTC()}
+	return append(slices.Clip(job.Timeline), sent)
+}
+
+// newQueryResults converts a job's result into the response sent to the client.
+func newQueryResults(job api.Job, result *api.JobResult) (api.QueryResults, error) {
+	var duckdbProfile api.DuckDBProfile
+	if len(result.Profile) > 0 {
+		if err := json.Unmarshal(result.Profile, &duckdbProfile); err != nil {
+			return api.QueryResults{}, err
+		}
+	}
+
+	var export *api.ExportFile
+	if result.Export != nil {
+		e := *result.Export
+		e.DownloadURL = "/jobs/" + job.ID + "/download"
+		export = &e
 	}
+
+	return api.QueryResults{
+		JobID:       job.ID,
+		ColumnNames: result.ColumnNames,
+		ColumnTypes: result.ColumnTypes,
+		ColumnData:  result.ColumnData,
+		Export:      export,
+		Error:       result.Error,
+		CacheHit:    job.CacheHit,
+		Profile: api.ProfilingStats{
+			TotalBytesWritten: duckdbProfile.TotalBytesWritten,
+			TotalBytesRead:    duckdbProfile.TotalBytesRead,
+			RowsReturned:      duckdbProfile.RowsReturned,
+			Latency:           duckdbProfile.Latency,
+			CPUTime:           duckdbProfile.CPUTime,
+		},
+		GoProfile: api.GoProfileStats{
+			ExecuteTime:     result.GoProfile.ExecuteTime,
+			QueryTime:       result.GoProfile.QueryTime,
+			DispatchLatency: job.DispatchedAt.Sub(job.CreatedAt),
+		},
+	}, nil
 }
 
 // ResultHandler is an internal endpoint for workers to post query results.
+// Only the worker the job is dispatched to can post its result.
 func (p *Proxy) ResultHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	type resultPayload struct {
-		JobID  string         `json:"job_id"`
-		Result *api.JobResult `json:"result"`
+	payload, err := decodeResultPayload(r)
+	if err != nil {
+		http.Error(w, "Invalid result payload", http.StatusBadRequest)
+		return
+	}
+	if payload.WorkerID == "" || payload.JobID == "" {
+		http.Error(w, "worker_id and job_id are required", http.StatusBadRequest)
+		return
 	}
+	if !p.authorizeWorker(w, r, payload.WorkerID) {
+		return
+	}
+	job, ok := p.jobStore.Get(payload.JobID)
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if job.WorkerID != payload.WorkerID {
+		slog.Warn("result rejected, job is not leased to the worker", "job_id", payload.JobID,
+			"worker_id", payload.WorkerID, "leased_to", job.WorkerID)
+		http.Error(w, "Job is not leased to this worker", http.StatusConflict)
+		return
+	}
+
+	if payload.Result != nil && !payload.SerializedAt.IsZero() {
+		payload.Result.Timeline = append(payload.Result.Timeline, api.TimelineEntry{
+			Stage: api.StageResultSerialized, At: payload.SerializedAt, WorkerID: payload.WorkerID,
+		})
+	}
+	p.completeJob(r.Context(), payload.JobID, payload.Result)
+	w.WriteHeader(http.StatusOK)
+}
 
+type resultPayload struct {
+	WorkerID string         `json:"worker_id"`
+	JobID    string         `json:"job_id"`
+	Result   *api.JobResult `json:"result"`
+	// SerializedAt is when the worker finished encoding the result.
+	SerializedAt time.Time `json:"serialized_at,omitzero"`
+}
+
+// decodeResultPayload reads a worker's result: JSON, or a raw Arrow IPC
+// stream with the job in the query parameters and the rest of the result in
+// api.ResultHeader.
+func decodeResultPayload(r *http.Request) (resultPayload, error) {
 	var payload resultPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		http.Error(w, "Invalid result payload", http.StatusBadRequest)
-		return
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != api.ArrowStreamMediaType {
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		return payload, err
 	}
+	payload.WorkerID = r.URL.Query().Get("worker_id")
+	payload.JobID = r.URL.Query().Get("job_id")
+	payload.Result = &api.JobResult{}
+	if header := r.Header.Get(api.ResultHeader); header != "" {
+		if err := json.Unmarshal([]byte(header), payload.Result); err != nil {
+			return payload, err
+		}
+	}
+	stream, err := io.ReadAll(r.Body)
+	if err != nil {
+		return payload, err
+	}
+	payload.Result.Arrow = stream
+	return payload, nil
+}
 
-	if !p.resultStore.Notify(payload.JobID, payload.Result) {
-		slog.Warn("result received for timed-out or unknown job", "job_id", payload.JobID)
+// completeJob records the result of a job and frees the worker's and the
+// class' slot for the next job.
+func (p *Proxy) completeJob(ctx context.Context, jobID string, result *api.JobResult) {
+	if job, ok := p.jobStore.Get(jobID); ok {
+		if handler, ok := p.registry.Get(job.WorkerID); ok {
+			handler.ReleaseJob(job.ID)
+		}
+		if result != nil {
+			result.Timeline.Add(api.StageResultReceived, job.WorkerID)
+		}
+	}
+	if p.jobStore.Complete(jobID, result) {
+		if job, ok := p.jobStore.Get(jobID); ok {
+			p.metrics.observeFinished(job)
+			if job.Status == api.StatusCompleted && job.CacheKey != "" {
+				p.cache.Put(job.CacheKey, result)
+			}
+		}
+		p.resultStore.Notify(jobID, result)
+	} else {
+		slog.Warn("result received for unknown or finished job", "job_id", jobID)
 	}
 
-	w.WriteHeader(http.StatusOK)
+	// The finished job might have freed a slot for a queued job of its class.
+	if p.scheduler.Done(jobID) {
+		p.dispatchQueued(ctx)
+	}
 }
 
 // HealthCheckHandler provides a simple endpoint for health checks.
@@ -0,0 +1,361 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"skein/internal/api"
+	"skein/internal/auth"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestProxy() *Proxy {
+	return newTestProxyWithClasses([]ClassConfig{{Class: api.ClassUser}, {Class: api.ClassSystem}})
+}
+
+func newTestProxyWithClasses(classes []ClassConfig) *Proxy {
+	return NewProxy(NewWorkerRegistry(), NewScheduler(classes, 0, 0), NewResultStore(), NewJobStore())
+}
+
+func submitJob(t *testing.T, p *Proxy, req api.QueryRequest) string {
+	t.Helper()
+	body, err := json.Marshal(req)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	p.SubmitJobHandler(rec, httptest.NewRequest(http.MethodPost, "/jobs", bytes.NewReader(body)))
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	var resp api.QueryResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	require.NotEmpty(t, resp.JobID)
+	return resp.JobID
+}
+
+func getJob(t *testing.T, p *Proxy, jobID string) api.Job {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/jobs/"+jobID, nil)
+	req.SetPathValue("id", jobID)
+	rec := httptest.NewRecorder()
+	p.JobStatusHandler(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var job api.Job
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&job))
+	return job
+}
+
+func getJobResult(p *Proxy, jobID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/jobs/"+jobID+"/result", nil)
+	req.SetPathValue("id", jobID)
+	rec := httptest.NewRecorder()
+	p.JobResultHandler(rec, req)
+	return rec
+}
+
+// fetchNextJob simulates a worker pulling the next job from the queue.
+func fetchNextJob(t *testing.T, p *Proxy, workerID string) api.Job {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	p.JobDispatcherHandler(rec, httptest.NewRequest(http.MethodGet, "/internal/job/next?worker_id="+workerID, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var job api.Job
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&job))
+	return job
+}
+
+// postResult posts the result as the worker the job is dispatched to.
+func postResult(t *testing.T, p *Proxy, jobID string, result string) {
+	t.Helper()
+	job, ok := p.jobStore.Get(jobID)
+	require.True(t, ok)
+	rec := postWorkerResult(p, job.WorkerID, jobID, result)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func postWorkerResult(p *Proxy, workerID, jobID, result string) *httptest.ResponseRecorder {
+	body := `{"worker_id":"` + workerID + `","job_id":"` + jobID + `","result":` + result + `}`
+	rec := httptest.NewRecorder()
+	p.ResultHandler(rec, httptest.NewRequest(http.MethodPost, "/internal/job/result", bytes.NewBufferString(body)))
+	return rec
+}
+
+func TestAsyncJobLifecycle(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+
+	jobID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 42 AS answer", Priority: api.PriorityNormal})
+
+	job := getJob(t, p, jobID)
+	assert.Equal(t, api.StatusPending, job.Status)
+	assert.Equal(t, "u1", job.UserID)
+
+	rec := getJobResult(p, jobID)
+	assert.Equal(t, http.StatusAccepted, rec.Code, "result of a pending job is not available yet")
+
+	dispatched := fetchNextJob(t, p, worker.ID)
+	assert.Equal(t, jobID, dispatched.ID)
+
+	job = getJob(t, p, jobID)
+	assert.Equal(t, api.StatusRunning, job.Status)
+	assert.Equal(t, worker.ID, job.WorkerID)
+
+	postResult(t, p, jobID, `{"column_names":["answer"],"column_types":[{"type":"BIGINT"}],"column_data":[[42]]}`)
+
+	job = getJob(t, p, jobID)
+	assert.Equal(t, api.StatusCompleted, job.Status)
+	assert.Nil(t, job.Result, "status endpoint does not carry the result")
+
+	rec = getJobResult(p, jobID)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var results api.QueryResults
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&results))
+	assert.Equal(t, []string{"answer"}, results.ColumnNames)
+	assert.Equal(t, []interface{}{api.Column{Values: []int64{42}}}, results.ColumnData)
+}
+
+func TestAsyncJobFailed(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+
+	jobID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT broken"})
+	fetchNextJob(t, p, worker.ID)
+	postResult(t, p, jobID, `{"error":"syntax error"}`)
+
+	assert.Equal(t, api.StatusFailed, getJob(t, p, jobID).Status)
+
+	rec := getJobResult(p, jobID)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	var results api.QueryResults
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&results))
+	assert.Equal(t, "syntax error", results.Error)
+}
+
+func TestJobNotFound(t *testing.T) {
+	p := newTestProxy()
+
+	req := httptest.NewRequest(http.MethodGet, "/jobs/missing", nil)
+	req.SetPathValue("id", "missing")
+	rec := httptest.NewRecorder()
+	p.JobStatusHandler(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	assert.Equal(t, http.StatusNotFound, getJobResult(p, "missing").Code)
+}
+
+func TestJobDispatcherHandler_HighestPriorityFirst(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+
+	low := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1", Priority: api.PriorityLow})
+	high := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 2", Priority: api.PriorityHigh})
+	normal := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 3", Priority: api.PriorityNormal})
+
+	assert.Equal(t, high, fetchNextJob(t, p, worker.ID).ID)
+	assert.Equal(t, normal, fetchNextJob(t, p, worker.ID).ID)
+	assert.Equal(t, low, fetchNextJob(t, p, worker.ID).ID)
+}
+
+func TestJobDispatcherHandler_ClassLimit(t *testing.T) {
+	p := newTestProxyWithClasses([]ClassConfig{{Class: api.ClassUser, MaxConcurrent: 1}, {Class: api.ClassSystem, MaxConcurrent: 1}})
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+
+	first := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1"})
+	second := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 2"})
+	system := submitJob(t, p, api.QueryRequest{UserID: "cron", Query: "SELECT 3", Class: api.ClassSystem})
+
+	assert.Equal(t, first, fetchNextJob(t, p, worker.ID).ID)
+	assert.Equal(t, system, fetchNextJob(t, p, worker.ID).ID, "user class is at its limit")
+
+	postResult(t, p, first, `{"column_names":["a"],"column_types":[{"type":"BIGINT"}],"column_data":[[1]]}`)
+	assert.Equal(t, second, fetchNextJob(t, p, worker.ID).ID)
+}
+
+func TestSubmitJobHandler_UnknownClass(t *testing.T) {
+	p := newTestProxy()
+
+	rec := httptest.NewRecorder()
+	body := `{"user_id":"u1","query":"SELECT 1","class":"batch"}`
+	p.SubmitJobHandler(rec, httptest.NewRequest(http.MethodPost, "/jobs", bytes.NewBufferString(body)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func cancelJob(p *Proxy, jobID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodDelete, "/jobs/"+jobID, nil)
+	req.SetPathValue("id", jobID)
+	rec := httptest.NewRecorder()
+	p.CancelJobHandler(rec, req)
+	return rec
+}
+
+func TestJobDispatcherHandler_SkipsJobCancelledInFlight(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+
+	jobID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1"})
+	// The job left the queue for the worker's channel and is cancelled before the worker takes it.
+	job := p.scheduler.Next(nil)
+	require.NotNil(t, job)
+	require.Equal(t, http.StatusOK, cancelJob(p, jobID).Code)
+	go func() { worker.JobChannel <- job }()
+
+	rec := httptest.NewRecorder()
+	p.JobDispatcherHandler(rec, httptest.NewRequest(http.MethodGet, "/internal/job/next?worker_id="+worker.ID, nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code, "the cancelled job isn't sent")
+	assert.Equal(t, 0, p.scheduler.Running(api.ClassUser), "its concurrency slot is released")
+	assert.Equal(t, 1, worker.FreeSlots(), "the worker holds no lease for it")
+	assert.Equal(t, api.StatusCancelled, getJob(t, p, jobID).Status)
+}
+
+func TestCancelJobHandler_Queued(t *testing.T) {
+	p := newTestProxy()
+
+	jobID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1"})
+	assert.Equal(t, 1, p.scheduler.Queued(api.ClassUser))
+
+	rec := cancelJob(p, jobID)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 0, p.scheduler.Queued(api.ClassUser), "cancelled job is removed from the queue")
+	assert.Equal(t, api.StatusCancelled, getJob(t, p, jobID).Status)
+	assert.Equal(t, http.StatusConflict, getJobResult(p, jobID).Code)
+
+	assert.Equal(t, http.StatusConflict, cancelJob(p, jobID).Code, "job is already finished")
+	assert.Equal(t, http.StatusNotFound, cancelJob(p, "missing").Code)
+}
+
+func TestCancelJobHandler_Running(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+
+	jobID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1"})
+	fetchNextJob(t, p, worker.ID)
+
+	watch := make(chan int, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		p.CancelWatchHandler(rec, httptest.NewRequest(http.MethodGet, "/internal/job/cancel?worker_id="+worker.ID+"&job_id="+jobID, nil))
+		watch <- rec.Code
+	}()
+
+	require.Equal(t, http.StatusOK, cancelJob(p, jobID).Code)
+	select {
+	case code := <-watch:
+		assert.Equal(t, http.StatusOK, code, "worker is told about the cancellation")
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancellation watch did not return")
+	}
+
+	postResult(t, p, jobID, `{"error":"query cancelled","cancelled":true}`)
+	assert.Equal(t, api.StatusCancelled, getJob(t, p, jobID).Status)
+	assert.Equal(t, 0, p.scheduler.Running(api.ClassUser), "cancelled job released its slot")
+}
+
+func TestQueryHandler_ClientDisconnectCancelsJob(t *testing.T) {
+	p := newTestProxy()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{"user_id":"u1","query":"SELECT 1"}`)).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	p.QueryHandler(rec, req)
+
+	assert.Equal(t, 499, rec.Code)
+	assert.Equal(t, 0, p.scheduler.Queued(api.ClassUser), "job of a disconnected client is removed from the queue")
+}
+
+func TestJobDispatcherHandler_MultiSlotWorker(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(2, api.WorkerCapacity{})
+
+	first := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1"})
+	second := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 2"})
+
+	assert.Equal(t, first, fetchNextJob(t, p, worker.ID).ID)
+	assert.Equal(t, second, fetchNextJob(t, p, worker.ID).ID)
+	assert.Equal(t, 0, worker.FreeSlots())
+
+	postResult(t, p, first, `{"column_names":["a"],"column_types":[{"type":"BIGINT"}],"column_data":[[1]]}`)
+	assert.Equal(t, 1, worker.FreeSlots())
+}
+
+func TestJobDispatcherHandler_WorkerSize(t *testing.T) {
+	p := newTestProxy()
+	small := p.registry.Register(1, api.WorkerCapacity{Size: api.SizeSmall})
+	large := p.registry.Register(1, api.WorkerCapacity{Size: api.SizeLarge})
+
+	heavy := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1", Priority: api.PriorityHigh, Size: api.SizeLarge})
+	light := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 2", Priority: api.PriorityLow})
+
+	assert.Equal(t, light, fetchNextJob(t, p, small.ID).ID, "the large job doesn't fit the small worker")
+	assert.Equal(t, heavy, fetchNextJob(t, p, large.ID).ID)
+	assert.Equal(t, api.SizeLarge, getJob(t, p, heavy).Size)
+}
+
+func TestSubmitJobHandler_UnknownSize(t *testing.T) {
+	p := newTestProxy()
+
+	rec := httptest.NewRecorder()
+	body := `{"user_id":"u1","query":"SELECT 1","size":"huge"}`
+	p.SubmitJobHandler(rec, httptest.NewRequest(http.MethodPost, "/jobs", bytes.NewBufferString(body)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestSubmitJobHandler_PrincipalOverridesUserID(t *testing.T) {
+	p := newTestProxy()
+	handler := auth.Middleware(auth.NewAPIKeys(map[string]string{"k-alice": "alice"}), http.HandlerFunc(p.SubmitJobHandler))
+
+	req := httptest.NewRequest(http.MethodPost, "/jobs", bytes.NewBufferString(`{"user_id":"bob","query":"SELECT 1"}`))
+	req.Header.Set(auth.APIKeyHeader, "k-alice")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	var resp api.QueryResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, "alice", getJob(t, p, resp.JobID).UserID)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", bytes.NewBufferString(`{"user_id":"bob","query":"SELECT 1"}`)))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestJobHandlers_OtherPrincipalsJob(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+	keys := auth.NewAPIKeys(map[string]string{"k-alice": "alice", "k-bob": "bob"})
+	jobID := submitJob(t, p, api.QueryRequest{UserID: "alice", Query: "SELECT 1"})
+	fetchNextJob(t, p, worker.ID)
+	postResult(t, p, jobID, `{"column_names":["a"],"column_types":[{"type":"INTEGER"}],"column_data":[[1]]}`)
+
+	call := func(handler http.HandlerFunc, method, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/jobs/"+jobID, nil)
+		req.SetPathValue("id", jobID)
+		req.Header.Set(auth.APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		auth.Middleware(keys, handler).ServeHTTP(rec, req)
+		return rec
+	}
+	for name, handler := range map[string]http.HandlerFunc{
+		"status": p.JobStatusHandler,
+		"result": p.JobResultHandler,
+	} {
+		rec := call(handler, http.MethodGet, "k-bob")
+		assert.Equal(t, http.StatusNotFound, rec.Code, name)
+		assert.Equal(t, "Job not found\n", rec.Body.String(), name)
+		assert.Equal(t, http.StatusOK, call(handler, http.MethodGet, "k-alice").Code, name)
+	}
+	assert.Equal(t, "Job not found\n", call(p.DownloadHandler, http.MethodGet, "k-bob").Body.String())
+	assert.Equal(t, "Job has no exported file\n", call(p.DownloadHandler, http.MethodGet, "k-alice").Body.String())
+
+	jobID = submitJob(t, p, api.QueryRequest{UserID: "alice", Query: "SELECT 2"})
+	assert.Equal(t, http.StatusNotFound, call(p.CancelJobHandler, http.MethodDelete, "k-bob").Code)
+	assert.Equal(t, api.StatusPending, getJob(t, p, jobID).Status, "bob can't cancel alice's job")
+	assert.Equal(t, http.StatusOK, call(p.CancelJobHandler, http.MethodDelete, "k-alice").Code)
+}
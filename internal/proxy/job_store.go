@@ -0,0 +1,357 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"skein/internal/api"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrJobNotFound is returned for an unknown job ID.
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobFinished is returned when a finished job can't be changed anymore.
+	ErrJobFinished = errors.New("job already finished")
+)
+
+const (
+	jobRetention       = 1 * time.Hour
+	jobCleanupInterval = 5 * time.Minute
+)
+
+// JobBackend persists jobs, so they survive a proxy restart.
+type JobBackend interface {
+	// Save stores the current state of the job and records its status.
+	Save(job *api.Job) error
+	// Load returns all stored jobs.
+	Load() ([]*api.Job, error)
+	// Delete removes an expired job.
+	Delete(jobID string) error
+}
+
+// JobStore keeps track of submitted jobs and their results, so that clients
+// can poll a job's status and fetch its result after the submitting request
+// has returned.
+type JobStore struct {
+	mu   sync.RWMutex
+	jobs map[string]*api.Job
+	// cancels holds a channel per job that is closed when the job is cancelled.
+	cancels map[string]chan struct{}
+	// backend, if set, gets every change of a job. The changes are queued in
+	// writes and written in order by writeLoop, outside of mu, so requests
+	// don't wait for the disk.
+	backend    JobBackend
+	writeMu    sync.Mutex
+	writeCond  *sync.Cond
+	writes     []jobWrite
+	writing    bool
+	closed     bool
+	writerDone chan struct{}
+}
+
+// jobWrite is a queued change for the backend: a snapshot of a job to save,
+// or the ID of a job to delete.
+type jobWrite struct {
+	job      *api.Job
+	deleteID string
+}
+
+// NewJobStore creates a new in-memory JobStore and starts its cleanup process.
+func NewJobStore() *JobStore {
+	s := &JobStore{
+		jobs:    make(map[string]*api.Job),
+		cancels: make(map[string]chan struct{}),
+	}
+	go s.cleanupLoop()
+	return s
+}
+
+// NewPersistentJobStore creates a JobStore that writes every change of its
+// jobs to the backend in the background, loads the jobs the backend already
+// holds and starts its cleanup process. Close it before the backend.
+func NewPersistentJobStore(backend JobBackend) (*JobStore, error) {
+	jobs, err := backend.Load()
+	if err != nil {
+		return nil, fmt.Errorf("loading jobs: %w", err)
+	}
+	s := &JobStore{
+		jobs:       make(map[string]*api.Job, len(jobs)),
+		cancels:    make(map[string]chan struct{}, len(jobs)),
+		backend:    backend,
+		writerDone: make(chan struct{}),
+	}
+	s.writeCond = sync.NewCond(&s.writeMu)
+	for _, job := range jobs {
+		s.jobs[job.ID] = job
+		s.cancels[job.ID] = make(chan struct{})
+		if job.Status == api.StatusCancelled {
+			close(s.cancels[job.ID])
+		}
+	}
+	slog.Info("jobs loaded", "jobs", len(jobs))
+	go s.writeLoop()
+	go s.cleanupLoop()
+	return s, nil
+}
+
+// Add stores a copy of the job.
+func (s *JobStore) Add(job *api.Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *job
+	s.jobs[job.ID] = &stored
+	s.cancels[job.ID] = make(chan struct{})
+	s.persist(&stored)
+}
+
+// Get returns a snapshot of the job with the given ID.
+func (s *JobStore) Get(jobID string) (api.Job, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	job, ok := s.jobs[jobID]
+	if !ok {
+		return api.Job{}, false
+	}
+	snapshot := *job
+	snapshot.Timeline = slices.Clone(job.Timeline)
+	return snapshot, true
+}
+
+// MarkRunning records that the job has been handed to a worker.
+func (s *JobStore) MarkRunning(dispatched *api.Job) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[dispatched.ID]
+	if !ok || isFinished(job.Status) {
+		return false
+	}
+	job.Status = api.StatusRunning
+	job.WorkerID = dispatched.WorkerID
+	job.Attempts = dispatched.Attempts
+	job.DispatchedAt = dispatched.DispatchedAt
+	job.UpdatedAt = time.Now().UTC()
+	job.Timeline = append(job.Timeline, api.TimelineEntry{
+		Stage: api.StageDispatched, At: dispatched.DispatchedAt, WorkerID: dispatched.WorkerID,
+	})
+	s.persist(job)
+	return true
+}
+
+// Requeue moves a running job back to pending, e.g. after its worker was lost.
+func (s *JobStore) Requeue(jobID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[jobID]
+	if !ok || isFinished(job.Status) {
+		return false
+	}
+	job.Status = api.StatusPending
+	job.WorkerID = ""
+	job.UpdatedAt = time.Now().UTC()
+	job.Timeline.Add(api.StageQueued, "")
+	s.persist(job)
+	return true
+}
+
+// Complete stores the result of a job, adds the stages of the result's
+// timeline to the job's and moves it to its final status.
+// A cancelled job stays cancelled whatever the result says. It returns false
+// for an unknown job or a job that already has its result, e.g. a job that
+// was redelivered and finished by another worker.
+func (s *JobStore) Complete(jobID string, result *api.JobResult) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[jobID]
+	if !ok || job.Status == api.StatusCompleted || job.Status == api.StatusFailed {
+		return false
+	}
+	switch {
+	case job.Status == api.StatusCancelled || (result != nil && result.Cancelled):
+		job.Status = api.StatusCancelled
+	case result == nil || result.Error != "":
+		job.Status = api.StatusFailed
+	default:
+		job.Status = api.StatusCompleted
+	}
+	job.Result = result
+	job.UpdatedAt = time.Now().UTC()
+	if result != nil {
+		job.Timeline = append(job.Timeline, result.Timeline...)
+	}
+	s.persist(job)
+	return true
+}
+
+// Cancel marks an unfinished job as cancelled and signals the worker
+// running it, if any. It returns the job as it was before the cancellation.
+func (s *JobStore) Cancel(jobID string) (api.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[jobID]
+	if !ok {
+		return api.Job{}, ErrJobNotFound
+	}
+	prev := *job
+	if isFinished(job.Status) {
+		return prev, ErrJobFinished
+	}
+	job.Status = api.StatusCancelled
+	job.Result = &api.JobResult{Error: "job cancelled", Cancelled: true}
+	job.UpdatedAt = time.Now().UTC()
+	close(s.cancels[jobID])
+	s.persist(job)
+	return prev, nil
+}
+
+// List returns snapshots of the jobs of userID, or of all users if it's
+// empty, with the given status, or any status if it's empty. The newest jobs
+// come first, at most limit of them, and without their results.
+func (s *JobStore) List(userID string, status api.JobStatus, limit int) []api.Job {
+	s.mu.RLock()
+	var jobs []api.Job
+	for _, job := range s.jobs {
+		if (userID == "" || job.UserID == userID) && (status == "" || job.Status == status) {
+			snapshot := *job
+			snapshot.Result = nil
+			snapshot.Timeline = slices.Clone(job.Timeline)
+			jobs = append(jobs, snapshot)
+		}
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(jobs, func(a, b api.Job) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs
+}
+
+// Unfinished returns copies of the pending and running jobs.
+func (s *JobStore) Unfinished() []*api.Job {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var jobs []*api.Job
+	for _, job := range s.jobs {
+		if !isFinished(job.Status) {
+			stored := *job
+			jobs = append(jobs, &stored)
+		}
+	}
+	return jobs
+}
+
+// persist queues a snapshot of the job for the backend, if any. It's called
+// with the lock held, so the backend sees the changes of a job in order.
+func (s *JobStore) persist(job *api.Job) {
+	if s.backend == nil {
+		return
+	}
+	snapshot := *job
+	snapshot.Timeline = slices.Clone(job.Timeline)
+	s.enqueue(jobWrite{job: &snapshot})
+}
+
+func (s *JobStore) enqueue(write jobWrite) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.writes = append(s.writes, write)
+	s.writeCond.Broadcast()
+}
+
+// writeLoop writes the queued changes to the backend until the store is closed.
+func (s *JobStore) writeLoop() {
+	defer close(s.writerDone)
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	for {
+		for len(s.writes) == 0 && !s.closed {
+			s.writeCond.Wait()
+		}
+		if len(s.writes) == 0 {
+			return
+		}
+		batch := s.writes
+		s.writes = nil
+		s.writing = true
+		s.writeMu.Unlock()
+
+		for _, write := range batch {
+			if write.job == nil {
+				if err := s.backend.Delete(write.deleteID); err != nil {
+					slog.Error("failed to delete persisted job", "job_id", write.deleteID, "error", err)
+				}
+				continue
+			}
+			if err := s.backend.Save(write.job); err != nil {
+				slog.Error("failed to persist job", "job_id", write.job.ID, "status", write.job.Status, "error", err)
+			}
+		}
+
+		s.writeMu.Lock()
+		s.writing = false
+		s.writeCond.Broadcast()
+	}
+}
+
+// Flush waits until the backend has all the changes made so far.
+func (s *JobStore) Flush() {
+	if s.backend == nil {
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	for len(s.writes) > 0 || s.writing {
+		s.writeCond.Wait()
+	}
+}
+
+// Close writes the queued changes to the backend and stops writing. The
+// backend can be closed afterwards.
+func (s *JobStore) Close() {
+	if s.backend == nil {
+		return
+	}
+	s.writeMu.Lock()
+	s.closed = true
+	s.writeCond.Broadcast()
+	s.writeMu.Unlock()
+	<-s.writerDone
+}
+
+// CancelSignal returns a channel that is closed when the job gets cancelled.
+func (s *JobStore) CancelSignal(jobID string) (<-chan struct{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ch, ok := s.cancels[jobID]
+	return ch, ok
+}
+
+// cleanupLoop periodically removes finished jobs older than jobRetention.
+func (s *JobStore) cleanupLoop() {
+	ticker := time.NewTicker(jobCleanupInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mu.Lock()
+		for id, job := range s.jobs {
+			if isFinished(job.Status) && time.Since(job.UpdatedAt) > jobRetention {
+				delete(s.jobs, id)
+				delete(s.cancels, id)
+				if s.backend != nil {
+					s.enqueue(jobWrite{deleteID: id})
+				}
+				slog.Debug("removed expired job", "job_id", id)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func isFinished(status api.JobStatus) bool {
+	return status == api.StatusCompleted || status == api.StatusFailed || status == api.StatusCancelled
+}
@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"skein/internal/api"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memBackend is a JobBackend keeping the saved jobs in memory.
+type memBackend struct {
+	mu       sync.Mutex
+	jobs     map[string]api.Job
+	order    []string
+	statuses map[string][]api.JobStatus
+}
+
+func newMemBackend() *memBackend {
+	return &memBackend{jobs: make(map[string]api.Job), statuses: make(map[string][]api.JobStatus)}
+}
+
+func (b *memBackend) Save(job *api.Job) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.jobs[job.ID]; !ok {
+		b.order = append(b.order, job.ID)
+	}
+	b.jobs[job.ID] = *job
+	b.statuses[job.ID] = append(b.statuses[job.ID], job.Status)
+	return nil
+}
+
+func (b *memBackend) Load() ([]*api.Job, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var jobs []*api.Job
+	for _, id := range b.order {
+		if job, ok := b.jobs[id]; ok {
+			jobs = append(jobs, &job)
+		}
+	}
+	return jobs, nil
+}
+
+func (b *memBackend) Delete(jobID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.jobs, jobID)
+	return nil
+}
+
+func TestPersistentJobStore_SurvivesRestart(t *testing.T) {
+	backend := newMemBackend()
+	jobStore, err := NewPersistentJobStore(backend)
+	require.NoError(t, err)
+	p := NewProxy(NewWorkerRegistry(), NewScheduler([]ClassConfig{{Class: api.ClassUser}}, 0, 0), NewResultStore(), jobStore)
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+
+	doneID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1 AS a"})
+	fetchNextJob(t, p, worker.ID)
+	postResult(t, p, doneID, `{"column_names":["a"],"column_types":[{"type":"INTEGER"}],"column_data":[[1]]}`)
+	runningID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 2"})
+	fetchNextJob(t, p, worker.ID)
+	queuedID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 3"})
+	jobStore.Close()
+	assert.Equal(t, []api.JobStatus{api.StatusPending, api.StatusRunning, api.StatusCompleted}, backend.statuses[doneID])
+
+	// A new proxy process over the same backend.
+	jobStore, err = NewPersistentJobStore(backend)
+	require.NoError(t, err)
+	p = NewProxy(NewWorkerRegistry(), NewScheduler([]ClassConfig{{Class: api.ClassUser}}, 0, 0), NewResultStore(), jobStore)
+	worker = p.registry.Register(1, api.WorkerCapacity{})
+
+	done := getJob(t, p, doneID)
+	assert.Equal(t, api.StatusCompleted, done.Status)
+	assert.Equal(t, 200, getJobResult(p, doneID).Code, "the result is still available")
+
+	running := getJob(t, p, runningID)
+	assert.Equal(t, api.StatusPending, running.Status, "the running job starts over")
+	assert.Empty(t, running.WorkerID)
+
+	assert.Equal(t, runningID, fetchNextJob(t, p, worker.ID).ID, "jobs keep their place in the queue")
+	postResult(t, p, runningID, `{"column_names":["a"],"column_types":[{"type":"INTEGER"}],"column_data":[[2]]}`)
+	assert.Equal(t, queuedID, fetchNextJob(t, p, worker.ID).ID)
+}
+
+// blockingBackend is a memBackend whose Save waits until release is closed.
+type blockingBackend struct {
+	*memBackend
+	release chan struct{}
+}
+
+func (b *blockingBackend) Save(job *api.Job) error {
+	<-b.release
+	return b.memBackend.Save(job)
+}
+
+func TestPersistentJobStore_WritesOutsideTheLock(t *testing.T) {
+	backend := &blockingBackend{memBackend: newMemBackend(), release: make(chan struct{})}
+	jobStore, err := NewPersistentJobStore(backend)
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		jobStore.Add(&api.Job{ID: "j1", Status: api.StatusPending})
+		jobStore.MarkRunning(&api.Job{ID: "j1", WorkerID: "w1", Attempts: 1})
+		jobStore.Complete("j1", &api.JobResult{})
+		jobStore.Get("j1")
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job store waited for the backend")
+	}
+
+	close(backend.release)
+	jobStore.Flush()
+	assert.Equal(t, []api.JobStatus{api.StatusPending, api.StatusRunning, api.StatusCompleted}, backend.statuses["j1"],
+		"the backend gets the changes in order")
+	jobStore.Close()
+}
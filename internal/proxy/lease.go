@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"skein/internal/api"
+	"skein/internal/settings"
+	"time"
+)
+
+const leaseCheckInterval = 1 * time.Second
+
+// leaseLoop periodically removes stale workers and redelivers the jobs of
+// removed workers and the jobs whose lease expired.
+func (p *Proxy) leaseLoop() {
+	ticker := time.NewTicker(leaseCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		p.checkLeases(time.Now().UTC())
+	}
+}
+
+func (p *Proxy) checkLeases(now time.Time) {
+	var lost int
+	for _, handler := range p.registry.RemoveStale() {
+		lost += p.redeliverAll(handler.TakeJobs(), "worker removed")
+	}
+	for _, handler := range p.registry.Workers() {
+		lost += p.redeliverAll(handler.ExpiredJobs(now), "lease expired")
+	}
+	if lost > 0 {
+		p.dispatchQueued(context.Background())
+	}
+}
+
+func (p *Proxy) redeliverAll(jobs []*api.Job, reason string) int {
+	for _, job := range jobs {
+		p.redeliver(job, reason)
+	}
+	return len(jobs)
+}
+
+// redeliver puts a job lost by its worker back into its queue, or fails it
+// once it was handed to workers settings.MaxDeliveryAttempts times.
+// The caller is responsible for releasing the job's lease.
+func (p *Proxy) redeliver(job *api.Job, reason string) {
+	p.scheduler.Done(job.ID)
+	if stored, ok := p.jobStore.Get(job.ID); !ok || isFinished(stored.Status) {
+		return
+	}
+
+	if job.Attempts >= settings.MaxDeliveryAttempts {
+		slog.Error("job lost too many times, giving up", "event", "query.lost", "job_id", job.ID,
+			"worker_id", job.WorkerID, "attempts", job.Attempts, "reason", reason)
+		result := &api.JobResult{Error: fmt.Sprintf("job lost after %d delivery attempts: %s", job.Attempts, reason)}
+		p.jobStore.Complete(job.ID, result)
+		p.resultStore.Notify(job.ID, result)
+		return
+	}
+
+	slog.Warn("redelivering job", "event", "query.redelivered", "job_id", job.ID,
+		"worker_id", job.WorkerID, "attempts", job.Attempts, "reason", reason)
+	p.jobStore.Requeue(job.ID)
+	job.Status = api.StatusPending
+	job.WorkerID = ""
+	job.UpdatedAt = time.Now().UTC()
+	p.scheduler.Add(job)
+}
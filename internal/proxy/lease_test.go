@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"skein/internal/api"
+	"skein/internal/settings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ackJob(p *Proxy, workerID, jobID string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(api.JobAck{WorkerID: workerID, JobID: jobID})
+	rec := httptest.NewRecorder()
+	p.AckJobHandler(rec, httptest.NewRequest(http.MethodPost, "/internal/job/ack", bytes.NewReader(body)))
+	return rec
+}
+
+func heartbeat(t *testing.T, p *Proxy, workerID string, jobIDs ...string) api.HeartbeatResponse {
+	t.Helper()
+	body, err := json.Marshal(api.HeartbeatRequest{WorkerID: workerID, JobIDs: jobIDs})
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	p.HeartbeatHandler(rec, httptest.NewRequest(http.MethodPost, "/internal/worker/heartbeat", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp api.HeartbeatResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	return resp
+}
+
+func TestLease_UnackedJobIsRedelivered(t *testing.T) {
+	p := newTestProxy()
+	first := p.registry.Register(1, api.WorkerCapacity{})
+	second := p.registry.Register(1, api.WorkerCapacity{})
+
+	jobID := submitJob(t, p, api.QueryRequest{Query: "SELECT 1"})
+	assert.Equal(t, 1, fetchNextJob(t, p, first.ID).Attempts)
+
+	p.checkLeases(time.Now().UTC().Add(settings.JobAckTimeout + time.Second))
+
+	job := getJob(t, p, jobID)
+	assert.Equal(t, api.StatusPending, job.Status)
+	assert.Empty(t, job.WorkerID)
+	assert.Equal(t, 1, first.FreeSlots(), "the expired lease frees the slot")
+
+	dispatched := fetchNextJob(t, p, second.ID)
+	assert.Equal(t, jobID, dispatched.ID)
+	assert.Equal(t, 2, dispatched.Attempts)
+	assert.Equal(t, second.ID, getJob(t, p, jobID).WorkerID)
+
+	assert.Equal(t, http.StatusConflict, ackJob(p, first.ID, jobID).Code, "the first worker lost the job")
+	assert.Equal(t, http.StatusOK, ackJob(p, second.ID, jobID).Code)
+}
+
+func TestLease_HeartbeatRenewsAckedJob(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+
+	jobID := submitJob(t, p, api.QueryRequest{Query: "SELECT 1"})
+	fetchNextJob(t, p, worker.ID)
+	require.Equal(t, http.StatusOK, ackJob(p, worker.ID, jobID).Code)
+
+	p.checkLeases(time.Now().UTC().Add(settings.JobAckTimeout + time.Second))
+	assert.Equal(t, api.StatusRunning, getJob(t, p, jobID).Status, "an acked job outlives the ack timeout")
+
+	resp := heartbeat(t, p, worker.ID, jobID, "unknown")
+	assert.Equal(t, []string{"unknown"}, resp.LostJobIDs)
+
+	p.checkLeases(time.Now().UTC().Add(settings.JobLeaseDuration / 2))
+	assert.Equal(t, api.StatusRunning, getJob(t, p, jobID).Status)
+
+	p.checkLeases(time.Now().UTC().Add(settings.JobLeaseDuration + time.Second))
+	assert.Equal(t, api.StatusPending, getJob(t, p, jobID).Status, "the lease expires without heartbeats")
+	assert.Equal(t, []string{jobID}, heartbeat(t, p, worker.ID, jobID).LostJobIDs)
+}
+
+func TestLease_MaxDeliveryAttempts(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+
+	jobID := submitJob(t, p, api.QueryRequest{Query: "SELECT 1"})
+	for i := 1; i <= settings.MaxDeliveryAttempts; i++ {
+		require.Equal(t, i, fetchNextJob(t, p, worker.ID).Attempts)
+		p.checkLeases(time.Now().UTC().Add(settings.JobAckTimeout + time.Second))
+	}
+
+	job := getJob(t, p, jobID)
+	assert.Equal(t, api.StatusFailed, job.Status)
+	assert.Equal(t, settings.MaxDeliveryAttempts, job.Attempts)
+	assert.Equal(t, 0, p.scheduler.Queued(api.ClassUser))
+	assert.Equal(t, 0, p.scheduler.Running(api.ClassUser))
+
+	rec := getJobResult(p, jobID)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "delivery attempts")
+}
+
+func TestDeregisterWorkerHandler_RedeliversJobs(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+
+	jobID := submitJob(t, p, api.QueryRequest{Query: "SELECT 1"})
+	fetchNextJob(t, p, worker.ID)
+
+	rec := httptest.NewRecorder()
+	p.DeregisterWorkerHandler(rec, httptest.NewRequest(http.MethodPost, "/internal/worker/goodbye?worker_id="+worker.ID, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, api.StatusPending, getJob(t, p, jobID).Status)
+	assert.Equal(t, 1, p.scheduler.Queued(api.ClassUser))
+}
+
+func TestResultHandler_LateResultOfRedeliveredJob(t *testing.T) {
+	p := newTestProxy()
+	first := p.registry.Register(1, api.WorkerCapacity{})
+	second := p.registry.Register(1, api.WorkerCapacity{})
+
+	jobID := submitJob(t, p, api.QueryRequest{Query: "SELECT 1"})
+	fetchNextJob(t, p, first.ID)
+	p.checkLeases(time.Now().UTC().Add(settings.JobAckTimeout + time.Second))
+	require.Equal(t, 1, p.scheduler.Queued(api.ClassUser))
+
+	result := `{"column_names":["x"],"column_types":[{"type":"INTEGER"}],"column_data":[[1]]}`
+	assert.Equal(t, http.StatusConflict, postWorkerResult(p, first.ID, jobID, result).Code,
+		"the first worker lost the job")
+	assert.Equal(t, api.StatusPending, getJob(t, p, jobID).Status)
+
+	fetchNextJob(t, p, second.ID)
+	assert.Equal(t, http.StatusConflict, postWorkerResult(p, first.ID, jobID, result).Code)
+	require.Equal(t, http.StatusOK, postWorkerResult(p, second.ID, jobID, result).Code)
+	assert.Equal(t, api.StatusCompleted, getJob(t, p, jobID).Status)
+
+	postResult(t, p, jobID, `{"error":"duplicate"}`)
+	assert.Equal(t, api.StatusCompleted, getJob(t, p, jobID).Status, "a duplicate result is ignored")
+}
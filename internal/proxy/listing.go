@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"skein/internal/api"
+	"skein/internal/auth"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultJobListLimit = 50
+	maxJobListLimit     = 1000
+)
+
+// ListJobsHandler lists the newest jobs, without their results. The user_id
+// and status query parameters filter them and limit caps their number. An
+// authenticated caller only sees its own jobs.
+func (p *Proxy) ListJobsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	userID := query.Get("user_id")
+	if principal, ok := auth.PrincipalFrom(r.Context()); ok {
+		userID = principal
+	}
+	status := api.JobStatus(query.Get("status"))
+	switch status {
+	case "", api.StatusPending, api.StatusRunning, api.StatusCompleted, api.StatusFailed, api.StatusCancelled:
+	default:
+		http.Error(w, "Unknown job status", http.StatusBadRequest)
+		return
+	}
+	limit := defaultJobListLimit
+	if s := query.Get("limit"); s != "" {
+		var err error
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 || limit > maxJobListLimit {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	jobs := p.jobStore.List(userID, status, limit)
+	if jobs == nil {
+		jobs = []api.Job{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// ListWorkersHandler lists the registered workers.
+func (p *Proxy) ListWorkersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	workers := make([]api.WorkerInfo, 0)
+	for _, handler := range p.registry.Workers() {
+		workers = append(workers, handler.Info())
+	}
+	slices.SortFunc(workers, func(a, b api.WorkerInfo) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(workers)
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"skein/internal/api"
+	"skein/internal/auth"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func listJobs(t *testing.T, p *Proxy, r *http.Request) []api.Job {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	p.ListJobsHandler(rec, r)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var jobs []api.Job
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&jobs))
+	return jobs
+}
+
+func TestListJobsHandler(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+	first := submitJob(t, p, api.QueryRequest{UserID: "alice", Query: "SELECT 1"})
+	time.Sleep(time.Millisecond)
+	second := submitJob(t, p, api.QueryRequest{UserID: "alice", Query: "SELECT 2"})
+	bobs := submitJob(t, p, api.QueryRequest{UserID: "bob", Query: "SELECT 3"})
+	fetchNextJob(t, p, worker.ID)
+	postResult(t, p, first, `{"column_names":["a"],"column_types":[{"type":"INTEGER"}],"column_data":[[1]]}`)
+
+	ids := func(jobs []api.Job) []string {
+		var ids []string
+		for _, job := range jobs {
+			assert.Nil(t, job.Result)
+			ids = append(ids, job.ID)
+		}
+		return ids
+	}
+	assert.ElementsMatch(t, []string{first, second, bobs}, ids(listJobs(t, p, httptest.NewRequest(http.MethodGet, "/jobs", nil))))
+	assert.Equal(t, []string{second, first}, ids(listJobs(t, p, httptest.NewRequest(http.MethodGet, "/jobs?user_id=alice", nil))), "newest first")
+	assert.Equal(t, []string{second}, ids(listJobs(t, p, httptest.NewRequest(http.MethodGet, "/jobs?user_id=alice&limit=1", nil))))
+	assert.Equal(t, []string{first}, ids(listJobs(t, p, httptest.NewRequest(http.MethodGet, "/jobs?status=completed", nil))))
+
+	r := httptest.NewRequest(http.MethodGet, "/jobs?user_id=alice", nil)
+	r = r.WithContext(auth.WithPrincipal(r.Context(), "bob"))
+	assert.Equal(t, []string{bobs}, ids(listJobs(t, p, r)), "authenticated callers only see their own jobs")
+
+	for _, query := range []string{"status=done", "limit=0", "limit=x"} {
+		rec := httptest.NewRecorder()
+		p.ListJobsHandler(rec, httptest.NewRequest(http.MethodGet, "/jobs?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+func TestListWorkersHandler(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.RegisterNamed("etl-1", 2, api.WorkerCapacity{Size: api.SizeLarge})
+	submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1"})
+	fetchNextJob(t, p, worker.ID)
+
+	rec := httptest.NewRecorder()
+	p.ListWorkersHandler(rec, httptest.NewRequest(http.MethodGet, "/workers", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var workers []api.WorkerInfo
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&workers))
+	require.Len(t, workers, 1)
+	assert.Equal(t, worker.ID, workers[0].ID)
+	assert.Equal(t, "etl-1", workers[0].Name)
+	assert.Equal(t, 2, workers[0].Slots)
+	assert.Equal(t, 1, workers[0].Running)
+	assert.Equal(t, api.SizeLarge, workers[0].Capacity.Size)
+	assert.NotContains(t, rec.Body.String(), worker.Token())
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"net/http"
+	"skein/internal/api"
+	"skein/internal/metrics"
+	"strconv"
+	"time"
+)
+
+// proxyMetrics are the proxy's Prometheus metrics.
+type proxyMetrics struct {
+	registry        *metrics.Registry
+	dispatchLatency *metrics.Histogram
+	queryDuration   *metrics.Histogram
+	resultSize      *metrics.Histogram
+}
+
+func newProxyMetrics(p *Proxy) *proxyMetrics {
+	r := metrics.NewRegistry()
+	m := &proxyMetrics{
+		registry: r,
+		dispatchLatency: r.NewHistogram("skein_dispatch_latency_seconds",
+			"Time from the submission of a job to its dispatch to a worker.", metrics.DefBuckets, "class"),
+		queryDuration: r.NewHistogram("skein_query_duration_seconds",
+			"Time from the submission of a job to its result, by final status.", metrics.DefBuckets, "class", "status"),
+		resultSize: r.NewHistogram("skein_result_size_bytes",
+			"Estimated size of received JSON and Arrow results.", metrics.ExponentialBuckets(1024, 4, 10), "format"),
+	}
+	r.NewGaugeFunc("skein_queue_depth", "Queued jobs by class and priority.", []string{"class", "priority"},
+		func(set func(float64, ...string)) {
+			for _, class := range p.scheduler.Classes() {
+				for priority, n := range p.scheduler.QueuedByPriority(class) {
+					set(float64(n), string(class), strconv.Itoa(int(priority)))
+				}
+			}
+		})
+	r.NewGaugeFunc("skein_jobs_running", "Running jobs by class.", []string{"class"},
+		func(set func(float64, ...string)) {
+			for _, class := range p.scheduler.Classes() {
+				set(float64(p.scheduler.Running(class)), string(class))
+			}
+		})
+	r.NewGaugeFunc("skein_workers_registered", "Registered workers.", nil,
+		func(set func(float64, ...string)) {
+			set(float64(len(p.registry.Workers())))
+		})
+	r.NewGaugeFunc("skein_workers_ready", "Workers with a free slot waiting for a job.", nil,
+		func(set func(float64, ...string)) {
+			ready := 0
+			for _, handler := range p.registry.Workers() {
+				if handler.IsReady() {
+					ready++
+				}
+			}
+			set(float64(ready))
+		})
+	return m
+}
+
+func (m *proxyMetrics) observeDispatch(job *api.Job, now time.Time) {
+	m.dispatchLatency.Observe(now.Sub(job.CreatedAt).Seconds(), string(job.Class))
+}
+
+// observeFinished records a job that just got its final status. Only JSON
+// and Arrow results are sized, the rows of streamed and exported results
+// never reach the job's stored result.
+func (m *proxyMetrics) observeFinished(job api.Job) {
+	m.queryDuration.Observe(time.Since(job.CreatedAt).Seconds(), string(job.Class), string(job.Status))
+	if job.Result == nil || job.Result.Error != "" {
+		return
+	}
+	format := job.Format
+	if format == "" {
+		format = api.FormatJSON
+	}
+	if format == api.FormatJSON || format == api.FormatArrow {
+		m.resultSize.Observe(float64(resultSize(job.Result)), string(format))
+	}
+}
+
+// MetricsHandler serves the proxy's metrics in the Prometheus text format.
+func (p *Proxy) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	p.metrics.registry.Handler().ServeHTTP(w, r)
+}
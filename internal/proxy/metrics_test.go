@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"skein/internal/api"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, p *Proxy) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	p.MetricsHandler(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	return rec.Body.String()
+}
+
+func TestMetricsHandler(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+	submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1", Priority: api.PriorityHigh})
+	submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 2", Priority: api.PriorityLow})
+	submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 3", Priority: api.PriorityLow})
+
+	body := scrape(t, p)
+	assert.Contains(t, body, `skein_queue_depth{class="user",priority="20"} 1`)
+	assert.Contains(t, body, `skein_queue_depth{class="user",priority="0"} 2`)
+	assert.Contains(t, body, "skein_workers_registered 1\n")
+	assert.Contains(t, body, "skein_workers_ready 0\n")
+
+	job := fetchNextJob(t, p, worker.ID)
+	postResult(t, p, job.ID, `{"column_names":["1"],"column_types":[{"type":"INTEGER"}],"column_data":[[1]]}`)
+
+	body = scrape(t, p)
+	assert.NotContains(t, body, `skein_queue_depth{class="user",priority="20"}`)
+	assert.Contains(t, body, `skein_dispatch_latency_seconds_count{class="user"} 1`)
+	assert.Contains(t, body, `skein_query_duration_seconds_count{class="user",status="completed"} 1`)
+	assert.Contains(t, body, `skein_result_size_bytes_count{format="json"} 1`)
+}
+
+func TestMetricsHandler_ResultSizeSkipsExports(t *testing.T) {
+	p := newTestProxy()
+	p.exports = NewExportStore(t.TempDir())
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+	jobID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1", Format: api.FormatCSV})
+	fetchNextJob(t, p, worker.ID)
+	postResult(t, p, jobID, `{"export":{"file_name":"out.csv","size_bytes":3,"rows":1}}`)
+
+	body := scrape(t, p)
+	assert.Contains(t, body, `skein_query_duration_seconds_count{class="user",status="completed"} 1`)
+	assert.NotContains(t, body, `skein_result_size_bytes_count`)
+}
@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"skein/internal/api"
+	"skein/internal/settings"
+	"strconv"
+	"strings"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// isPageRequest reports whether the client asks for a page of the result
+// instead of the whole result.
+func isPageRequest(r *http.Request) bool {
+	q := r.URL.Query()
+	return q.Has("cursor") || q.Has("limit")
+}
+
+// writeResultPage writes the page of a finished job's result selected by the
+// request's cursor and limit query parameters.
+func (p *Proxy) writeResultPage(w http.ResponseWriter, r *http.Request, job api.Job) {
+	if job.Status != api.StatusCompleted || job.Result == nil {
+		p.writeJobResult(w, job)
+		return
+	}
+	if job.Format != "" && job.Format != api.FormatJSON {
+		http.Error(w, "Only JSON results can be paginated", http.StatusBadRequest)
+		return
+	}
+
+	limit := settings.ResultPageLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > settings.MaxResultPageLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", settings.MaxResultPageLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	result := job.Result
+	total := resultRows(result)
+	offset := 0
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		var err error
+		if offset, err = decodeCursor(job.ID, cursor); err != nil || offset > total {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+	end := min(offset+limit, total)
+
+	page := api.ResultPage{
+		ColumnNames: result.ColumnNames,
+		ColumnTypes: result.ColumnTypes,
+		ColumnData:  sliceColumns(result.ColumnData, offset, end),
+		Offset:      offset,
+		TotalRows:   total,
+	}
+	if end < total {
+		page.NextCursor = encodeCursor(job.ID, end)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		slog.Error("failed to encode result page", "job_id", job.ID, "error", err)
+	}
+}
+
+// encodeCursor returns an opaque token for the job's result starting at offset.
+func encodeCursor(jobID string, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(jobID + ":" + strconv.Itoa(offset)))
+}
+
+// decodeCursor returns the offset of a cursor issued for the job.
+func decodeCursor(jobID, cursor string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errInvalidCursor
+	}
+	id, s, ok := strings.Cut(string(b), ":")
+	if !ok || id != jobID {
+		return 0, errInvalidCursor
+	}
+	offset, err := strconv.Atoi(s)
+	if err != nil || offset < 0 {
+		return 0, errInvalidCursor
+	}
+	return offset, nil
+}
+
+// resultRows returns the number of rows of a columnar result.
+func resultRows(result *api.JobResult) int {
+	if len(result.ColumnData) == 0 || result.ColumnData[0] == nil {
+		return 0
+	}
+	return api.AsColumn(result.ColumnData[0]).Len()
+}
+
+// sliceColumns returns the rows [from, to) of every column.
+func sliceColumns(columns []interface{}, from, to int) []interface{} {
+	sliced := make([]interface{}, len(columns))
+	for i, col := range columns {
+		if col == nil {
+			continue
+		}
+		sliced[i] = api.AsColumn(col).Slice(from, to)
+	}
+	return sliced
+}
@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"skein/internal/api"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getResultPage(p *Proxy, jobID string, query url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/jobs/"+jobID+"/result?"+query.Encode(), nil)
+	req.SetPathValue("id", jobID)
+	rec := httptest.NewRecorder()
+	p.JobResultHandler(rec, req)
+	return rec
+}
+
+func TestJobResultHandler_Pagination(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+	jobID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT * FROM range(5)"})
+	fetchNextJob(t, p, worker.ID)
+	postResult(t, p, jobID, `{"column_names":["id","name"],"column_types":[{"name":"id","type":"BIGINT"},{"name":"name","type":"VARCHAR"}],`+
+		`"column_data":[[1,2,3,4,5],["a","b","c","d","e"]]}`)
+
+	var (
+		ids    []float64
+		cursor string
+		pages  int
+	)
+	for {
+		query := url.Values{"limit": {"2"}}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		rec := getResultPage(p, jobID, query)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var page api.ResultPage
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+		assert.Equal(t, []string{"id", "name"}, page.ColumnNames)
+		assert.Len(t, page.ColumnTypes, 2)
+		assert.Equal(t, 5, page.TotalRows)
+		assert.Equal(t, len(ids), page.Offset)
+		for _, id := range page.ColumnData[0].([]interface{}) {
+			ids = append(ids, id.(float64))
+		}
+		pages++
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	assert.Equal(t, 3, pages)
+	assert.Equal(t, []float64{1, 2, 3, 4, 5}, ids)
+
+	rec := getJobResult(p, jobID)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var results api.QueryResults
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&results))
+	assert.Equal(t, jobID, results.JobID, "the whole result is still available")
+}
+
+func TestJobResultHandler_InvalidPage(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+	jobID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1"})
+	otherID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1"})
+	fetchNextJob(t, p, worker.ID)
+	postResult(t, p, jobID, `{"column_names":["a"],"column_types":[{"name":"a","type":"INTEGER"}],"column_data":[[1,2,3]]}`)
+
+	for name, query := range map[string]url.Values{
+		"zero limit":         {"limit": {"0"}},
+		"limit too large":    {"limit": {"100000000"}},
+		"garbage cursor":     {"cursor": {"not a cursor"}},
+		"cursor past end":    {"cursor": {encodeCursor(jobID, 4)}},
+		"other job's cursor": {"cursor": {encodeCursor(otherID, 1)}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, http.StatusBadRequest, getResultPage(p, jobID, query).Code)
+		})
+	}
+
+	rec := getResultPage(p, jobID, url.Values{"cursor": {encodeCursor(jobID, 3)}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	var page api.ResultPage
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+	assert.Empty(t, page.ColumnData[0])
+	assert.Empty(t, page.NextCursor)
+
+	assert.Equal(t, http.StatusAccepted, getResultPage(p, otherID, url.Values{"limit": {"1"}}).Code,
+		"the job is still queued")
+}
+
+func TestJobResultHandler_PageKeepsNulls(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+	jobID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT n FROM t"})
+	fetchNextJob(t, p, worker.ID)
+	postResult(t, p, jobID, `{"column_names":["n"],"column_types":[{"type":"INTEGER","nullable":true}],"column_data":[[1,null,0,null]]}`)
+
+	rec := getResultPage(p, jobID, url.Values{"limit": {"2"}, "cursor": {encodeCursor(jobID, 1)}})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var page struct {
+		ColumnData json.RawMessage `json:"column_data"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+	assert.JSONEq(t, `[[null, 0]]`, string(page.ColumnData))
+
+	rec = getJobResult(p, jobID)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+	assert.JSONEq(t, `[[1, null, 0, null]]`, string(page.ColumnData))
+}
@@ -1,52 +1,117 @@
 package proxy
 
 import (
+	"container/heap"
 	"log/slog"
 	"skein/internal/api"
 	"sync"
+	"time"
 )
 
-// JobQueue represents a simple FIFO queue for jobs.
+// JobQueue is a priority queue for jobs waiting for a worker.
+//
+// Jobs with a higher priority are served first, jobs with the same priority
+// are served in submission order. With aging enabled, a job gains agingStep
+// priority for every agingInterval it has been waiting, so low priority jobs
+// are eventually promoted. Because every job ages at the same rate, the
+// relative order of two queued jobs never changes over time and a plain heap
+// keyed on (priority - age at submission) is enough.
 type JobQueue struct {
-	mu   sync.Mutex
-	jobs []*api.Job
+	mu            sync.Mutex
+	items         jobHeap
+	byID          map[string]*queueItem
+	seq           uint64
+	epoch         time.Time
+	agingInterval time.Duration
+	agingStep     api.Priority
 }
 
-// NewJobQueue creates and returns a new FIFO JobQueue.
-func NewJobQueue() *JobQueue {
+// NewJobQueue creates and returns a new JobQueue. An agingInterval of zero
+// disables aging.
+func NewJobQueue(agingInterval time.Duration, agingStep api.Priority) *JobQueue {
 	return &JobQueue{
-		jobs: make([]*api.Job, 0),
+		items:         make(jobHeap, 0),
+		byID:          make(map[string]*queueItem),
+		epoch:         time.Now().UTC(),
+		agingInterval: agingInterval,
+		agingStep:     agingStep,
 	}
 }
 
-// Add adds a job to the end of the queue.
+// Add adds a job to the queue. The job's CreatedAt is used as its
+// submission time, so a job put back into the queue keeps its place.
 func (q *JobQueue) Add(job *api.Job) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	q.jobs = append(q.jobs, job)
-	slog.Info("query queued", "event", "query.queued", "job_id", job.ID, "user_id", job.UserID)
+	if _, ok := q.byID[job.ID]; ok {
+		return
+	}
+	q.seq++
+	item := &queueItem{
+		job:   job,
+		score: q.score(job),
+		seq:   q.seq,
+	}
+	heap.Push(&q.items, item)
+	q.byID[job.ID] = item
+	slog.Info("query queued", "event", "query.queued", "job_id", job.ID, "user_id", job.UserID, "priority", job.Priority)
 }
 
-// Get retrieves and removes the first job from the queue.
+// Get retrieves and removes the job with the highest effective priority.
 // Returns nil if the queue is empty.
 func (q *JobQueue) Get() *api.Job {
+	return q.GetFunc(nil)
+}
+
+// GetFunc retrieves and removes the job with the highest effective priority
+// accepted by fn, a nil fn accepts any job.
+// Returns nil if there's no such job.
+func (q *JobQueue) GetFunc(fn func(*api.Job) bool) *api.Job {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	if len(q.jobs) == 0 {
-		return nil
-	}
+	var skipped []*queueItem
+	defer func() {
+		for _, item := range skipped {
+			heap.Push(&q.items, item)
+		}
+	}()
 
-	job := q.jobs[0]
-	q.jobs = q.jobs[1:] // Remove the job from the queue
-	return job
+	for len(q.items) > 0 {
+		item := heap.Pop(&q.items).(*queueItem)
+		if fn == nil || fn(item.job) {
+			delete(q.byID, item.job.ID)
+			return item.job
+		}
+		skipped = append(skipped, item)
+	}
+	return nil
 }
 
 // IsEmpty checks if the queue is empty.
 func (q *JobQueue) IsEmpty() bool {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	return len(q.jobs) == 0
+	return len(q.items) == 0
+}
+
+// Len returns the number of queued jobs.
+func (q *JobQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// CountByPriority returns the number of queued jobs per priority they were
+// submitted with.
+func (q *JobQueue) CountByPriority() map[api.Priority]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	counts := make(map[api.Priority]int)
+	for _, item := range q.items {
+		counts[item.job.Priority]++
+	}
+	return counts
 }
 
 // Remove removes a job from the queue by its ID.
@@ -55,12 +120,66 @@ func (q *JobQueue) Remove(jobID string) bool {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	for i, job := range q.jobs {
-		if job.ID == jobID {
-			// Remove the element at index i
-			q.jobs = append(q.jobs[:i], q.jobs[i+1:]...)
-			return true
-		}
+	item, ok := q.byID[jobID]
+	if !ok {
+		return false
 	}
-	return false
+	heap.Remove(&q.items, item.index)
+	delete(q.byID, jobID)
+	return true
+}
+
+// score returns the job's effective priority as it would be at the queue's
+// epoch. Comparing scores is equivalent to comparing effective priorities at
+// any later point in time.
+func (q *JobQueue) score(job *api.Job) float64 {
+	score := float64(job.Priority)
+	if q.agingInterval > 0 {
+		waited := q.epoch.Sub(job.CreatedAt)
+		score += float64(q.agingStep) * float64(waited) / float64(q.agingInterval)
+	}
+	return score
+}
+
+type queueItem struct {
+	job   *api.Job
+	score float64
+	seq   uint64
+	index int
+}
+
+// jobHeap implements heap.Interface, the highest score is on top.
+type jobHeap []*queueItem
+
+func (h jobHeap) Len() int { return len(h) }
+
+func (h jobHeap) Less(i, j int) bool {
+	if h[i].score != h[j].score {
+		return h[i].score > h[j].score
+	}
+	if !h[i].job.CreatedAt.Equal(h[j].job.CreatedAt) {
+		return h[i].job.CreatedAt.Before(h[j].job.CreatedAt)
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h jobHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *jobHeap) Push(x any) {
+	item := x.(*queueItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *jobHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
 }
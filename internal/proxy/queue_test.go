@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"skein/internal/api"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func queuedJob(id string, priority api.Priority, createdAt time.Time) *api.Job {
+	return &api.Job{ID: id, Priority: priority, CreatedAt: createdAt}
+}
+
+func drain(q *JobQueue) []string {
+	var ids []string
+	for job := q.Get(); job != nil; job = q.Get() {
+		ids = append(ids, job.ID)
+	}
+	return ids
+}
+
+func TestJobQueue_PriorityOrder(t *testing.T) {
+	q := NewJobQueue(0, 0)
+	now := time.Now().UTC()
+
+	q.Add(queuedJob("low-1", api.PriorityLow, now))
+	q.Add(queuedJob("normal-1", api.PriorityNormal, now.Add(1*time.Millisecond)))
+	q.Add(queuedJob("high-1", api.PriorityHigh, now.Add(2*time.Millisecond)))
+	q.Add(queuedJob("normal-2", api.PriorityNormal, now.Add(3*time.Millisecond)))
+	q.Add(queuedJob("high-2", api.PriorityHigh, now.Add(4*time.Millisecond)))
+	q.Add(queuedJob("low-2", api.PriorityLow, now.Add(5*time.Millisecond)))
+
+	assert.Equal(t, 6, q.Len())
+	assert.Equal(t, []string{"high-1", "high-2", "normal-1", "normal-2", "low-1", "low-2"}, drain(q))
+	assert.True(t, q.IsEmpty())
+}
+
+func TestJobQueue_Aging(t *testing.T) {
+	q := NewJobQueue(10*time.Second, api.PriorityNormal-api.PriorityLow)
+	now := time.Now().UTC()
+
+	// Waited for two aging intervals, which is enough to catch up with a fresh high priority job.
+	q.Add(queuedJob("old-low", api.PriorityLow, now.Add(-21*time.Second)))
+	// Waited for one interval, promoted to the normal level only.
+	q.Add(queuedJob("waiting-low", api.PriorityLow, now.Add(-11*time.Second)))
+	q.Add(queuedJob("fresh-high", api.PriorityHigh, now))
+	q.Add(queuedJob("fresh-normal", api.PriorityNormal, now))
+
+	assert.Equal(t, []string{"old-low", "fresh-high", "waiting-low", "fresh-normal"}, drain(q))
+}
+
+func TestJobQueue_RequeueKeepsPlace(t *testing.T) {
+	q := NewJobQueue(0, 0)
+	now := time.Now().UTC()
+
+	first := queuedJob("first", api.PriorityNormal, now)
+	q.Add(first)
+	q.Add(queuedJob("second", api.PriorityNormal, now.Add(time.Millisecond)))
+
+	assert.Equal(t, first, q.Get())
+	q.Add(first)
+
+	assert.Equal(t, []string{"first", "second"}, drain(q))
+}
+
+func TestJobQueue_Remove(t *testing.T) {
+	q := NewJobQueue(0, 0)
+	now := time.Now().UTC()
+
+	q.Add(queuedJob("a", api.PriorityHigh, now))
+	q.Add(queuedJob("b", api.PriorityNormal, now))
+	q.Add(queuedJob("c", api.PriorityLow, now))
+
+	assert.True(t, q.Remove("b"))
+	assert.False(t, q.Remove("b"))
+	assert.Equal(t, []string{"a", "c"}, drain(q))
+}
+
+func TestJobQueue_GetFunc(t *testing.T) {
+	q := NewJobQueue(0, 0)
+	now := time.Now().UTC()
+
+	q.Add(queuedJob("a", api.PriorityHigh, now))
+	q.Add(queuedJob("b", api.PriorityNormal, now))
+	q.Add(queuedJob("c", api.PriorityLow, now))
+
+	job := q.GetFunc(func(job *api.Job) bool { return job.ID != "a" })
+	assert.Equal(t, "b", job.ID)
+	assert.Nil(t, q.GetFunc(func(*api.Job) bool { return false }))
+	assert.Equal(t, []string{"a", "c"}, drain(q), "skipped jobs keep their order")
+}
@@ -0,0 +1,310 @@
+package proxy
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"skein/internal/api"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const cacheCleanupInterval = 1 * time.Minute
+
+// ResultCache keeps the results of completed queries for a while, so a query
+// repeated with the same parameters over unchanged files doesn't have to run
+// again. The least recently used results are evicted when the cache grows
+// over its size limit.
+type ResultCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	maxBytes int64
+	size     int64
+	entries  map[string]*list.Element
+	lru      *list.List
+}
+
+type cacheEntry struct {
+	key       string
+	result    *api.JobResult
+	size      int64
+	expiresAt time.Time
+}
+
+// NewResultCache creates a cache keeping results for ttl and at most maxBytes
+// of them, and starts its cleanup process. A zero ttl or maxBytes disables it.
+func NewResultCache(ttl time.Duration, maxBytes int64) *ResultCache {
+	c := &ResultCache{
+		ttl:      ttl,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+	if c.Enabled() {
+		go c.cleanupLoop()
+	}
+	return c
+}
+
+// Enabled reports whether the cache keeps any results.
+func (c *ResultCache) Enabled() bool {
+	return c.ttl > 0 && c.maxBytes > 0
+}
+
+// Get returns the cached result for the key.
+func (c *ResultCache) Get(key string) (*api.JobResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.result, true
+}
+
+// Put caches the result under the key. The result must not be modified
+// afterwards. Results larger than the whole cache are not cached.
+func (c *ResultCache) Put(key string, result *api.JobResult) {
+	size := resultSize(result)
+	if !c.Enabled() || size > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:       key,
+		result:    result,
+		size:      size,
+		expiresAt: time.Now().Add(c.ttl),
+	})
+	c.size += size
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+// Len returns the number of cached results.
+func (c *ResultCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *ResultCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
+
+// cleanupLoop periodically removes expired results.
+func (c *ResultCache) cleanupLoop() {
+	ticker := time.NewTicker(cacheCleanupInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		c.mu.Lock()
+		for elem := c.lru.Back(); elem != nil; {
+			prev := elem.Prev()
+			if now.After(elem.Value.(*cacheEntry).expiresAt) {
+				c.remove(elem)
+			}
+			elem = prev
+		}
+		c.mu.Unlock()
+	}
+}
+
+// cacheKey returns the key of the job's result in the ResultCache. It covers
+// the normalized query text, the parameters and the size and modification
+// time of the local files the query refers to, so a result is not reused
+// once its input files change. Only JSON and Arrow results of queries
+// cacheableQuery accepts are cached.
+func cacheKey(job *api.Job) (string, bool) {
+	switch job.Format {
+	case "", api.FormatJSON, api.FormatArrow:
+	default:
+		return "", false
+	}
+	if !cacheableQuery(job.Query, job.Params) {
+		return "", false
+	}
+	params, err := json.Marshal(job.Params)
+	if err != nil {
+		return "", false
+	}
+
+	h := sha256.New()
+	query, literals := normalizeQuery(job.Query)
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%t\x00", job.Format, query, params, job.DisableProfiling)
+	for _, param := range job.Params {
+		if s, ok := param.(string); ok {
+			literals = append(literals, s)
+		}
+	}
+	for _, version := range fileVersions(literals) {
+		fmt.Fprintf(h, "%s\x00", version)
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// normalizeQuery collapses whitespace outside of quotes and drops a trailing
+// semicolon. It also returns the query's string literals.
+func normalizeQuery(query string) (string, []string) {
+	var (
+		b        strings.Builder
+		literals []string
+		literal  strings.Builder
+		quote    rune
+		space    bool
+	)
+	for _, r := range strings.TrimSpace(query) {
+		switch {
+		case quote != 0:
+			b.WriteRune(r)
+			if r == quote {
+				// An escaped quote ('') splits the literal in two, which
+				// only means the file lookup misses it.
+				if quote == '\'' {
+					literals = append(literals, literal.String())
+				}
+				literal.Reset()
+				quote = 0
+				continue
+			}
+			literal.WriteRune(r)
+		case r == '\'' || r == '"':
+			if space {
+				b.WriteByte(' ')
+				space = false
+			}
+			b.WriteRune(r)
+			quote = r
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			space = b.Len() > 0
+		default:
+			if space {
+				b.WriteByte(' ')
+				space = false
+			}
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimRight(b.String(), "; "), literals
+}
+
+// fileVersions returns the path, size and modification time of the local
+// files matching the candidate paths, sorted. Candidates that aren't files,
+// like most string literals, are skipped.
+//
+// The files are looked up on the proxy while the workers read their own
+// filesystem: the proxy must see the datasets at the same paths as the
+// workers. cacheableQuery turns down queries reading files it can't see,
+// and the proxy warns at startup when it can't see DATASETS_DIR.
+func fileVersions(candidates []string) []string {
+	var versions []string
+	for _, candidate := range candidates {
+		if candidate == "" || strings.Contains(candidate, "://") {
+			continue
+		}
+		paths := []string{candidate}
+		if strings.ContainsAny(candidate, "*?[") {
+			matches, err := filepath.Glob(candidate)
+			if err != nil {
+				continue
+			}
+			paths = matches
+			versions = append(versions, "glob:"+candidate)
+		}
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			versions = append(versions, fmt.Sprintf("%s:%d:%d", path, info.Size(), info.ModTime().UnixNano()))
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// CheckDatasets returns an error when dir, the directory the workers read the
+// datasets from, is missing or empty on the proxy. Queries over datasets the
+// proxy can't see are not cached.
+func CheckDatasets(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("read datasets directory: %w", err)
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("datasets directory %s is empty", dir)
+	}
+	return nil
+}
+
+// resultSize estimates the memory used by a result.
+func resultSize(result *api.JobResult) int64 {
+	size := int64(len(result.Arrow) + len(result.Profile))
+	for _, name := range result.ColumnNames {
+		size += int64(len(name))
+	}
+	for _, data := range result.ColumnData {
+		col := api.AsColumn(data)
+		size += int64(len(col.Validity))
+		if strs, ok := col.Values.([]string); ok {
+			for _, s := range strs {
+				size += int64(len(s)) + 16
+			}
+			continue
+		}
+		if v := reflect.ValueOf(col.Values); v.Kind() == reflect.Slice {
+			size += int64(v.Len()) * int64(v.Type().Elem().Size())
+		}
+	}
+	return size
+}
+
+// serveFromCache completes the job with a cached result if there is one.
+// Otherwise it records the job's cache key, so its result gets cached once
+// it completes.
+func (p *Proxy) serveFromCache(job *api.Job, bypass bool) bool {
+	if !p.cache.Enabled() {
+		return false
+	}
+	key, ok := cacheKey(job)
+	if !ok {
+		return false
+	}
+	job.CacheKey = key
+	if bypass {
+		return false
+	}
+	result, ok := p.cache.Get(key)
+	if !ok {
+		return false
+	}
+
+	job.Status = api.StatusCompleted
+	job.Result = result
+	job.CacheHit = true
+	job.DispatchedAt = job.CreatedAt
+	p.jobStore.Add(job)
+	slog.Info("query served from cache", "event", "query.cache.hit", "job_id", job.ID, "user_id", job.UserID)
+	return true
+}
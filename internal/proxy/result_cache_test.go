@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"skein/internal/api"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runQuery(p *Proxy, req api.QueryRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(req)
+	rec := httptest.NewRecorder()
+	p.QueryHandler(rec, httptest.NewRequest(http.MethodPost, "/query", bytes.NewReader(body)))
+	return rec
+}
+
+func TestQueryHandler_CacheHit(t *testing.T) {
+	p := newTestProxy()
+	p.cache = NewResultCache(time.Minute, 1<<20)
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+
+	jobID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT $n AS n", Params: map[string]interface{}{"n": 1}})
+	fetchNextJob(t, p, worker.ID)
+	postResult(t, p, jobID, `{"column_names":["n"],"column_types":[{"name":"n","type":"INTEGER"}],"column_data":[[1]]}`)
+	require.Equal(t, 1, p.cache.Len())
+
+	rec := runQuery(p, api.QueryRequest{UserID: "u2", Query: "  SELECT $n\n  AS n;", Params: map[string]interface{}{"n": 1}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "HIT", rec.Header().Get("X-Cache"))
+	var results api.QueryResults
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&results))
+	assert.True(t, results.CacheHit)
+	assert.Equal(t, []interface{}{api.Column{Values: []int32{1}}}, results.ColumnData)
+	assert.Equal(t, api.StatusCompleted, getJob(t, p, results.JobID).Status)
+
+	otherID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT $n AS n", Params: map[string]interface{}{"n": 2}})
+	assert.Equal(t, api.StatusPending, getJob(t, p, otherID).Status, "other params miss the cache")
+
+	bypassID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT $n AS n", Params: map[string]interface{}{"n": 1}, BypassCache: true})
+	bypassed := getJob(t, p, bypassID)
+	assert.Equal(t, api.StatusPending, bypassed.Status)
+	assert.False(t, bypassed.CacheHit)
+}
+
+func TestCacheKey_FileVersions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "trips.csv")
+	require.NoError(t, os.WriteFile(path, []byte("a\n1\n"), 0o644))
+
+	job := &api.Job{Query: "SELECT count(*) FROM '" + filepath.Join(dir, "*.csv") + "'"}
+	key, ok := cacheKey(job)
+	require.True(t, ok)
+
+	require.NoError(t, os.WriteFile(path, []byte("a\n1\n2\n"), 0o644))
+	changed, _ := cacheKey(job)
+	assert.NotEqual(t, key, changed, "a changed file changes the key")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "more.csv"), []byte("a\n3\n"), 0o644))
+	added, _ := cacheKey(job)
+	assert.NotEqual(t, changed, added, "a new file matching the glob changes the key")
+
+	_, ok = cacheKey(&api.Job{Query: "SELECT 1", Format: api.FormatNDJSON})
+	assert.False(t, ok)
+	_, ok = cacheKey(&api.Job{Query: "SELECT count(*) FROM trips"})
+	assert.False(t, ok, "worker tables can't be versioned")
+}
+
+func TestCacheableQuery(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "trips.parquet")
+	require.NoError(t, os.WriteFile(path, []byte("PAR1"), 0o644))
+	glob := filepath.Join(dir, "*.parquet")
+	missing := filepath.Join(dir, "missing.parquet")
+
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{query: "SELECT 1", want: true},
+		{query: "SELECT count(*) FROM '" + glob + "';", want: true},
+		{query: "FROM '" + path + "' SELECT count(*)", want: true},
+		{query: "SELECT * FROM read_parquet(['" + path + "', '" + glob + "'], union_by_name = true) AS t(a)", want: true},
+		{query: "SELECT * FROM read_parquet($path)", want: true},
+		{query: "WITH trips AS (SELECT * FROM '" + path + "') SELECT extract(year FROM pickup_at) FROM trips, range(3) r", want: true},
+		{query: "SELECT * FROM (SELECT 1 AS n) s JOIN '" + path + "' p ON s.n = p.n WHERE n IS DISTINCT FROM 2", want: true},
+		{query: "SELECT now()"},
+		{query: "SELECT random() FROM '" + path + "'"},
+		{query: "SELECT * FROM '" + path + "' USING SAMPLE 10%"},
+		{query: "INSERT INTO t VALUES (1)"},
+		{query: "COPY (SELECT 1) TO 'out.csv'"},
+		{query: "CREATE TABLE t AS SELECT 1"},
+		{query: "SELECT 1; DELETE FROM t"},
+		{query: "SELECT * FROM trips"},
+		{query: "SELECT * FROM '" + path + "', trips"},
+		{query: "SELECT * FROM '" + missing + "'"},
+		{query: "SELECT * FROM 'https://example.com/trips.parquet'"},
+		{query: "SELECT * FROM read_csv('s3://bucket/trips.csv')"},
+		{query: "SELECT * FROM '" + dir + "/**/*.parquet'"},
+		{query: "SELECT * FROM postgres_scan('host=db', 'public', 'trips')"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, cacheableQuery(tt.query, map[string]interface{}{"path": path}), tt.query)
+	}
+}
+
+func TestCheckDatasets(t *testing.T) {
+	dir := t.TempDir()
+	assert.Error(t, CheckDatasets(filepath.Join(dir, "missing")))
+	assert.Error(t, CheckDatasets(dir), "an empty directory")
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "taxi"), 0o755))
+	assert.NoError(t, CheckDatasets(dir))
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	query, literals := normalizeQuery("\n SELECT  'a  b', \"x  y\"\n\tFROM   'data.parquet' ;  ")
+	assert.Equal(t, `SELECT 'a  b', "x  y" FROM 'data.parquet'`, query)
+	assert.Equal(t, []string{"a  b", "data.parquet"}, literals)
+}
+
+func TestResultCache_Eviction(t *testing.T) {
+	c := NewResultCache(time.Minute, 100)
+	result := func() *api.JobResult {
+		return &api.JobResult{ColumnData: []interface{}{make([]int64, 5)}}
+	}
+	c.Put("a", result())
+	c.Put("b", result())
+	_, ok := c.Get("a")
+	require.True(t, ok)
+
+	c.Put("c", result())
+	_, ok = c.Get("b")
+	assert.False(t, ok, "the least recently used result is evicted")
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+
+	c.Put("big", &api.JobResult{ColumnData: []interface{}{make([]int64, 100)}})
+	_, ok = c.Get("big")
+	assert.False(t, ok, "a result larger than the cache is not cached")
+	assert.Equal(t, 2, c.Len())
+
+	expiring := NewResultCache(time.Millisecond, 100)
+	expiring.Put("a", result())
+	time.Sleep(5 * time.Millisecond)
+	_, ok = expiring.Get("a")
+	assert.False(t, ok)
+}
@@ -40,7 +40,12 @@ func (rs *ResultStore) Notify(jobID string, result *api.JobResult) bool {
 	defer rs.mu.Unlock()
 
 	if ch, ok := rs.results[jobID]; ok {
-		ch <- result
+		// Only the first result matters, e.g. a cancelled job might still
+		// get a result from its worker.
+		select {
+		case ch <- result:
+		default:
+		}
 		return true
 	}
 	// This can happen if the original request timed out and was deregistered.
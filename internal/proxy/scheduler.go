@@ -0,0 +1,167 @@
+package proxy
+
+import (
+	"log/slog"
+	"skein/internal/api"
+	"sync"
+	"time"
+)
+
+// ClassConfig configures the scheduling of a single query class.
+type ClassConfig struct {
+	Class api.QueryClass
+	// MaxConcurrent is the maximum number of jobs of this class running at
+	// the same time across all workers. Zero or less means no limit.
+	MaxConcurrent int
+}
+
+type classState struct {
+	ClassConfig
+	queue   *JobQueue
+	running map[string]struct{}
+}
+
+func (c *classState) hasCapacity() bool {
+	return c.MaxConcurrent <= 0 || len(c.running) < c.MaxConcurrent
+}
+
+// Scheduler keeps a priority queue per query class and decides which queued
+// job runs next. A class at its concurrency limit is skipped, and classes
+// with queued jobs are served in turns, so neither class can starve the other.
+type Scheduler struct {
+	mu      sync.Mutex
+	classes []*classState
+	byClass map[api.QueryClass]*classState
+	// runningClass maps a running job ID to its class.
+	runningClass map[string]api.QueryClass
+	next         int
+}
+
+// NewScheduler creates a Scheduler with one JobQueue per configured class.
+func NewScheduler(classes []ClassConfig, agingInterval time.Duration, agingStep api.Priority) *Scheduler {
+	s := &Scheduler{
+		byClass:      make(map[api.QueryClass]*classState),
+		runningClass: make(map[string]api.QueryClass),
+	}
+	for _, cfg := range classes {
+		state := &classState{
+			ClassConfig: cfg,
+			queue:       NewJobQueue(agingInterval, agingStep),
+			running:     make(map[string]struct{}),
+		}
+		s.classes = append(s.classes, state)
+		s.byClass[cfg.Class] = state
+	}
+	return s
+}
+
+// HasClass reports whether the scheduler has a queue for the class.
+func (s *Scheduler) HasClass(class api.QueryClass) bool {
+	_, ok := s.byClass[class]
+	return ok
+}
+
+// Add puts a job into the queue of its class.
+func (s *Scheduler) Add(job *api.Job) {
+	state, ok := s.byClass[job.Class]
+	if !ok {
+		slog.Error("job with unknown class, using the first queue", "job_id", job.ID, "class", job.Class)
+		state = s.classes[0]
+	}
+	state.queue.Add(job)
+}
+
+// Next removes the next job to run from the queues and counts it as running.
+// Only jobs accepted by fn are considered, a nil fn accepts any job.
+// Returns nil if there's no such job in a class below its limit.
+func (s *Scheduler) Next(fn func(*api.Job) bool) *api.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.classes {
+		idx := (s.next + i) % len(s.classes)
+		state := s.classes[idx]
+		if !state.hasCapacity() {
+			continue
+		}
+		job := state.queue.GetFunc(fn)
+		if job == nil {
+			continue
+		}
+		state.running[job.ID] = struct{}{}
+		s.runningClass[job.ID] = state.Class
+		s.next = (idx + 1) % len(s.classes)
+		return job
+	}
+	return nil
+}
+
+// Requeue puts back a job returned by Next that couldn't be handed to a worker.
+func (s *Scheduler) Requeue(job *api.Job) {
+	s.Done(job.ID)
+	s.Add(job)
+}
+
+// Done releases the concurrency slot held by a running job.
+// It returns false if the job was not running.
+func (s *Scheduler) Done(jobID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	class, ok := s.runningClass[jobID]
+	if !ok {
+		return false
+	}
+	delete(s.runningClass, jobID)
+	delete(s.byClass[class].running, jobID)
+	return true
+}
+
+// Remove removes a queued job.
+// It returns true if the job was found and removed, false otherwise.
+func (s *Scheduler) Remove(jobID string) bool {
+	for _, state := range s.classes {
+		if state.queue.Remove(jobID) {
+			return true
+		}
+	}
+	return false
+}
+
+// Classes returns the scheduled query classes.
+func (s *Scheduler) Classes() []api.QueryClass {
+	classes := make([]api.QueryClass, len(s.classes))
+	for i, state := range s.classes {
+		classes[i] = state.Class
+	}
+	return classes
+}
+
+// QueuedByPriority returns the number of queued jobs of the class per priority.
+func (s *Scheduler) QueuedByPriority(class api.QueryClass) map[api.Priority]int {
+	state, ok := s.byClass[class]
+	if !ok {
+		return nil
+	}
+	return state.queue.CountByPriority()
+}
+
+// Queued returns the number of queued jobs of the class.
+func (s *Scheduler) Queued(class api.QueryClass) int {
+	state, ok := s.byClass[class]
+	if !ok {
+		return 0
+	}
+	return state.queue.Len()
+}
+
+// Running returns the number of running jobs of the class.
+func (s *Scheduler) Running(class api.QueryClass) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.byClass[class]
+	if !ok {
+		return 0
+	}
+	return len(state.running)
+}
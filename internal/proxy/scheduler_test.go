@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"skein/internal/api"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func classJob(id string, class api.QueryClass, priority api.Priority, createdAt time.Time) *api.Job {
+	return &api.Job{ID: id, Class: class, Priority: priority, CreatedAt: createdAt}
+}
+
+func nextID(s *Scheduler) string {
+	job := s.Next(nil)
+	if job == nil {
+		return ""
+	}
+	return job.ID
+}
+
+func TestScheduler_ConcurrencyLimit(t *testing.T) {
+	s := NewScheduler([]ClassConfig{{Class: api.ClassUser, MaxConcurrent: 2}, {Class: api.ClassSystem, MaxConcurrent: 1}}, 0, 0)
+	now := time.Now().UTC()
+
+	for i, id := range []string{"u1", "u2", "u3"} {
+		s.Add(classJob(id, api.ClassUser, api.PriorityNormal, now.Add(time.Duration(i)*time.Millisecond)))
+	}
+	for i, id := range []string{"s1", "s2"} {
+		s.Add(classJob(id, api.ClassSystem, api.PriorityNormal, now.Add(time.Duration(i)*time.Millisecond)))
+	}
+
+	assert.Equal(t, "u1", nextID(s))
+	assert.Equal(t, "s1", nextID(s))
+	assert.Equal(t, "u2", nextID(s))
+	assert.Equal(t, "", nextID(s), "both classes are at their limit")
+	assert.Equal(t, 2, s.Running(api.ClassUser))
+	assert.Equal(t, 1, s.Running(api.ClassSystem))
+	assert.Equal(t, 1, s.Queued(api.ClassUser))
+	assert.Equal(t, 1, s.Queued(api.ClassSystem))
+
+	assert.True(t, s.Done("s1"))
+	assert.False(t, s.Done("s1"), "a job is released only once")
+	assert.Equal(t, "s2", nextID(s))
+
+	assert.True(t, s.Done("u1"))
+	assert.Equal(t, "u3", nextID(s))
+}
+
+func TestScheduler_ClassesTakeTurns(t *testing.T) {
+	s := NewScheduler([]ClassConfig{{Class: api.ClassUser}, {Class: api.ClassSystem}}, 0, 0)
+	now := time.Now().UTC()
+
+	for i, id := range []string{"u1", "u2", "u3"} {
+		s.Add(classJob(id, api.ClassUser, api.PriorityHigh, now.Add(time.Duration(i)*time.Millisecond)))
+	}
+	for i, id := range []string{"s1", "s2"} {
+		s.Add(classJob(id, api.ClassSystem, api.PriorityLow, now.Add(time.Duration(i)*time.Millisecond)))
+	}
+
+	var got []string
+	for id := nextID(s); id != ""; id = nextID(s) {
+		got = append(got, id)
+	}
+	assert.Equal(t, []string{"u1", "s1", "u2", "s2", "u3"}, got)
+}
+
+func TestScheduler_Requeue(t *testing.T) {
+	s := NewScheduler([]ClassConfig{{Class: api.ClassUser, MaxConcurrent: 1}}, 0, 0)
+	job := classJob("u1", api.ClassUser, api.PriorityNormal, time.Now().UTC())
+	s.Add(job)
+
+	assert.Equal(t, job, s.Next(nil))
+	s.Requeue(job)
+	assert.Equal(t, 0, s.Running(api.ClassUser))
+	assert.Equal(t, job, s.Next(nil), "a requeued job doesn't hold a slot")
+}
+
+func TestScheduler_Remove(t *testing.T) {
+	s := NewScheduler([]ClassConfig{{Class: api.ClassUser}, {Class: api.ClassSystem}}, 0, 0)
+	s.Add(classJob("s1", api.ClassSystem, api.PriorityNormal, time.Now().UTC()))
+
+	assert.True(t, s.Remove("s1"))
+	assert.False(t, s.Remove("s1"))
+	assert.Nil(t, s.Next(nil))
+}
+
+func TestScheduler_NextFunc(t *testing.T) {
+	s := NewScheduler([]ClassConfig{{Class: api.ClassUser}}, 0, 0)
+	now := time.Now().UTC()
+
+	heavy := classJob("heavy", api.ClassUser, api.PriorityHigh, now)
+	heavy.Size = api.SizeLarge
+	s.Add(heavy)
+	s.Add(classJob("light", api.ClassUser, api.PriorityLow, now))
+
+	small := NewWorkerHandler(1, api.WorkerCapacity{Size: api.SizeSmall})
+	assert.Equal(t, "light", s.Next(small.Accepts).ID, "a small worker skips the large job")
+	assert.Nil(t, s.Next(small.Accepts))
+	assert.Equal(t, 1, s.Queued(api.ClassUser))
+	assert.Equal(t, heavy, s.Next(nil))
+}
@@ -0,0 +1,200 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"skein/internal/api"
+	"time"
+)
+
+// StreamResultHandler is an internal endpoint for workers to upload a
+// streamed result. The body is a sequence of api.StreamMessage lines ending
+// with the job's result; the lines before it are relayed to the waiting
+// client as they arrive.
+func (p *Proxy) StreamResultHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	workerID := r.URL.Query().Get("worker_id")
+	jobID := r.URL.Query().Get("job_id")
+	if workerID == "" || jobID == "" {
+		http.Error(w, "worker_id and job_id query parameters are required", http.StatusBadRequest)
+		return
+	}
+	if !p.authorizeWorker(w, r, workerID) {
+		return
+	}
+	job, ok := p.jobStore.Get(jobID)
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if job.WorkerID != workerID {
+		http.Error(w, "Job is not leased to this worker", http.StatusConflict)
+		return
+	}
+	// Without a waiting client the lines are read and dropped, the job still
+	// gets its result.
+	stream, relay := p.streams.Claim(jobID)
+
+	var (
+		result *api.JobResult
+		lines  int
+	)
+	reader := bufio.NewReader(r.Body)
+	for result == nil {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var msg struct {
+				Result *api.JobResult `json:"result"`
+			}
+			if err := json.Unmarshal(line, &msg); err != nil {
+				slog.Error("invalid result stream line", "job_id", jobID, "worker_id", workerID, "error", err)
+				break
+			}
+			if msg.Result != nil {
+				result = msg.Result
+				break
+			}
+			lines++
+			if relay {
+				select {
+				case stream.lines <- line:
+				case <-stream.done:
+					slog.Info("client stopped reading the result stream", "job_id", jobID)
+					relay = false
+				}
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if result == nil {
+		slog.Error("result stream ended without a result", "job_id", jobID, "worker_id", workerID, "lines", lines)
+		result = &api.JobResult{Error: "result stream ended without a result"}
+	}
+	slog.Info("result stream received", "event", "query.result.streamed", "job_id", jobID, "worker_id", workerID, "lines", lines)
+
+	// The lease can expire during a long upload and the job be delivered to
+	// another worker, the stale upload must not complete it.
+	if job, ok := p.jobStore.Get(jobID); !ok || job.WorkerID != workerID {
+		slog.Warn("result stream dropped, job is not leased to the worker anymore", "job_id", jobID,
+			"worker_id", workerID, "leased_to", job.WorkerID)
+		if relay {
+			p.sendStreamError(stream, "result stream interrupted, the job lease was lost")
+			close(stream.lines)
+		}
+		http.Error(w, "Job is not leased to this worker", http.StatusConflict)
+		return
+	}
+	p.completeJob(r.Context(), jobID, result)
+	if relay {
+		p.sendTrailer(jobID, stream)
+		close(stream.lines)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// sendTrailer relays the last line of a result stream, the outcome of the
+// finished job with its profiling data.
+func (p *Proxy) sendTrailer(jobID string, stream *resultStream) {
+	job, _ := p.jobStore.Get(jobID)
+	result := job.Result
+	if result == nil {
+		result = &api.JobResult{Error: "job finished without a result"}
+	}
+	trailer, err := newQueryResults(job, &api.JobResult{
+		Error:     result.Error,
+		Profile:   result.Profile,
+		GoProfile: result.GoProfile,
+	})
+	if err != nil {
+		slog.Error("failed to unmarshal DuckDB profile", "job_id", jobID, "error", err)
+		trailer = api.QueryResults{Error: result.Error}
+	}
+	if job.Status == api.StatusCancelled {
+		trailer.Error = "job cancelled"
+	}
+	trailer.Timeline = p.responseTimeline(job)
+	line, _ := json.Marshal(api.StreamMessage{Trailer: &trailer})
+	select {
+	case stream.lines <- append(line, '\n'):
+	case <-stream.done:
+	}
+}
+
+// sendStreamError ends a relayed result stream with an error trailer.
+func (p *Proxy) sendStreamError(stream *resultStream, message string) {
+	line, _ := json.Marshal(api.StreamMessage{Trailer: &api.QueryResults{Error: message}})
+	select {
+	case stream.lines <- append(line, '\n'):
+	case <-stream.done:
+	}
+}
+
+// streamJobResult relays a streamed result to the client as NDJSON, line by
+// line as the worker uploads it. A job that fails before streaming anything
+// gets a regular JSON error response.
+func (p *Proxy) streamJobResult(w http.ResponseWriter, r *http.Request, jobID string, stream *resultStream, resultChan chan *api.JobResult) {
+	rc := http.NewResponseController(w)
+	timeout := time.After(requestTimeout)
+	started := false
+	for {
+		select {
+		case line, ok := <-stream.lines:
+			if !ok {
+				return
+			}
+			if !started {
+				var msg api.StreamMessage
+				if err := json.Unmarshal(line, &msg); err == nil && msg.Trailer != nil && msg.Trailer.Error != "" {
+					job, _ := p.jobStore.Get(jobID)
+					p.writeJobResult(w, job)
+					return
+				}
+				w.Header().Set("Content-Type", api.NDJSONMediaType)
+				w.WriteHeader(http.StatusOK)
+				started = true
+				timeout = nil
+			}
+			if _, err := w.Write(line); err != nil {
+				slog.Warn("failed to write result stream", "job_id", jobID, "error", err)
+				p.cancelStreamedJob(jobID)
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				slog.Warn("failed to flush result stream", "job_id", jobID, "error", err)
+			}
+		case <-resultChan:
+			resultChan = nil
+			if !p.streams.Claimed(jobID) {
+				// The job finished without streaming, e.g. it was lost too many times.
+				job, _ := p.jobStore.Get(jobID)
+				p.writeJobResult(w, job)
+				return
+			}
+		case <-r.Context().Done():
+			slog.Warn("client cancelled request", "job_id", jobID)
+			p.cancelStreamedJob(jobID)
+			if !started {
+				http.Error(w, "Request cancelled", 499) // 499 Client Closed Request
+			}
+			return
+		case <-timeout:
+			slog.Error("request timed out waiting for result stream", "job_id", jobID)
+			http.Error(w, "Request timed out", http.StatusGatewayTimeout)
+			return
+		}
+	}
+}
+
+func (p *Proxy) cancelStreamedJob(jobID string) {
+	if err := p.cancelJob(jobID); err != nil {
+		slog.Debug("failed to cancel job of a cancelled request", "job_id", jobID, "error", err)
+	}
+}
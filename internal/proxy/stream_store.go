@@ -0,0 +1,70 @@
+package proxy
+
+import "sync"
+
+// resultStream relays the lines of a streamed result from the worker's
+// upload to the client request waiting for them.
+type resultStream struct {
+	// lines is closed by the uploader after the last line.
+	lines chan []byte
+	// done is closed when the client stops reading.
+	done    chan struct{}
+	claimed bool
+}
+
+// StreamStore holds the result streams of the clients waiting for them.
+type StreamStore struct {
+	mu      sync.Mutex
+	streams map[string]*resultStream
+}
+
+// NewStreamStore creates a new StreamStore.
+func NewStreamStore() *StreamStore {
+	return &StreamStore{
+		streams: make(map[string]*resultStream),
+	}
+}
+
+// Register creates the result stream of a job.
+func (s *StreamStore) Register(jobID string) *resultStream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream := &resultStream{
+		lines: make(chan []byte, 16),
+		done:  make(chan struct{}),
+	}
+	s.streams[jobID] = stream
+	return stream
+}
+
+// Claim returns the result stream of a job to its single uploader.
+// It returns false if nobody waits for the stream or it's already claimed.
+func (s *StreamStore) Claim(jobID string) (*resultStream, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream, ok := s.streams[jobID]
+	if !ok || stream.claimed {
+		return nil, false
+	}
+	stream.claimed = true
+	return stream, true
+}
+
+// Deregister removes the result stream of a job and tells its uploader that
+// the client stopped reading.
+func (s *StreamStore) Deregister(jobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stream, ok := s.streams[jobID]; ok {
+		close(stream.done)
+		delete(s.streams, jobID)
+	}
+}
+
+// Claimed reports whether an uploader claimed the result stream of a job.
+func (s *StreamStore) Claimed(jobID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream, ok := s.streams[jobID]
+	return ok && stream.claimed
+}
@@ -0,0 +1,168 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"skein/internal/api"
+	"skein/internal/settings"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startStreamedQuery runs QueryHandler for a streamed query in the background,
+// the returned channel yields the response once the handler is done.
+func startStreamedQuery(p *Proxy, query string) <-chan *httptest.ResponseRecorder {
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(`{"user_id":"u1","query":"`+query+`"}`))
+		req.Header.Set("Accept", api.NDJSONMediaType)
+		rec := httptest.NewRecorder()
+		p.QueryHandler(rec, req)
+		done <- rec
+	}()
+	return done
+}
+
+func uploadStream(p *Proxy, workerID, jobID string, lines ...string) *httptest.ResponseRecorder {
+	body := strings.Join(lines, "\n") + "\n"
+	rec := httptest.NewRecorder()
+	p.StreamResultHandler(rec, httptest.NewRequest(http.MethodPost,
+		"/internal/job/stream?worker_id="+workerID+"&job_id="+jobID, strings.NewReader(body)))
+	return rec
+}
+
+func waitResponse(t *testing.T, done <-chan *httptest.ResponseRecorder) *httptest.ResponseRecorder {
+	t.Helper()
+	select {
+	case rec := <-done:
+		return rec
+	case <-time.After(5 * time.Second):
+		t.Fatal("query handler did not return")
+		return nil
+	}
+}
+
+func TestQueryHandler_StreamedResult(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+
+	done := startStreamedQuery(p, "SELECT range FROM range(3)")
+	job := fetchNextJob(t, p, worker.ID)
+	assert.Equal(t, api.FormatNDJSON, job.Format)
+
+	rec := uploadStream(p, worker.ID, job.ID,
+		`{"schema":{"column_names":["range"],"column_types":[{"type":"BIGINT","nullable":true}]}}`,
+		`{"rows":[[0],[1]]}`,
+		`{"rows":[[2]]}`,
+		`{"result":{"profile":{"rows_returned":3,"cpu_time":0.5},"go_profile":{"execute_time_ns":1000}}}`,
+	)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	resp := waitResponse(t, done)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, api.NDJSONMediaType, resp.Header().Get("Content-Type"))
+
+	var msgs []api.StreamMessage
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var msg api.StreamMessage
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &msg))
+		msgs = append(msgs, msg)
+	}
+	require.Len(t, msgs, 4)
+	require.NotNil(t, msgs[0].Schema)
+	assert.Equal(t, []string{"range"}, msgs[0].Schema.ColumnNames)
+	assert.Len(t, msgs[1].Rows, 2)
+	assert.Len(t, msgs[2].Rows, 1)
+	require.NotNil(t, msgs[3].Trailer)
+	assert.Empty(t, msgs[3].Trailer.Error)
+	assert.Equal(t, 3, msgs[3].Trailer.Profile.RowsReturned)
+	assert.Equal(t, time.Duration(1000), msgs[3].Trailer.GoProfile.ExecuteTime)
+
+	assert.Equal(t, api.StatusCompleted, getJob(t, p, job.ID).Status)
+	assert.Equal(t, 1, worker.FreeSlots())
+}
+
+func TestQueryHandler_StreamedResultFailsBeforeRows(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+
+	done := startStreamedQuery(p, "SELECT broken")
+	job := fetchNextJob(t, p, worker.ID)
+	require.Equal(t, http.StatusOK, uploadStream(p, worker.ID, job.ID, `{"result":{"error":"syntax error"}}`).Code)
+
+	resp := waitResponse(t, done)
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	var results api.QueryResults
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&results))
+	assert.Equal(t, "syntax error", results.Error)
+}
+
+func TestStreamResultHandler_InterruptedUpload(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+	other := p.registry.Register(1, api.WorkerCapacity{})
+
+	jobID := submitJob(t, p, api.QueryRequest{Query: "SELECT 1"})
+	fetchNextJob(t, p, worker.ID)
+
+	assert.Equal(t, http.StatusConflict, uploadStream(p, other.ID, jobID, `{"result":{}}`).Code)
+
+	rec := uploadStream(p, worker.ID, jobID, `{"schema":{"column_names":["x"],"column_types":[{"type":"INTEGER"}]}}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, api.StatusFailed, getJob(t, p, jobID).Status, "a stream without a result fails the job")
+}
+
+func TestSubmitJobHandler_StreamedFormat(t *testing.T) {
+	p := newTestProxy()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/jobs", bytes.NewBufferString(`{"user_id":"u1","query":"SELECT 1"}`))
+	req.Header.Set("Accept", api.NDJSONMediaType)
+	p.SubmitJobHandler(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+// readHook runs fn when the upload reaches it, it adds nothing to the body.
+type readHook func()
+
+func (h readHook) Read([]byte) (int, error) {
+	h()
+	return 0, io.EOF
+}
+
+func TestStreamResultHandler_LeaseLostDuringUpload(t *testing.T) {
+	p := newTestProxy()
+	first := p.registry.Register(1, api.WorkerCapacity{})
+	second := p.registry.Register(1, api.WorkerCapacity{})
+
+	jobID := submitJob(t, p, api.QueryRequest{Query: "SELECT 1"})
+	fetchNextJob(t, p, first.ID)
+
+	body := io.MultiReader(
+		strings.NewReader(`{"schema":{"column_names":["x"],"column_types":[{"type":"INTEGER"}]}}`+"\n"),
+		readHook(func() {
+			p.checkLeases(time.Now().UTC().Add(settings.JobAckTimeout + time.Second))
+			fetchNextJob(t, p, second.ID)
+		}),
+		strings.NewReader(`{"result":{}}`+"\n"),
+	)
+	rec := httptest.NewRecorder()
+	p.StreamResultHandler(rec, httptest.NewRequest(http.MethodPost,
+		"/internal/job/stream?worker_id="+first.ID+"&job_id="+jobID, body))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	job := getJob(t, p, jobID)
+	assert.Equal(t, api.StatusRunning, job.Status, "the stale upload doesn't complete the job")
+	assert.Equal(t, second.ID, job.WorkerID)
+
+	require.Equal(t, http.StatusOK, uploadStream(p, second.ID, jobID, `{"result":{}}`).Code)
+	assert.Equal(t, api.StatusCompleted, getJob(t, p, jobID).Status)
+}
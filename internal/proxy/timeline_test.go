@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"skein/internal/api"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stages(timeline api.Timeline) []api.Stage {
+	var stages []api.Stage
+	for _, e := range timeline {
+		stages = append(stages, e.Stage)
+	}
+	return stages
+}
+
+func TestJobTimeline(t *testing.T) {
+	p := newTestProxy()
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+	jobID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1"})
+	assert.Equal(t, []api.Stage{api.StageReceived, api.StageQueued}, stages(getJob(t, p, jobID).Timeline))
+
+	fetchNextJob(t, p, worker.ID)
+	body := `{"worker_id":"` + worker.ID + `","job_id":"` + jobID + `","serialized_at":"2026-10-16T12:00:02Z","result":{
+		"column_names":["1"],"column_types":[{"type":"INTEGER"}],"column_data":[[1]],
+		"timeline":[
+			{"stage":"worker_started","at":"2026-10-16T12:00:00Z","worker_id":"` + worker.ID + `"},
+			{"stage":"duckdb_finished","at":"2026-10-16T12:00:01Z","worker_id":"` + worker.ID + `"}
+		]}}`
+	rec := httptest.NewRecorder()
+	p.ResultHandler(rec, httptest.NewRequest(http.MethodPost, "/internal/job/result", bytes.NewBufferString(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = getJobResult(p, jobID)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var results api.QueryResults
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&results))
+	want := []api.Stage{
+		api.StageReceived, api.StageQueued, api.StageDispatched, api.StageWorkerStarted, api.StageDuckDBFinished,
+		api.StageResultSerialized, api.StageResultReceived, api.StageResponseSent,
+	}
+	assert.Equal(t, want, stages(results.Timeline))
+	for _, e := range results.Timeline {
+		switch e.Stage {
+		case api.StageReceived, api.StageQueued, api.StageResponseSent:
+			assert.Empty(t, e.WorkerID, e.Stage)
+		default:
+			assert.Equal(t, worker.ID, e.WorkerID, e.Stage)
+		}
+	}
+
+	assert.Equal(t, want[:len(want)-1], stages(getJob(t, p, jobID).Timeline), "reading the result doesn't change the job")
+
+	rec = getJobResult(p, jobID)
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&results))
+	assert.Equal(t, want, stages(results.Timeline), "every response has one response_sent")
+}
+
+func TestJobTimeline_Redelivery(t *testing.T) {
+	p := newTestProxy()
+	first := p.registry.Register(1, api.WorkerCapacity{})
+	second := p.registry.Register(1, api.WorkerCapacity{})
+	jobID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1"})
+
+	job := fetchNextJob(t, p, first.ID)
+	p.registry.Deregister(first.ID)
+	p.redeliver(&job, "worker deregistered")
+	fetchNextJob(t, p, second.ID)
+
+	timeline := getJob(t, p, jobID).Timeline
+	assert.Equal(t, []api.Stage{api.StageReceived, api.StageQueued, api.StageDispatched, api.StageQueued, api.StageDispatched},
+		stages(timeline))
+	last, _ := timeline.Last(api.StageDispatched)
+	assert.Equal(t, second.ID, last.WorkerID)
+}
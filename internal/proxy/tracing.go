@@ -0,0 +1,32 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"skein/internal/api"
+	"skein/internal/tracing"
+	"time"
+)
+
+// SetTracer makes the proxy export the spans of the jobs it handles.
+func (p *Proxy) SetTracer(t *tracing.Tracer) {
+	p.tracer = t
+}
+
+// traceReceive starts the span of receiving the job, a child of the request's
+// traceparent. The span becomes the parent of all later spans of the job, on
+// the proxy and on the worker.
+func (p *Proxy) traceReceive(r *http.Request, job *api.Job) *tracing.Span {
+	_, span := p.tracer.Start(tracing.Extract(r.Context(), r.Header), "skein.proxy.receive",
+		tracing.String("job.id", job.ID), tracing.String("user.id", job.UserID),
+		tracing.String("query.class", string(job.Class)), tracing.Int("query.priority", int(job.Priority)))
+	job.Traceparent = span.Context().Traceparent()
+	return span
+}
+
+// traceJob records a finished step of the job between start and end.
+func (p *Proxy) traceJob(job *api.Job, name string, start, end time.Time) {
+	ctx := tracing.ContextWithTraceparent(context.Background(), job.Traceparent)
+	p.tracer.Record(ctx, name, start, end,
+		tracing.String("job.id", job.ID), tracing.String("worker.id", job.WorkerID), tracing.Int("job.attempt", job.Attempts))
+}
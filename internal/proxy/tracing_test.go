@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"skein/internal/api"
+	"skein/internal/tracing"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracing_JobSpans(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	exporter, err := tracing.NewFileExporter(path)
+	require.NoError(t, err)
+	p := newTestProxy()
+	p.SetTracer(tracing.NewTracer("skein-proxy", exporter))
+	worker := p.registry.Register(1, api.WorkerCapacity{})
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodPost, "/jobs", bytes.NewBufferString(`{"user_id":"u1","query":"SELECT 1"}`))
+	req.Header.Set("traceparent", traceparent)
+	rec := httptest.NewRecorder()
+	p.SubmitJobHandler(rec, req)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	job := fetchNextJob(t, p, worker.ID)
+	require.Equal(t, http.StatusOK, ackJob(p, worker.ID, job.ID).Code)
+	require.NoError(t, exporter.Shutdown(t.Context()))
+
+	spans, err := tracing.ReadSpans(path)
+	require.NoError(t, err)
+	require.Len(t, spans, 3)
+	receive, queueWait, dispatch := spans[0], spans[1], spans[2]
+	assert.Equal(t, "skein.proxy.receive", receive.Name)
+	assert.Equal(t, "skein.proxy.queue_wait", queueWait.Name)
+	assert.Equal(t, "skein.proxy.dispatch", dispatch.Name)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", receive.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", receive.ParentSpanID.String())
+	for _, span := range []tracing.SpanData{queueWait, dispatch} {
+		assert.Equal(t, receive.TraceID, span.TraceID)
+		assert.Equal(t, receive.SpanID, span.ParentSpanID)
+		assert.Equal(t, worker.ID, span.Attributes["worker.id"])
+	}
+
+	// The worker continues the trace from the job's traceparent.
+	sc, err := tracing.ParseTraceparent(job.Traceparent)
+	require.NoError(t, err)
+	assert.Equal(t, receive.TraceID, sc.TraceID)
+	assert.Equal(t, receive.SpanID, sc.SpanID)
+}
+
+func TestTracing_WithoutTracer(t *testing.T) {
+	p := newTestProxy()
+	req := httptest.NewRequest(http.MethodPost, "/jobs", bytes.NewBufferString(`{"user_id":"u1","query":"SELECT 1"}`))
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	p.SubmitJobHandler(rec, req)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	job := fetchNextJob(t, p, p.registry.Register(1, api.WorkerCapacity{}).ID)
+	sc, err := tracing.ParseTraceparent(job.Traceparent)
+	require.NoError(t, err, "the trace context is propagated without exporting spans")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+}
@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// WorkerAuth checks the credentials a worker registers with: a secret shared
+// by all workers or a token of its own. A registered worker gets a token
+// bound to its worker ID and sends it with every internal request.
+type WorkerAuth struct {
+	sharedSecret [sha256.Size]byte
+	hasSecret    bool
+	// names maps the SHA-256 of a per-worker token to the worker's name.
+	names map[[sha256.Size]byte]string
+}
+
+// NewWorkerAuth creates a WorkerAuth accepting the shared secret, if it's not
+// empty, and the per-worker tokens, mapped by worker name.
+func NewWorkerAuth(sharedSecret string, tokens map[string]string) *WorkerAuth {
+	a := &WorkerAuth{
+		sharedSecret: sha256.Sum256([]byte(sharedSecret)),
+		hasSecret:    sharedSecret != "",
+		names:        make(map[[sha256.Size]byte]string, len(tokens)),
+	}
+	for name, token := range tokens {
+		a.names[sha256.Sum256([]byte(token))] = name
+	}
+	return a
+}
+
+// LoadWorkerTokens reads a JSON file mapping worker names to their tokens.
+func LoadWorkerTokens(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tokens map[string]string
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return tokens, nil
+}
+
+// checkRegistration returns the name of the worker registering with the
+// request's credentials, the shared secret has no name.
+func (a *WorkerAuth) checkRegistration(r *http.Request) (string, bool) {
+	token, ok := workerToken(r)
+	if !ok {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(token))
+	if a.hasSecret && subtle.ConstantTimeCompare(sum[:], a.sharedSecret[:]) == 1 {
+		return "", true
+	}
+	name, ok := a.names[sum]
+	return name, ok
+}
+
+// RequireWorkerAuth makes workers register with credentials accepted by a
+// and authenticate every internal request with the token they got.
+func (p *Proxy) RequireWorkerAuth(a *WorkerAuth) {
+	p.workerAuth = a
+}
+
+// authorizeWorker checks that the request carries the token of the worker
+// with the given ID and responds with 401 if it doesn't. Without WorkerAuth
+// every request passes.
+func (p *Proxy) authorizeWorker(w http.ResponseWriter, r *http.Request, workerID string) bool {
+	if p.workerAuth == nil {
+		return true
+	}
+	token, _ := workerToken(r)
+	if handler, ok := p.registry.Get(workerID); ok && handler.CheckToken(token) {
+		return true
+	}
+	slog.Warn("unauthorized worker request", "event", "worker.auth.failed", "worker_id", workerID, "path", r.URL.Path)
+	http.Error(w, "Invalid worker credentials", http.StatusUnauthorized)
+	return false
+}
+
+// workerToken returns the bearer token of the request.
+func workerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"skein/internal/api"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func registerWorker(p *Proxy, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/internal/worker/register", bytes.NewBufferString(`{"slots":1}`))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	p.RegisterWorkerHandler(rec, req)
+	return rec
+}
+
+func TestRegisterWorkerHandler_Credentials(t *testing.T) {
+	p := newTestProxy()
+	p.RequireWorkerAuth(NewWorkerAuth("shared", map[string]string{"etl-1": "etl-token"}))
+
+	assert.Equal(t, http.StatusUnauthorized, registerWorker(p, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, registerWorker(p, "guess").Code)
+
+	for token, name := range map[string]string{"shared": "", "etl-token": "etl-1"} {
+		rec := registerWorker(p, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp api.RegisterWorkerResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.NotEmpty(t, resp.Token)
+		assert.NotEqual(t, token, resp.Token)
+
+		handler, ok := p.registry.Get(resp.WorkerID)
+		require.True(t, ok)
+		assert.Equal(t, name, handler.Name)
+	}
+}
+
+func TestWorkerAuth_InternalRequestsBoundToWorker(t *testing.T) {
+	p := newTestProxy()
+	p.RequireWorkerAuth(NewWorkerAuth("shared", nil))
+	var workers [2]api.RegisterWorkerResponse
+	for i := range workers {
+		rec := registerWorker(p, "shared")
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&workers[i]))
+	}
+	owner, other := workers[0], workers[1]
+
+	jobID := submitJob(t, p, api.QueryRequest{UserID: "u1", Query: "SELECT 1"})
+	next := func(workerID, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/internal/job/next?worker_id="+workerID, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		p.JobDispatcherHandler(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusUnauthorized, next(owner.WorkerID, "shared").Code, "the shared secret is only for registration")
+	assert.Equal(t, http.StatusUnauthorized, next(owner.WorkerID, other.Token).Code)
+	require.Equal(t, http.StatusOK, next(owner.WorkerID, owner.Token).Code)
+
+	post := func(workerID, token string) *httptest.ResponseRecorder {
+		body := `{"worker_id":"` + workerID + `","job_id":"` + jobID + `","result":{"error":"fake"}}`
+		req := httptest.NewRequest(http.MethodPost, "/internal/job/result", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		p.ResultHandler(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusUnauthorized, post(owner.WorkerID, other.Token).Code)
+	assert.Equal(t, http.StatusConflict, post(other.WorkerID, other.Token).Code, "the job wasn't dispatched to the other worker")
+	assert.Equal(t, api.StatusRunning, getJob(t, p, jobID).Status)
+	assert.Equal(t, http.StatusOK, post(owner.WorkerID, owner.Token).Code)
+	assert.Equal(t, api.StatusFailed, getJob(t, p, jobID).Status)
+}
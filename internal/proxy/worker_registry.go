@@ -2,9 +2,12 @@ package proxy
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
 	"errors"
 	"log/slog"
 	"skein/internal/api"
+	"sort"
 	"sync"
 	"time"
 
@@ -14,7 +17,6 @@ import (
 const (
 	workerSendTimeout  = 500 * time.Millisecond
 	staleWorkerTimeout = 60 * time.Second
-	cleanupInterval    = 5 * time.Minute
 )
 
 // ErrNoWorkersAvailable is returned when a job cannot be dispatched because no workers are ready.
@@ -22,37 +24,184 @@ var ErrNoWorkersAvailable = errors.New("no workers available to dispatch job")
 
 // WorkerHandler represents the proxy's state for a single worker.
 type WorkerHandler struct {
-	ID            string
-	JobChannel    chan *api.Job
-	mu            sync.RWMutex
-	ready         bool
+	ID string
+	// Name is the name of the worker's registration token, if it has one.
+	Name string
+	// Slots is the number of jobs the worker can execute at the same time.
+	Slots      int
+	Capacity   api.WorkerCapacity
+	JobChannel chan *api.Job
+	mu         sync.RWMutex
+	// waiting is the number of the worker's job requests waiting for a job.
+	waiting int
+	// leases holds the jobs handed to the worker, by job ID.
+	leases        map[string]*lease
 	lastHeartbeat time.Time
+	// token authenticates the worker's requests once it's registered.
+	token string
 }
 
-// NewWorkerHandler creates a new handler for a worker.
-func NewWorkerHandler() *WorkerHandler {
+// lease is the worker's ownership of a dispatched job. The worker has to
+// acknowledge the job before the lease expires for the first time and then
+// keeps renewing it with heartbeats.
+type lease struct {
+	job       *api.Job
+	expiresAt time.Time
+	acked     bool
+}
+
+// NewWorkerHandler creates a new handler for a worker with the given number of slots.
+func NewWorkerHandler(slots int, capacity api.WorkerCapacity) *WorkerHandler {
+	if slots < 1 {
+		slots = 1
+	}
 	return &WorkerHandler{
-		ID: uuid.NewString(),
-		// A buffered channel of 1 allows the registry to send a job without blocking
-		// while the worker's long poll request might be in flight.
-		JobChannel:    make(chan *api.Job, 0),
-		ready:         false,
+		ID:       uuid.NewString(),
+		Slots:    slots,
+		Capacity: capacity,
+		// The channel is unbuffered, a job is handed over only to a job
+		// request waiting for it.
+		JobChannel:    make(chan *api.Job),
+		leases:        make(map[string]*lease),
 		lastHeartbeat: time.Now().UTC(),
+		token:         rand.Text(),
 	}
 }
 
-// IsReady checks if the worker is marked as ready.
+// Token returns the token the worker authenticates its requests with.
+func (wh *WorkerHandler) Token() string {
+	return wh.token
+}
+
+// CheckToken reports whether token is the worker's token.
+func (wh *WorkerHandler) CheckToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(wh.token)) == 1
+}
+
+// Info returns the worker's state as listed by the API.
+func (wh *WorkerHandler) Info() api.WorkerInfo {
+	wh.mu.RLock()
+	defer wh.mu.RUnlock()
+	return api.WorkerInfo{
+		ID:            wh.ID,
+		Name:          wh.Name,
+		Slots:         wh.Slots,
+		Running:       len(wh.leases),
+		Ready:         wh.waiting > 0 && len(wh.leases) < wh.Slots,
+		Capacity:      wh.Capacity,
+		LastHeartbeat: wh.lastHeartbeat,
+	}
+}
+
+// IsReady checks if the worker has a free slot and a job request waiting for a job.
 func (wh *WorkerHandler) IsReady() bool {
 	wh.mu.RLock()
 	defer wh.mu.RUnlock()
-	return wh.ready
+	return wh.waiting > 0 && len(wh.leases) < wh.Slots
+}
+
+// Accepts reports whether the worker is big enough for the job and can
+// produce its result format.
+func (wh *WorkerHandler) Accepts(job *api.Job) bool {
+	if job.Format == api.FormatArrow && !wh.Capacity.Arrow {
+		return false
+	}
+	return wh.Capacity.Size.Satisfies(job.Size)
+}
+
+// StartWaiting records a job request waiting for a job.
+func (wh *WorkerHandler) StartWaiting() {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	wh.waiting++
+}
+
+// StopWaiting records that a job request stopped waiting for a job.
+func (wh *WorkerHandler) StopWaiting() {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	wh.waiting--
+}
+
+// FreeSlots returns the number of slots not running a job.
+func (wh *WorkerHandler) FreeSlots() int {
+	wh.mu.RLock()
+	defer wh.mu.RUnlock()
+	return wh.Slots - len(wh.leases)
+}
+
+// AssignJob records a job handed to the worker, the worker has to
+// acknowledge it before ackDeadline.
+func (wh *WorkerHandler) AssignJob(job *api.Job, ackDeadline time.Time) {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	wh.leases[job.ID] = &lease{job: job, expiresAt: ackDeadline}
+}
+
+// AckJob records the worker's acknowledgement of a job and extends its lease
+// until expiresAt. It returns false if the worker doesn't hold the job.
+func (wh *WorkerHandler) AckJob(jobID string, expiresAt time.Time) bool {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	l, ok := wh.leases[jobID]
+	if !ok {
+		return false
+	}
+	l.acked = true
+	l.expiresAt = expiresAt
+	return true
+}
+
+// RenewLeases extends the leases of acknowledged jobs until expiresAt.
+// It returns the IDs of the jobs the worker doesn't hold anymore.
+func (wh *WorkerHandler) RenewLeases(jobIDs []string, expiresAt time.Time) []string {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	var lost []string
+	for _, id := range jobIDs {
+		l, ok := wh.leases[id]
+		if !ok {
+			lost = append(lost, id)
+			continue
+		}
+		if l.acked {
+			l.expiresAt = expiresAt
+		}
+	}
+	return lost
+}
+
+// ReleaseJob frees the slot of a finished job.
+func (wh *WorkerHandler) ReleaseJob(jobID string) {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	delete(wh.leases, jobID)
+}
+
+// ExpiredJobs releases and returns the jobs whose lease expired before now.
+func (wh *WorkerHandler) ExpiredJobs(now time.Time) []*api.Job {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	var expired []*api.Job
+	for id, l := range wh.leases {
+		if now.After(l.expiresAt) {
+			expired = append(expired, l.job)
+			delete(wh.leases, id)
+		}
+	}
+	return expired
 }
 
-// SetReady marks the worker's ready status.
-func (wh *WorkerHandler) SetReady(ready bool) {
+// TakeJobs releases and returns all jobs held by the worker.
+func (wh *WorkerHandler) TakeJobs() []*api.Job {
 	wh.mu.Lock()
 	defer wh.mu.Unlock()
-	wh.ready = ready
+	jobs := make([]*api.Job, 0, len(wh.leases))
+	for id, l := range wh.leases {
+		jobs = append(jobs, l.job)
+		delete(wh.leases, id)
+	}
+	return jobs
 }
 
 // UpdateHeartbeat sets the last heartbeat time to now.
@@ -75,31 +224,39 @@ type WorkerRegistry struct {
 	workers map[string]*WorkerHandler
 }
 
-// NewWorkerRegistry creates a new worker registry and starts its cleanup process.
+// NewWorkerRegistry creates a new worker registry.
 func NewWorkerRegistry() *WorkerRegistry {
-	r := &WorkerRegistry{
+	return &WorkerRegistry{
 		workers: make(map[string]*WorkerHandler),
 	}
-	go r.cleanupLoop()
-	return r
 }
 
 // Register creates a new WorkerHandler, adds it to the pool, and returns it.
-func (r *WorkerRegistry) Register() *WorkerHandler {
-	handler := NewWorkerHandler()
+func (r *WorkerRegistry) Register(slots int, capacity api.WorkerCapacity) *WorkerHandler {
+	return r.RegisterNamed("", slots, capacity)
+}
+
+// RegisterNamed registers a worker that authenticated with the token of the
+// given name.
+func (r *WorkerRegistry) RegisterNamed(name string, slots int, capacity api.WorkerCapacity) *WorkerHandler {
+	handler := NewWorkerHandler(slots, capacity)
+	handler.Name = name
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	r.workers[handler.ID] = handler
-	slog.Info("worker registered", "worker_id", handler.ID)
+	slog.Info("worker registered", "worker_id", handler.ID, "name", name, "slots", handler.Slots,
+		"size", capacity.Size, "threads", capacity.Threads, "memory_limit_bytes", capacity.MemoryLimitBytes)
 	return handler
 }
 
-// Deregister removes a worker from the pool.
-func (r *WorkerRegistry) Deregister(workerID string) {
+// Deregister removes a worker from the pool and returns it.
+func (r *WorkerRegistry) Deregister(workerID string) (*WorkerHandler, bool) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	handler, ok := r.workers[workerID]
 	delete(r.workers, workerID)
 	slog.Info("worker deregistered", "worker_id", workerID)
+	return handler, ok
 }
 
 // Heartbeat updates the heartbeat timestamp for a given worker.
@@ -114,6 +271,17 @@ func (r *WorkerRegistry) Heartbeat(workerID string) bool {
 	return false
 }
 
+// Workers returns all registered workers.
+func (r *WorkerRegistry) Workers() []*WorkerHandler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	workers := make([]*WorkerHandler, 0, len(r.workers))
+	for _, handler := range r.workers {
+		workers = append(workers, handler)
+	}
+	return workers
+}
+
 // Get returns a worker handler by its ID.
 func (r *WorkerRegistry) Get(workerID string) (*WorkerHandler, bool) {
 	r.mu.RLock()
@@ -122,17 +290,12 @@ func (r *WorkerRegistry) Get(workerID string) (*WorkerHandler, bool) {
 	return handler, ok
 }
 
-// Dispatch finds a ready worker and attempts to send it a job.
+// Dispatch finds a ready worker big enough for the job and attempts to send
+// it the job. The smallest fitting workers are tried first, so larger workers
+// stay available for heavier jobs; among workers of the same size the ones
+// with more free slots go first.
 func (r *WorkerRegistry) Dispatch(ctx context.Context, job *api.Job) error {
-	r.mu.RLock()
-	for _, handler := range r.workers {
-		r.mu.RUnlock()
-
-		if !handler.IsReady() {
-			r.mu.RLock()
-			continue
-		}
-
+	for _, handler := range r.readyWorkers(job) {
 		// Try to send the job with a timeout.
 		select {
 		case handler.JobChannel <- job:
@@ -141,32 +304,74 @@ func (r *WorkerRegistry) Dispatch(ctx context.Context, job *api.Job) error {
 		case <-time.After(workerSendTimeout):
 			// Worker was not ready to receive, try the next one.
 			slog.Warn("timed out sending job to worker, trying next", "worker_id", handler.ID)
-			r.mu.RLock()
-			continue
 		case <-ctx.Done():
 			// The original request context was cancelled.
 			return ctx.Err()
 		}
 	}
-	r.mu.RUnlock()
 
 	return ErrNoWorkersAvailable
 }
 
-// cleanupLoop periodically removes stale workers from the registry.
-func (r *WorkerRegistry) cleanupLoop() {
-	ticker := time.NewTicker(cleanupInterval)
-	defer ticker.Stop()
+// HasReadyWorkerFor reports whether any ready worker can run the job.
+func (r *WorkerRegistry) HasReadyWorkerFor(job *api.Job) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, handler := range r.workers {
+		if handler.IsReady() && handler.Accepts(job) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasArrowWorker reports whether any registered worker can produce Arrow
+// results.
+func (r *WorkerRegistry) HasArrowWorker() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, handler := range r.workers {
+		if handler.Capacity.Arrow {
+			return true
+		}
+	}
+	return false
+}
+
+// readyWorkers returns the ready workers that can run the job in the order
+// they should be tried.
+func (r *WorkerRegistry) readyWorkers(job *api.Job) []*WorkerHandler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ready := make([]*WorkerHandler, 0, len(r.workers))
+	for _, handler := range r.workers {
+		if handler.IsReady() && handler.Accepts(job) {
+			ready = append(ready, handler)
+		}
+	}
+	sort.SliceStable(ready, func(i, j int) bool {
+		if c := ready[i].Capacity.Size.Compare(ready[j].Capacity.Size); c != 0 {
+			return c < 0
+		}
+		return ready[i].FreeSlots() > ready[j].FreeSlots()
+	})
+	return ready
+}
+
+// RemoveStale removes the workers that haven't sent a heartbeat in a while
+// and returns them.
+func (r *WorkerRegistry) RemoveStale() []*WorkerHandler {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	for range ticker.C {
-		r.mu.Lock()
-		slog.Info("running worker cleanup")
-		for id, handler := range r.workers {
-			if handler.IsStale() {
-				delete(r.workers, id)
-				slog.Info("removed stale worker", "worker_id", id)
-			}
+	var removed []*WorkerHandler
+	for id, handler := range r.workers {
+		if handler.IsStale() {
+			delete(r.workers, id)
+			removed = append(removed, handler)
+			slog.Info("removed stale worker", "worker_id", id)
 		}
-		r.mu.Unlock()
 	}
+	return removed
 }
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"context"
+	"skein/internal/api"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerHandler_Slots(t *testing.T) {
+	wh := NewWorkerHandler(2, api.WorkerCapacity{})
+	assert.Equal(t, 2, wh.FreeSlots())
+	assert.False(t, wh.IsReady(), "no job request is waiting")
+
+	wh.StartWaiting()
+	assert.True(t, wh.IsReady())
+
+	wh.AssignJob(&api.Job{ID: "a"}, time.Now().Add(time.Minute))
+	assert.Equal(t, 1, wh.FreeSlots())
+	assert.True(t, wh.IsReady())
+
+	wh.AssignJob(&api.Job{ID: "b"}, time.Now().Add(time.Minute))
+	assert.Equal(t, 0, wh.FreeSlots())
+	assert.False(t, wh.IsReady(), "all slots are busy")
+
+	wh.ReleaseJob("a")
+	assert.True(t, wh.IsReady())
+
+	wh.StopWaiting()
+	assert.False(t, wh.IsReady())
+
+	assert.Equal(t, 1, NewWorkerHandler(0, api.WorkerCapacity{}).Slots, "a worker has at least one slot")
+}
+
+func TestWorkerRegistry_DispatchPrefersFreeSlots(t *testing.T) {
+	r := NewWorkerRegistry()
+	small := r.Register(1, api.WorkerCapacity{})
+	big := r.Register(4, api.WorkerCapacity{})
+	big.AssignJob(&api.Job{ID: "running"}, time.Now().Add(time.Minute))
+
+	for _, wh := range []*WorkerHandler{small, big} {
+		wh.StartWaiting()
+		defer wh.StopWaiting()
+	}
+
+	got := make(chan string, 1)
+	go func() {
+		select {
+		case job := <-big.JobChannel:
+			got <- "big:" + job.ID
+		case job := <-small.JobChannel:
+			got <- "small:" + job.ID
+		}
+	}()
+
+	require.NoError(t, r.Dispatch(context.Background(), &api.Job{ID: "j1"}))
+	select {
+	case id := <-got:
+		assert.Equal(t, "big:j1", id)
+	case <-time.After(time.Second):
+		t.Fatal("job was not received")
+	}
+}
+
+func TestWorkerRegistry_DispatchNoWorkers(t *testing.T) {
+	r := NewWorkerRegistry()
+	r.Register(2, api.WorkerCapacity{})
+	assert.ErrorIs(t, r.Dispatch(context.Background(), &api.Job{ID: "j1"}), ErrNoWorkersAvailable)
+}
+
+func TestWorkerRegistry_DispatchBySize(t *testing.T) {
+	r := NewWorkerRegistry()
+	small := r.Register(4, api.WorkerCapacity{Size: api.SizeSmall})
+	medium := r.Register(1, api.WorkerCapacity{Size: api.SizeMedium})
+	large := r.Register(4, api.WorkerCapacity{Size: api.SizeLarge})
+	for _, wh := range []*WorkerHandler{small, medium, large} {
+		wh.StartWaiting()
+		defer wh.StopWaiting()
+	}
+
+	got := make(chan string, 1)
+	receive := func() {
+		select {
+		case job := <-small.JobChannel:
+			got <- "small:" + job.ID
+		case job := <-medium.JobChannel:
+			got <- "medium:" + job.ID
+		case job := <-large.JobChannel:
+			got <- "large:" + job.ID
+		}
+	}
+
+	go receive()
+	require.NoError(t, r.Dispatch(context.Background(), &api.Job{ID: "point-lookup"}))
+	assert.Equal(t, "small:point-lookup", <-got, "the smallest worker gets a job without a size hint")
+
+	go receive()
+	require.NoError(t, r.Dispatch(context.Background(), &api.Job{ID: "aggregation", Size: api.SizeMedium}))
+	assert.Equal(t, "medium:aggregation", <-got, "the smallest worker that fits is preferred")
+
+	assert.True(t, r.HasReadyWorkerFor(&api.Job{Size: api.SizeLarge}))
+	large.StopWaiting()
+	assert.False(t, r.HasReadyWorkerFor(&api.Job{Size: api.SizeLarge}))
+	large.StartWaiting()
+}
@@ -0,0 +1,50 @@
+package settings
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DurationFromEnv reads a duration from an environment variable, falling back to def when it's not set.
+func DurationFromEnv(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		slog.Error("invalid duration in environment variable", "name", name, "value", v, "error", err)
+		os.Exit(1)
+	}
+	return d
+}
+
+// IntFromEnv reads an integer from an environment variable, falling back to def when it's not set.
+func IntFromEnv(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		slog.Error("invalid integer in environment variable", "name", name, "value", v, "error", err)
+		os.Exit(1)
+	}
+	return n
+}
+
+// BoolFromEnv reads a boolean from an environment variable, falling back to def when it's not set.
+func BoolFromEnv(name string, def bool) bool {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		slog.Error("invalid boolean in environment variable", "name", name, "value", v, "error", err)
+		os.Exit(1)
+	}
+	return b
+}
@@ -1,8 +1,48 @@
 package settings
 
-import "time"
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
 
 var (
 	HeartbeatInterval = 10 * time.Second
 	LongPollTimeout   = 30 * time.Second
+
+	// A worker has to acknowledge a dispatched job within JobAckTimeout, after that
+	// its heartbeats keep the job's lease for JobLeaseDuration. A job with an expired
+	// lease is redelivered, at most MaxDeliveryAttempts times in total.
+	JobAckTimeout       = 10 * time.Second
+	JobLeaseDuration    = 3 * HeartbeatInterval
+	MaxDeliveryAttempts = 3
+
+	// A queued job gains QueueAgingStep priority for every QueueAgingInterval it waits.
+	QueueAgingInterval = 30 * time.Second
+	QueueAgingStep     = 10
+
+	// Rows per result page when the client doesn't ask for a limit, and the largest limit allowed.
+	ResultPageLimit    = 1000
+	MaxResultPageLimit = 100000
+
+	// Results of completed queries are cached for ResultCacheTTL, up to ResultCacheMaxBytes
+	// in total. Either being zero disables the cache, it's off unless ResultCacheTTL is set.
+	ResultCacheTTL      = time.Duration(0)
+	ResultCacheMaxBytes = int64(256 << 20)
+
+	// Directory the workers read the datasets from, the proxy has to see it at the same
+	// path for the result cache to tell when the files change.
+	DatasetsDir = "datasets"
+
+	// Database file the proxy keeps its jobs in, so they survive a restart. Empty keeps
+	// the jobs in memory only. Results are stored with their jobs if JobStoreResults is set.
+	JobStorePath    = ""
+	JobStoreResults = true
+
+	// Directory where the proxy keeps exported result files.
+	ExportSpoolDir = filepath.Join(os.TempDir(), "skein-exports")
+
+	// Maximum number of concurrently running queries per query class, 0 means no limit.
+	MaxConcurrentUserQueries   = 8
+	MaxConcurrentSystemQueries = 2
 )
@@ -0,0 +1,330 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileExporter writes every span as a JSON line to a file.
+type FileExporter struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewFileExporter appends spans to the file at path.
+func NewFileExporter(path string) (*FileExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileExporter{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Export implements Exporter.
+func (e *FileExporter) Export(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.enc.Encode(span); err != nil {
+		slog.Warn("failed to write span", "name", span.Name, "error", err)
+	}
+}
+
+// Shutdown closes the file.
+func (e *FileExporter) Shutdown(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.file.Close()
+}
+
+// ReadSpans reads the spans written by a FileExporter.
+func ReadSpans(path string) ([]SpanData, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var spans []SpanData
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var span SpanData
+		if err := dec.Decode(&span); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		spans = append(spans, span)
+	}
+	return spans, nil
+}
+
+const (
+	otlpBatchSize     = 512
+	otlpQueueSize     = 4096
+	otlpFlushInterval = 2 * time.Second
+)
+
+// OTLPExporter sends spans in batches to an OpenTelemetry collector with the
+// OTLP/HTTP JSON protocol. Spans are dropped when the collector can't keep up.
+type OTLPExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+	spans    chan SpanData
+	flush    chan chan struct{}
+	done     chan struct{}
+}
+
+// NewOTLPExporter creates an exporter posting to the traces endpoint, e.g.
+// http://collector:4318/v1/traces, with the extra headers.
+func NewOTLPExporter(endpoint string, headers map[string]string) *OTLPExporter {
+	e := &OTLPExporter{
+		endpoint: endpoint,
+		headers:  headers,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		spans:    make(chan SpanData, otlpQueueSize),
+		flush:    make(chan chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go e.exportLoop()
+	return e
+}
+
+// Export implements Exporter.
+func (e *OTLPExporter) Export(span SpanData) {
+	select {
+	case e.spans <- span:
+	default:
+		slog.Warn("span queue full, dropping span", "name", span.Name)
+	}
+}
+
+// Shutdown sends the queued spans and stops the exporter.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case e.flush <- flushed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *OTLPExporter) exportLoop() {
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+
+	var batch []SpanData
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil {
+			slog.Warn("failed to export spans", "endpoint", e.endpoint, "spans", len(batch), "error", err)
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case span := <-e.spans:
+			batch = append(batch, span)
+			if len(batch) >= otlpBatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case flushed := <-e.flush:
+			for n := len(e.spans); n > 0; n-- {
+				batch = append(batch, <-e.spans)
+			}
+			send()
+			close(flushed)
+			return
+		}
+	}
+}
+
+func (e *OTLPExporter) send(spans []SpanData) error {
+	body, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// The OTLP JSON encoding of ExportTraceServiceRequest, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding.
+type (
+	otlpTraces struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            *otlpStatus    `json:"status,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusError      = 2
+)
+
+// otlpRequest groups the spans by service into resource spans.
+func otlpRequest(spans []SpanData) otlpTraces {
+	byService := make(map[string][]otlpSpan)
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			Name:              s.Name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes),
+		}
+		if !s.ParentSpanID.IsZero() {
+			span.ParentSpanID = s.ParentSpanID.String()
+		}
+		if s.Error != "" {
+			span.Status = &otlpStatus{Code: otlpStatusError, Message: s.Error}
+		}
+		byService[s.Service] = append(byService[s.Service], span)
+	}
+
+	var req otlpTraces
+	for service, spans := range byService {
+		req.ResourceSpans = append(req.ResourceSpans, otlpResourceSpans{
+			Resource:   otlpResource{Attributes: otlpAttributes(map[string]any{"service.name": service})},
+			ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "skein"}, Spans: spans}},
+		})
+	}
+	return req
+}
+
+func otlpAttributes(attrs map[string]any) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		var value map[string]any
+		switch v := attrs[k].(type) {
+		case string:
+			value = map[string]any{"stringValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: value})
+	}
+	return kvs
+}
+
+// FromEnv creates a tracer configured with the standard OpenTelemetry
+// variables: OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, or OTEL_EXPORTER_OTLP_ENDPOINT
+// with /v1/traces appended, OTEL_EXPORTER_OTLP_HEADERS and OTEL_SERVICE_NAME
+// overriding service. TRACE_FILE also writes the spans to a file. Without
+// any of them it returns nil, which propagates trace context but exports
+// nothing.
+func FromEnv(service string) (*Tracer, error) {
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		service = name
+	}
+	var exporters []Exporter
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint == "" && base != "" {
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	if endpoint != "" {
+		exporters = append(exporters, NewOTLPExporter(endpoint, parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))))
+	}
+	if path := os.Getenv("TRACE_FILE"); path != "" {
+		e, err := NewFileExporter(path)
+		if err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, e)
+	}
+	if len(exporters) == 0 {
+		return nil, nil
+	}
+	return NewTracer(service, exporters...), nil
+}
+
+// parseHeaders parses comma separated key=value pairs.
+func parseHeaders(s string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return headers
+}
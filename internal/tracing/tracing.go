@@ -0,0 +1,294 @@
+// Package tracing records spans of a query's way through the proxy and the
+// workers, linked by W3C trace context (traceparent) and exported over
+// OTLP/HTTP or to a local file.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader is the W3C trace context header.
+const TraceparentHeader = "traceparent"
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// IsZero reports whether the ID is unset, the all-zero ID is invalid.
+func (id TraceID) IsZero() bool { return id == TraceID{} }
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// MarshalText encodes the ID as lowercase hex.
+func (id TraceID) MarshalText() ([]byte, error) { return []byte(id.String()), nil }
+
+// UnmarshalText decodes the ID from hex.
+func (id *TraceID) UnmarshalText(text []byte) error { return decodeHex(id[:], string(text)) }
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// IsZero reports whether the ID is unset, the all-zero ID is invalid.
+func (id SpanID) IsZero() bool { return id == SpanID{} }
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// MarshalText encodes the ID as lowercase hex.
+func (id SpanID) MarshalText() ([]byte, error) { return []byte(id.String()), nil }
+
+// UnmarshalText decodes the ID from hex.
+func (id *SpanID) UnmarshalText(text []byte) error { return decodeHex(id[:], string(text)) }
+
+func decodeHex(dst []byte, s string) error {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return fmt.Errorf("want %d lowercase hex digits, got %q", 2*len(dst), s)
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+// SpanContext is the part of a span propagated to other processes.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether both IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return !sc.TraceID.IsZero() && !sc.SpanID.IsZero()
+}
+
+// Traceparent formats the context as a version 00 traceparent header value,
+// or returns "" if it isn't valid.
+func (sc SpanContext) Traceparent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent parses a traceparent header value. Versions after 00 are
+// accepted as long as they start with the version 00 fields.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("invalid traceparent %q", s)
+	}
+	var version, flags [1]byte
+	if err := decodeHex(version[:], parts[0]); err != nil {
+		return sc, fmt.Errorf("invalid traceparent version: %w", err)
+	}
+	if err := sc.TraceID.UnmarshalText([]byte(parts[1])); err != nil {
+		return sc, fmt.Errorf("invalid trace ID: %w", err)
+	}
+	if err := sc.SpanID.UnmarshalText([]byte(parts[2])); err != nil {
+		return sc, fmt.Errorf("invalid parent ID: %w", err)
+	}
+	if err := decodeHex(flags[:], parts[3]); err != nil {
+		return sc, fmt.Errorf("invalid trace flags: %w", err)
+	}
+	if !sc.IsValid() {
+		return sc, errors.New("all-zero trace or parent ID")
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a context whose spans are children of sc.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFrom returns the span context of ctx, if there is one.
+func SpanContextFrom(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// Extract returns a context carrying the traceparent of the header, an
+// invalid header is ignored and the spans start a new trace.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return ContextWithTraceparent(ctx, header.Get(TraceparentHeader))
+}
+
+// ContextWithTraceparent is like Extract for a traceparent value.
+func ContextWithTraceparent(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+	sc, err := ParseTraceparent(traceparent)
+	if err != nil {
+		return ctx
+	}
+	return ContextWithSpanContext(ctx, sc)
+}
+
+// Attribute is a key/value pair describing a span.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// String returns a string attribute.
+func String(key, value string) Attribute { return Attribute{key, value} }
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attribute { return Attribute{key, int64(value)} }
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attribute { return Attribute{key, value} }
+
+// SpanData is a finished span as it's exported.
+type SpanData struct {
+	Service      string         `json:"service"`
+	Name         string         `json:"name"`
+	TraceID      TraceID        `json:"trace_id"`
+	SpanID       SpanID         `json:"span_id"`
+	ParentSpanID SpanID         `json:"parent_span_id,omitzero"`
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Error        string         `json:"error,omitempty"`
+}
+
+// Exporter sends finished spans to a tracing backend.
+type Exporter interface {
+	Export(span SpanData)
+	Shutdown(ctx context.Context) error
+}
+
+// Tracer creates spans of a service. A nil Tracer creates and propagates
+// spans but doesn't export them.
+type Tracer struct {
+	service   string
+	exporters []Exporter
+}
+
+// NewTracer creates a tracer exporting the spans of service to exporters.
+func NewTracer(service string, exporters ...Exporter) *Tracer {
+	return &Tracer{service: service, exporters: exporters}
+}
+
+// Shutdown exports the remaining spans and stops the exporters.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	var errs []error
+	for _, e := range t.exporters {
+		errs = append(errs, e.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Start starts a span, a child of the span in ctx or the root of a new
+// sampled trace. The returned context carries the new span.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	span := t.newSpan(ctx, name, time.Now(), attrs)
+	return ContextWithSpanContext(ctx, span.sc), span
+}
+
+// Record exports a span that already happened between start and end.
+func (t *Tracer) Record(ctx context.Context, name string, start, end time.Time, attrs ...Attribute) {
+	t.newSpan(ctx, name, start, attrs).EndAt(end)
+}
+
+func (t *Tracer) newSpan(ctx context.Context, name string, start time.Time, attrs []Attribute) *Span {
+	span := &Span{tracer: t, data: SpanData{Name: name, Start: start}}
+	if parent, ok := SpanContextFrom(ctx); ok {
+		span.sc = SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled}
+		span.data.ParentSpanID = parent.SpanID
+	} else {
+		rand.Read(span.sc.TraceID[:])
+		span.sc.Sampled = true
+	}
+	rand.Read(span.sc.SpanID[:])
+	span.SetAttributes(attrs...)
+	return span
+}
+
+// Span is a timed operation of a trace.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// Context returns the span's context to propagate to its children.
+func (s *Span) Context() SpanContext {
+	return s.sc
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if len(attrs) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]any, len(attrs))
+	}
+	for _, a := range attrs {
+		s.data.Attributes[a.Key] = a.Value
+	}
+}
+
+// SetError marks the span as failed with err, a nil err is ignored.
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Error = err.Error()
+}
+
+// End ends the span now and exports it. Only the first call has an effect.
+func (s *Span) End() {
+	s.EndAt(time.Now())
+}
+
+// EndAt ends the span at the given time and exports it.
+func (s *Span) EndAt(end time.Time) {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = end
+	data := s.data
+	s.mu.Unlock()
+
+	if s.tracer == nil || !s.sc.Sampled {
+		return
+	}
+	data.Service = s.tracer.service
+	data.TraceID = s.sc.TraceID
+	data.SpanID = s.sc.SpanID
+	for _, e := range s.tracer.exporters {
+		e.Export(data)
+	}
+}
@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := ParseTraceparent(testTraceparent)
+	require.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(t, sc.Sampled)
+	assert.Equal(t, testTraceparent, sc.Traceparent())
+
+	sc, err = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future")
+	require.NoError(t, err, "later versions may add fields")
+	assert.False(t, sc.Sampled)
+
+	for _, s := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+	} {
+		_, err := ParseTraceparent(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestTracer_FileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	exporter, err := NewFileExporter(path)
+	require.NoError(t, err)
+	tracer := NewTracer("test", exporter)
+
+	ctx := ContextWithTraceparent(context.Background(), testTraceparent)
+	ctx, parent := tracer.Start(ctx, "parent", String("job.id", "j1"))
+	_, child := tracer.Start(ctx, "child", Int("rows", 3))
+	child.SetError(errors.New("boom"))
+	child.End()
+	parent.End()
+	parent.End()
+	start := time.Now().Add(-time.Second)
+	tracer.Record(ctx, "recorded", start, start.Add(time.Millisecond))
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	spans, err := ReadSpans(path)
+	require.NoError(t, err)
+	require.Len(t, spans, 3, "a span is exported once")
+	gotChild, gotParent, recorded := spans[0], spans[1], spans[2]
+
+	assert.Equal(t, "child", gotChild.Name)
+	assert.Equal(t, "test", gotChild.Service)
+	assert.Equal(t, "boom", gotChild.Error)
+	assert.Equal(t, float64(3), gotChild.Attributes["rows"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", gotParent.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", gotParent.ParentSpanID.String())
+	assert.Equal(t, "j1", gotParent.Attributes["job.id"])
+	assert.Equal(t, gotParent.TraceID, gotChild.TraceID)
+	assert.Equal(t, gotParent.SpanID, gotChild.ParentSpanID)
+	assert.Equal(t, gotParent.SpanID, recorded.ParentSpanID)
+	assert.Equal(t, time.Millisecond, recorded.End.Sub(recorded.Start))
+}
+
+func TestTracer_NewTraceAndUnsampled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	exporter, err := NewFileExporter(path)
+	require.NoError(t, err)
+	tracer := NewTracer("test", exporter)
+
+	_, root := tracer.Start(context.Background(), "root")
+	root.End()
+	assert.True(t, root.Context().IsValid())
+	assert.True(t, root.Context().Sampled)
+
+	ctx := ContextWithTraceparent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	_, unsampled := tracer.Start(ctx, "unsampled")
+	unsampled.End()
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	spans, err := ReadSpans(path)
+	require.NoError(t, err)
+	require.Len(t, spans, 1)
+	assert.Equal(t, "root", spans[0].Name)
+	assert.True(t, spans[0].ParentSpanID.IsZero())
+
+	var nilTracer *Tracer
+	_, span := nilTracer.Start(ctx, "propagated")
+	span.End()
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.Context().TraceID.String())
+}
+
+func TestOTLPExporter(t *testing.T) {
+	requests := make(chan map[string]any, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "secret", r.Header.Get("X-Token"))
+		body, _ := io.ReadAll(r.Body)
+		var req map[string]any
+		assert.NoError(t, json.Unmarshal(body, &req))
+		requests <- req
+	}))
+	defer server.Close()
+
+	tracer := NewTracer("skein-test", NewOTLPExporter(server.URL+"/v1/traces", map[string]string{"X-Token": "secret"}))
+	ctx := ContextWithTraceparent(context.Background(), testTraceparent)
+	_, span := tracer.Start(ctx, "query", Bool("cache.hit", false), Int("rows", 7))
+	span.SetError(errors.New("failed"))
+	span.End()
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	req := <-requests
+	resourceSpans := req["resourceSpans"].([]any)[0].(map[string]any)
+	assert.Equal(t, []any{map[string]any{"key": "service.name", "value": map[string]any{"stringValue": "skein-test"}}},
+		resourceSpans["resource"].(map[string]any)["attributes"])
+	got := resourceSpans["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)[0].(map[string]any)
+	assert.Equal(t, "query", got["name"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", got["traceId"])
+	assert.Equal(t, "00f067aa0ba902b7", got["parentSpanId"])
+	assert.Len(t, got["spanId"], 16)
+	assert.Equal(t, []any{
+		map[string]any{"key": "cache.hit", "value": map[string]any{"boolValue": false}},
+		map[string]any{"key": "rows", "value": map[string]any{"intValue": "7"}},
+	}, got["attributes"])
+	assert.Equal(t, map[string]any{"code": float64(2), "message": "failed"}, got["status"])
+}